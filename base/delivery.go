@@ -0,0 +1,72 @@
+package base
+
+import (
+	"time"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+)
+
+// Acknowledger settles a delivery with the message broker it was received from.
+// Every broker client provides its own implementation.
+type Acknowledger interface {
+	Ack() error                // message was processed and can be removed
+	Nack(requeue bool) error   // message failed to be processed
+	Reject(requeue bool) error // message was refused without being processed
+}
+
+// Delivery a broker agnostic representation of a message received from a message broker
+type Delivery struct {
+	Acknowledger Acknowledger `json:"-"` // settles the delivery with its broker
+
+	MessageID       string                 `json:"message_id,omitempty"`       // broker assigned id of the message
+	Headers         map[string]interface{} `json:"headers,omitempty"`          // message headers/attributes
+	ContentType     string                 `json:"content_type,omitempty"`     // MIME content type of the body
+	ContentEncoding string                 `json:"content_encoding,omitempty"` // MIME content encoding of the body
+	Body            []byte                 `json:"body"`                       // actual message body
+	Queue           string                 `json:"queue"`                      // queue/topic message was received from
+	Exchange        string                 `json:"exchange,omitempty"`         // exchange message was published to (if any)
+	RoutingKey      string                 `json:"routing_key,omitempty"`      // routing/partition key of the message
+	Priority        uint8                  `json:"priority,omitempty"`         // priority message was published with
+	Redelivered     bool                   `json:"redelivered"`                // if message has been delivered before
+	RedeliveryCount int                    `json:"redelivery_count"`           // no. of times message has been delivered before (if known)
+	Timestamp       time.Time              `json:"timestamp,omitempty"`        // when message was published (if known)
+}
+
+// Ack acknowledges the delivery
+func (d Delivery) Ack() error {
+	if d.Acknowledger == nil {
+		return libErrs.ErrorDeliveryNotAcknowledgeable
+	}
+
+	return d.Acknowledger.Ack()
+}
+
+// Nack negatively acknowledges the delivery. If requeue is true the
+// broker will attempt to deliver the message again.
+func (d Delivery) Nack(requeue bool) error {
+	if d.Acknowledger == nil {
+		return libErrs.ErrorDeliveryNotAcknowledgeable
+	}
+
+	return d.Acknowledger.Nack(requeue)
+}
+
+// Reject rejects the delivery. If requeue is true the broker will
+// attempt to deliver the message again.
+func (d Delivery) Reject(requeue bool) error {
+	if d.Acknowledger == nil {
+		return libErrs.ErrorDeliveryNotAcknowledgeable
+	}
+
+	return d.Acknowledger.Reject(requeue)
+}
+
+// Header returns the value of a header as a string. Empty if
+// the header is not set or is not a string.
+func (d Delivery) Header(key string) string {
+	if v, ok := d.Headers[key].(string); ok {
+		return v
+	}
+
+	return ""
+}
@@ -0,0 +1,42 @@
+package base
+
+// NamedEvent is implemented by events that have a name e.g product.created.
+// The name labels the metrics recorded for an event.
+type NamedEvent interface {
+	EventName() string
+}
+
+// ErrorRecorder is implemented by events that record the errors they failed
+// with e.g when deadlettered.
+type ErrorRecorder interface {
+	RecordErrors(errs ...Error)
+}
+
+// EventName name of the event
+func (e EyewaEvent) EventName() string {
+	return e.Name
+}
+
+// RecordErrors appends errs to the errors of the event
+func (e *EyewaEvent) RecordErrors(errs ...Error) {
+	e.Errors = append(e.Errors, errs...)
+}
+
+// EventName name of the event
+func (e MagentoProductEvent) EventName() string {
+	return e.Name
+}
+
+// RecordErrors appends errs to the errors of the event
+func (e *MagentoProductEvent) RecordErrors(errs ...Error) {
+	e.Errors = append(e.Errors, errs...)
+}
+
+// EventNameOf the name of event if it has one.
+func EventNameOf(event interface{}) string {
+	if named, ok := event.(NamedEvent); ok {
+		return named.EventName()
+	}
+
+	return ""
+}
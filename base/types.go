@@ -11,18 +11,18 @@ type EyewaProductType string
 
 // EyewaEvent a base representation of an event fired/received
 type EyewaEvent struct {
-	ID           string `json:"id"`                      // can be used for tracing
-	Name         string `json:"name"`                    // name of event - product.created, product.updated etc
-	EventType    string `json:"event_type"`              // type of event's entity - Product, Order etc
-	StoreCode    string `json:"store_code,omitempty"`    // store locale for store sa-sone, kw-ar, sa-en etc
-	EventSubType string `json:"event_subtype,omitempty"` // product-simple/product-simple-custom/product-configurable", // Would be empty for category events
+	ID           string `json:"id" avro:"id"`                                 // can be used for tracing
+	Name         string `json:"name" avro:"name"`                             // name of event - product.created, product.updated etc
+	EventType    string `json:"event_type" avro:"event_type"`                 // type of event's entity - Product, Order etc
+	StoreCode    string `json:"store_code,omitempty" avro:"store_code"`       // store locale for store sa-sone, kw-ar, sa-en etc
+	EventSubType string `json:"event_subtype,omitempty" avro:"event_subtype"` // product-simple/product-simple-custom/product-configurable", // Would be empty for category events
 
 	// a representation on an error. provides reasons when a message ends up back
 	// in the queue
-	Errors []Error `json:"errors,omitempty"`
+	Errors []Error `json:"errors,omitempty" avro:"errors"`
 
-	Payload   json.RawMessage `json:"payload"`    // actual event payload
-	CreatedAt string          `json:"created_at"` // time in RFC3339 format
+	Payload   json.RawMessage `json:"payload" avro:"payload"`       // actual event payload
+	CreatedAt string          `json:"created_at" avro:"created_at"` // time in RFC3339 format
 }
 
 // DeleteEventPayload used solely for publishing deleted events
@@ -35,24 +35,24 @@ type DeleteEventPayload struct {
 
 // MagentoProductEvent a representation of a product event in Magento
 type MagentoProductEvent struct {
-	ID           string  `json:"id"`                     // uuid
-	Name         string  `json:"event"`                  // name of event - product.created, catalog.created etc
-	EntityID     int     `json:"entity_id"`              // ID of the product in magento
-	EventType    string  `json:"event_type"`             // type of event's entity - Product, Order etc
-	EventSubType string  `json:"event_subtype"`          // product-simple/product-simple-custom/product-configurable", // Would be empty for category events
-	StoreID      int     `json:"store_id"`               // ID of the store the product/category belongs to
-	StoreCode    string  `json:"store_code"`             // store code for store ae-en, qa-ar etc
-	WebsiteID    int     `json:"website_id"`             // ID of website store is assigned to
-	Errors       []Error `json:"errors,omitempty"`       // provides reasons why a message ended up in the deadletter queue for e.g
-	CreatedAt    string  `json:"created_at"`             // time in RFC3339 format of when event occurred
-	IsMigration  bool    `json:"is_migration,omitempty"` // indicates if event is part of a migration process or not.
+	ID           string  `json:"id" avro:"id"`                               // uuid
+	Name         string  `json:"event" avro:"event"`                         // name of event - product.created, catalog.created etc
+	EntityID     int     `json:"entity_id" avro:"entity_id"`                 // ID of the product in magento
+	EventType    string  `json:"event_type" avro:"event_type"`               // type of event's entity - Product, Order etc
+	EventSubType string  `json:"event_subtype" avro:"event_subtype"`         // product-simple/product-simple-custom/product-configurable", // Would be empty for category events
+	StoreID      int     `json:"store_id" avro:"store_id"`                   // ID of the store the product/category belongs to
+	StoreCode    string  `json:"store_code" avro:"store_code"`               // store code for store ae-en, qa-ar etc
+	WebsiteID    int     `json:"website_id" avro:"website_id"`               // ID of website store is assigned to
+	Errors       []Error `json:"errors,omitempty" avro:"errors"`             // provides reasons why a message ended up in the deadletter queue for e.g
+	CreatedAt    string  `json:"created_at" avro:"created_at"`               // time in RFC3339 format of when event occurred
+	IsMigration  bool    `json:"is_migration,omitempty" avro:"is_migration"` // indicates if event is part of a migration process or not.
 }
 
 // Error a structural info about an error within the ecosystem
 type Error struct {
-	ErrorCode    int    `json:"error_code"`    // custom or http code should suffice
-	ErrorMessage string `json:"error_message"` // error being reported
-	CreatedAt    string `json:"created_at"`    // time in RFC3339 format
+	ErrorCode    int    `json:"error_code" avro:"error_code"`       // custom or http code should suffice
+	ErrorMessage string `json:"error_message" avro:"error_message"` // error being reported
+	CreatedAt    string `json:"created_at" avro:"created_at"`       // time in RFC3339 format
 }
 
 // MessageBrokerCallbackFunc all broker clients should define this callback fn
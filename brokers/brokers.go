@@ -7,7 +7,10 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/eyewa/eyewa-go-lib/brokers/kafka"
+	"github.com/eyewa/eyewa-go-lib/brokers/memory"
 	"github.com/eyewa/eyewa-go-lib/brokers/rabbitmq"
+	"github.com/eyewa/eyewa-go-lib/brokers/sqs"
 	libErrs "github.com/eyewa/eyewa-go-lib/errors"
 )
 
@@ -15,6 +18,7 @@ const (
 	RabbitMQ BrokerType = "rabbitmq"
 	SQS      BrokerType = "sqs"
 	Kafka    BrokerType = "kafka"
+	Memory   BrokerType = "memory"
 )
 
 var (
@@ -31,6 +35,12 @@ func OpenConnection() (*MessageBrokerClient, error) {
 	switch strings.ToLower(os.Getenv("MESSAGE_BROKER")) {
 	case string(RabbitMQ):
 		broker = &MessageBrokerClient{RabbitMQ, new(rabbitmq.RMQClient), maxConnectionRetries}
+	case string(SQS):
+		broker = &MessageBrokerClient{SQS, sqs.NewSQSClient(), maxConnectionRetries}
+	case string(Kafka):
+		broker = &MessageBrokerClient{Kafka, kafka.NewKafkaClient(), maxConnectionRetries}
+	case string(Memory):
+		broker = &MessageBrokerClient{Memory, memory.NewMemoryClient(), maxConnectionRetries}
 	default:
 		broker = new(MessageBrokerClient)
 	}
@@ -84,6 +94,9 @@ func NewPublisherClient(brokerType BrokerType) *MessageBrokerPublisherClient {
 func getClient(brokerType BrokerType) MessageBroker {
 	clientMap := map[BrokerType]MessageBroker{
 		RabbitMQ: rabbitmq.NewRMQClient(),
+		SQS:      sqs.NewSQSClient(),
+		Kafka:    kafka.NewKafkaClient(),
+		Memory:   memory.NewMemoryClient(),
 		Mock:     NewMockClient(),
 	}
 
@@ -42,6 +42,16 @@ func TestOpenConnection(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestOpenMemoryConnection(t *testing.T) {
+	os.Setenv("MESSAGE_BROKER", "memory")
+	defer os.Setenv("MESSAGE_BROKER", "")
+
+	broker, err := OpenConnection()
+	assert.NoError(t, err)
+	assert.Equal(t, Memory, broker.Type)
+	assert.True(t, broker.Client.IsConnectionOpen())
+}
+
 func TestOpenConnectionFail(t *testing.T) {
 	var err error
 
@@ -66,6 +76,15 @@ func TestGetClient(t *testing.T) {
 	client = getClient(RabbitMQ)
 	assert.NotZero(t, client)
 
+	client = getClient(SQS)
+	assert.NotZero(t, client)
+
+	client = getClient(Kafka)
+	assert.NotZero(t, client)
+
+	client = getClient(Memory)
+	assert.NotZero(t, client)
+
 	client = getClient(Mock)
 	assert.NotZero(t, client)
 }
@@ -77,6 +96,10 @@ func TestNewConsumerClient(t *testing.T) {
 	assert.NotNil(t, client.Client)
 	assert.Equal(t, RabbitMQ, client.Type)
 
+	client = NewConsumerClient(SQS)
+	assert.NotNil(t, client.Client)
+	assert.Equal(t, SQS, client.Type)
+
 	client = NewConsumerClient(Mock)
 	assert.NotNil(t, client.Client)
 	assert.Equal(t, Mock, client.Type)
@@ -94,6 +117,10 @@ func TestNewPublisherClient(t *testing.T) {
 	assert.NotNil(t, pub.Client)
 	assert.Equal(t, RabbitMQ, pub.Type)
 
+	pub = NewPublisherClient(Kafka)
+	assert.NotNil(t, pub.Client)
+	assert.Equal(t, Kafka, pub.Type)
+
 	pub = NewPublisherClient(Mock)
 	assert.NotNil(t, pub.Client)
 	assert.Equal(t, Mock, pub.Type)
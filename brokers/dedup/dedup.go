@@ -0,0 +1,119 @@
+package dedup
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/ory/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	config          Config
+	standardMetrics *DedupMetrics
+	defaultTTL      = 24 * time.Hour
+)
+
+func initConfig() (Config, error) {
+	config = *new(Config)
+
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	envVars := []string{
+		"DEDUP_TTL",
+		"DEDUP_MEMORY_CAPACITY",
+	}
+
+	for _, v := range envVars {
+		if err := viper.BindEnv(v); err != nil {
+			return config, err
+		}
+	}
+
+	if err := viper.Unmarshal(&config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// New creates a deduplicator recording processed events in store.
+func New(store Store) (*Deduplicator, error) {
+	if _, err := initConfig(); err != nil {
+		return nil, err
+	}
+
+	standardMetrics = NewDedupMetrics()
+
+	ttl, err := time.ParseDuration(config.TTL)
+	if err != nil || ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	return &Deduplicator{
+		store: store,
+		TTL:   ttl,
+	}, nil
+}
+
+// Callback wraps a consumer callback so events already processed - by ID - are
+// acked without invoking it, and events it processes successfully are recorded.
+//
+// Should the store fail, events are processed regardless - at least once.
+// Redeliveries of an event being processed concurrently aren't detected.
+func (d *Deduplicator) Callback(callback base.MessageBrokerCallbackFunc) base.MessageBrokerCallbackFunc {
+	return deduplicate(d, func(event *base.EyewaEvent) (string, string) {
+		return event.ID, event.Name
+	}, callback)
+}
+
+// MagentoProductCallback wraps a magento product event consumer callback - see Callback.
+func (d *Deduplicator) MagentoProductCallback(callback base.MessageBrokerMagentoProductCallbackFunc) base.MessageBrokerMagentoProductCallbackFunc {
+	return deduplicate(d, func(event *base.MagentoProductEvent) (string, string) {
+		return event.ID, event.Name
+	}, callback)
+}
+
+// deduplicate wraps a callback of events identified by id.
+func deduplicate[T any, C ~func(context.Context, *T, error) error](d *Deduplicator, id func(*T) (string, string), callback C) C {
+	return func(ctx context.Context, event *T, err error) error {
+		// failures to consume are the callback's to handle
+		if err != nil || event == nil {
+			return callback(ctx, event, err)
+		}
+
+		eventID, name := id(event)
+		if eventID == "" {
+			return callback(ctx, event, err)
+		}
+
+		seen, errSeen := d.store.Seen(ctx, eventID)
+		if errSeen != nil {
+			go standardMetrics.StoreFailureCounter.Add(1)
+			log.Error(libErrs.ErrorDedupStoreFailure.Error(), zap.String("event", eventID), zap.Error(errSeen))
+		}
+
+		if seen {
+			go standardMetrics.DuplicateEventCounter.Add(1, attribute.String("event", name))
+			log.Debug("Skipped duplicate event.", zap.String("event", eventID), zap.String("name", name))
+			return nil
+		}
+
+		if err := callback(ctx, event, nil); err != nil {
+			return err
+		}
+
+		if err := d.store.Mark(ctx, eventID, d.TTL); err != nil {
+			go standardMetrics.StoreFailureCounter.Add(1)
+			log.Error(libErrs.ErrorDedupStoreFailure.Error(), zap.String("event", eventID), zap.Error(err))
+		}
+
+		return nil
+	}
+}
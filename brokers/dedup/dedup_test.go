@@ -0,0 +1,171 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "dedup.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	assert.Nil(t, Migrate(db))
+
+	return db
+}
+
+// failingStore a store that always fails.
+type failingStore struct{}
+
+func (failingStore) Seen(ctx context.Context, id string) (bool, error) {
+	return false, errors.New("store down")
+}
+
+func (failingStore) Mark(ctx context.Context, id string, ttl time.Duration) error {
+	return errors.New("store down")
+}
+
+func TestNewConfig(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	d, err := New(NewMemoryStore(0))
+	assert.Nil(t, err)
+	assert.Equal(t, defaultTTL, d.TTL)
+
+	os.Setenv("DEDUP_TTL", "1h")
+	os.Setenv("DEDUP_MEMORY_CAPACITY", "2")
+
+	d, err = New(NewMemoryStore(0))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Hour, d.TTL)
+	assert.Equal(t, 2, d.store.(*MemoryStore).capacity)
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	store := NewMemoryStore(2)
+	store.now = func() time.Time { return now }
+
+	assert.Nil(t, store.Mark(ctx, "1", time.Minute))
+	assert.Nil(t, store.Mark(ctx, "2", time.Minute))
+
+	seen, err := store.Seen(ctx, "1")
+	assert.Nil(t, err)
+	assert.True(t, seen)
+
+	// 2 is the least recently seen
+	assert.Nil(t, store.Mark(ctx, "3", time.Minute))
+	assert.Equal(t, 2, store.Len())
+
+	seen, _ = store.Seen(ctx, "2")
+	assert.False(t, seen)
+	seen, _ = store.Seen(ctx, "1")
+	assert.True(t, seen)
+
+	// expired
+	now = now.Add(2 * time.Minute)
+	seen, _ = store.Seen(ctx, "3")
+	assert.False(t, seen)
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewSQLStore(openDB(t))
+
+	seen, err := store.Seen(ctx, "1")
+	assert.Nil(t, err)
+	assert.False(t, seen)
+
+	assert.Nil(t, store.Mark(ctx, "1", time.Minute))
+	assert.Nil(t, store.Mark(ctx, "2", -time.Minute))
+
+	seen, err = store.Seen(ctx, "1")
+	assert.Nil(t, err)
+	assert.True(t, seen)
+
+	seen, err = store.Seen(ctx, "2")
+	assert.Nil(t, err)
+	assert.False(t, seen)
+
+	// marking again extends it
+	assert.Nil(t, store.Mark(ctx, "2", time.Minute))
+	seen, _ = store.Seen(ctx, "2")
+	assert.True(t, seen)
+
+	assert.Nil(t, store.Mark(ctx, "3", -time.Minute))
+	purged, err := store.Purge(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+func TestCallback(t *testing.T) {
+	ctx := context.Background()
+	errCallback := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		store   Store
+		event   *base.EyewaEvent
+		err     error
+		results []error // results of the callback's first calls - nil after
+		calls   int     // expected callback calls
+	}{
+		{"duplicates skipped", NewMemoryStore(10), &base.EyewaEvent{ID: "1"}, nil, nil, 1},
+		{"failed events redelivered", NewMemoryStore(10), &base.EyewaEvent{ID: "1"}, nil, []error{errCallback, errCallback}, 3},
+		{"events without ID not deduplicated", NewMemoryStore(10), &base.EyewaEvent{}, nil, nil, 4},
+		{"failures to consume passed on", NewMemoryStore(10), nil, errCallback, nil, 4},
+		{"store failing", failingStore{}, &base.EyewaEvent{ID: "1"}, nil, nil, 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := New(test.store)
+			assert.Nil(t, err)
+
+			calls := 0
+			callback := d.Callback(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+				calls++
+				if calls <= len(test.results) {
+					return test.results[calls-1]
+				}
+				return nil
+			})
+
+			for i := 0; i < 4; i++ {
+				_ = callback(ctx, test.event, test.err)
+			}
+
+			assert.Equal(t, test.calls, calls)
+		})
+	}
+}
+
+func TestMagentoProductCallback(t *testing.T) {
+	d, err := New(NewMemoryStore(10))
+	assert.Nil(t, err)
+
+	calls := 0
+	callback := d.MagentoProductCallback(func(ctx context.Context, event *base.MagentoProductEvent, err error) error {
+		calls++
+		return nil
+	})
+
+	event := &base.MagentoProductEvent{ID: "1", Name: "product.updated"}
+	assert.Nil(t, callback(context.Background(), event, nil))
+	assert.Nil(t, callback(context.Background(), event, nil))
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,89 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var defaultMemoryCapacity = 10000
+
+// NewMemoryStore creates an in-memory store remembering up to capacity event
+// IDs - or DEDUP_MEMORY_CAPACITY if capacity isn't positive.
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		if _, err := initConfig(); err == nil {
+			capacity, _ = strconv.Atoi(config.MemoryCapacity)
+		}
+	}
+
+	if capacity <= 0 {
+		capacity = defaultMemoryCapacity
+	}
+
+	return &MemoryStore{
+		mutex:    new(sync.Mutex),
+		capacity: capacity,
+		entries:  list.New(),
+		ids:      make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Seen reports if an event with id was processed and hasn't expired yet.
+func (s *MemoryStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.ids[id]
+	if !ok {
+		return false, nil
+	}
+
+	if s.now().After(element.Value.(*memoryEntry).expiresAt) {
+		s.remove(element)
+		return false, nil
+	}
+
+	s.entries.MoveToFront(element)
+
+	return true, nil
+}
+
+// Mark records an event with id as processed for ttl - evicting the least
+// recently seen event if the store is full.
+func (s *MemoryStore) Mark(ctx context.Context, id string, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiresAt := s.now().Add(ttl)
+
+	if element, ok := s.ids[id]; ok {
+		element.Value.(*memoryEntry).expiresAt = expiresAt
+		s.entries.MoveToFront(element)
+		return nil
+	}
+
+	s.ids[id] = s.entries.PushFront(&memoryEntry{id: id, expiresAt: expiresAt})
+
+	for s.entries.Len() > s.capacity {
+		s.remove(s.entries.Back())
+	}
+
+	return nil
+}
+
+// Len the no. of event IDs in the store.
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.entries.Len()
+}
+
+func (s *MemoryStore) remove(element *list.Element) {
+	s.entries.Remove(element)
+	delete(s.ids, element.Value.(*memoryEntry).id)
+}
@@ -0,0 +1,38 @@
+package dedup
+
+import (
+	"context"
+
+	"github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/metrics"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DedupMetrics is a collection of standard metrics
+type DedupMetrics struct {
+	DuplicateEventCounter *metrics.Counter
+	StoreFailureCounter   *metrics.Counter
+}
+
+// NewDedupMetrics creates a instance of DedupMetrics
+func NewDedupMetrics() *DedupMetrics {
+	meter := metrics.NewMeter("dedup.meter", context.Background())
+
+	duplicateEventCounter, err := meter.NewCounter("dedup.duplicate.event.counter",
+		metric.WithDescription("Counts duplicate events skipped"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	storeFailureCounter, err := meter.NewCounter("dedup.store.failure.counter",
+		metric.WithDescription("Counts failures to check/record processed events in the dedup store"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	return &DedupMetrics{
+		DuplicateEventCounter: duplicateEventCounter,
+		StoreFailureCounter:   storeFailureCounter,
+	}
+}
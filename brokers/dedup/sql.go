@@ -0,0 +1,49 @@
+package dedup
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TableName overrides the table name for ProcessedEvent
+func (ProcessedEvent) TableName() string {
+	return "processed_events"
+}
+
+// Migrate creates/updates the processed events table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&ProcessedEvent{})
+}
+
+// NewSQLStore creates a store of processed event IDs in db e.g the Gorm of a
+// db client opened with db.OpenConnection.
+func NewSQLStore(db *gorm.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Seen reports if an event with id was processed and hasn't expired yet.
+func (s *SQLStore) Seen(ctx context.Context, id string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&ProcessedEvent{}).
+		Where("event_id = ? AND expires_at > ?", id, time.Now()).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// Mark records an event with id as processed for ttl.
+func (s *SQLStore) Mark(ctx context.Context, id string, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&ProcessedEvent{EventID: id, ExpiresAt: time.Now().Add(ttl)}).Error
+}
+
+// Purge deletes expired event IDs, returning how many were deleted.
+func (s *SQLStore) Purge(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&ProcessedEvent{})
+	return result.RowsAffected, result.Error
+}
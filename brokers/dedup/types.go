@@ -0,0 +1,65 @@
+package dedup
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Config for all dedup env vars
+type Config struct {
+	// How long processed event IDs are remembered e.g 24h
+	TTL string `mapstructure:"dedup_ttl"`
+
+	// Max no. of event IDs an in-memory store remembers
+	MemoryCapacity string `mapstructure:"dedup_memory_capacity"`
+}
+
+// Store records the IDs of processed events.
+type Store interface {
+	// Seen reports if an event with id was processed and hasn't expired yet.
+	Seen(ctx context.Context, id string) (bool, error)
+
+	// Mark records an event with id as processed for ttl.
+	Mark(ctx context.Context, id string, ttl time.Duration) error
+}
+
+// Deduplicator skips events already processed by the callbacks it wraps.
+type Deduplicator struct {
+	store Store
+
+	TTL time.Duration
+}
+
+// MemoryStore an in-memory LRU store - remembering up to its capacity of
+// event IDs, forgetting the least recently seen first. Processed events are
+// only deduplicated within the process.
+type MemoryStore struct {
+	mutex *sync.Mutex
+
+	capacity int
+	entries  *list.List
+	ids      map[string]*list.Element
+
+	now func() time.Time
+}
+
+type memoryEntry struct {
+	id        string
+	expiresAt time.Time
+}
+
+// SQLStore a store of processed event IDs in a SQL db - shared by all
+// instances of a service.
+type SQLStore struct {
+	db *gorm.DB
+}
+
+// ProcessedEvent the ID of a processed event stored in the db.
+type ProcessedEvent struct {
+	EventID   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
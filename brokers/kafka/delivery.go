@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// acknowledger settles a base.Delivery by committing its offset with the
+// consumer group. Kafka has no notion of requeuing a single message.
+type acknowledger struct {
+	reader messageReader
+	msg    kafka.Message
+}
+
+// Ack commits the offset of the message.
+func (a acknowledger) Ack() error {
+	if err := a.reader.CommitMessages(context.Background(), a.msg); err != nil {
+		return fmt.Errorf("%s %s", libErrs.ErrorAckFailure.Error(), err)
+	}
+
+	return nil
+}
+
+// Nack commits the offset of the message so it is skipped. Requeuing is
+// not supported - the message is redelivered only if its offset is never
+// committed and the partition is reassigned.
+func (a acknowledger) Nack(requeue bool) error {
+	if requeue {
+		return libErrs.ErrorRequeueNotSupported
+	}
+
+	return a.Ack()
+}
+
+// Reject behaves like Nack as kafka makes no distinction between the two.
+func (a acknowledger) Reject(requeue bool) error {
+	return a.Nack(requeue)
+}
+
+// newDelivery maps a kafka message fetched by reader onto a base.Delivery.
+func newDelivery(reader messageReader, msg kafka.Message) base.Delivery {
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+
+	return base.Delivery{
+		Acknowledger: acknowledger{reader, msg},
+		MessageID:    fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset),
+		Headers:      headers,
+		ContentType:  "application/json",
+		Body:         msg.Value,
+		Queue:        msg.Topic,
+		RoutingKey:   string(msg.Key),
+		Timestamp:    msg.Time,
+	}
+}
+
+// kafkaHeaders maps the headers of a delivery back onto kafka headers.
+func kafkaHeaders(delivery base.Delivery) []kafka.Header {
+	headers := make([]kafka.Header, 0, len(delivery.Headers))
+	for k := range delivery.Headers {
+		headers = append(headers, kafka.Header{Key: k, Value: []byte(delivery.Header(k))})
+	}
+
+	return headers
+}
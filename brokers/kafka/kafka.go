@@ -0,0 +1,562 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	kafkatracing "github.com/eyewa/eyewa-go-lib/tracing/kafka"
+	"github.com/eyewa/eyewa-go-lib/utils"
+	"github.com/ory/viper"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// PartitionKeyEventID keys messages by the ID of the event
+	PartitionKeyEventID = "event_id"
+
+	// PartitionKeyEntityID keys messages by the ID of the entity the event is about
+	PartitionKeyEntityID = "entity_id"
+
+	// header identifying the type of event a message carries
+	eventTypeHeader = "x-type-of-event"
+)
+
+var (
+	config          Config
+	standardMetrics *KafkaMetrics
+	tracerName      = "github.com/eyewa/eyewa-go-lib/brokers/kafka"
+)
+
+func initConfig() (Config, error) {
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	envVars := []string{
+		"SERVICE_NAME",
+		"KAFKA_BROKERS",
+		"KAFKA_CONSUMER_GROUP",
+		"KAFKA_PARTITION_KEY",
+		"PUBLISHER_QUEUE_NAME",
+		"CONSUMER_QUEUE_NAME",
+		"MESSAGE_BROKER",
+	}
+
+	viper.SetDefault("KAFKA_PARTITION_KEY", PartitionKeyEventID)
+
+	for _, v := range envVars {
+		if err := viper.BindEnv(v); err != nil {
+			return config, err
+		}
+	}
+	if err := viper.Unmarshal(&config); err != nil {
+		return config, err
+	}
+
+	if config.ConsumerGroup == "" {
+		config.ConsumerGroup = config.ServiceName
+	}
+
+	return config, nil
+}
+
+// NewKafkaClient new kafka client
+func NewKafkaClient() *KafkaClient {
+	return &KafkaClient{
+		mutex:   new(sync.RWMutex),
+		readers: make(map[string]messageReader),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Connect sets up the writer and reader factory for the configured brokers.
+// Connections to the brokers themselves are established lazily by kafka-go.
+func (k *KafkaClient) Connect() error {
+	// init configs
+	if _, err := initConfig(); err != nil {
+		return err
+	}
+
+	// init metrics
+	standardMetrics = NewKafkaMetrics()
+
+	// if no topics are specified, back off.
+	if config.ConsumerQueueName == "" && config.PublisherQueueName == "" {
+		return libErrs.ErrorNoQueuesSpecified
+	}
+
+	if config.Brokers == "" {
+		return libErrs.ErrorNoKafkaBrokersSpecified
+	}
+
+	brokers := strings.Split(config.Brokers, ",")
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.writer == nil {
+		// messages sharing a key are hashed to the same partition
+		k.writer = &kafka.Writer{
+			Addr:                   kafka.TCP(brokers...),
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		}
+	}
+
+	if k.newReader == nil {
+		k.newReader = func(topic string) messageReader {
+			return kafka.NewReader(kafka.ReaderConfig{
+				Brokers: brokers,
+				GroupID: config.ConsumerGroup,
+				Topic:   topic,
+			})
+		}
+	}
+
+	k.closed = make(chan struct{})
+
+	return nil
+}
+
+// Consume consumes messages from a topic
+func (k *KafkaClient) Consume(queue string, callback base.MessageBrokerCallbackFunc) {
+	ctx := context.Background()
+	defer func() {
+		// reaching here means consumption meant to be long lived has stopped.
+		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
+	}()
+
+	err := k.consume(queue, "Kafka.Consume", false, func(ctx context.Context, delivery base.Delivery) (string, error) {
+		var event *base.EyewaEvent
+
+		if err := json.Unmarshal(delivery.Body, &event); err != nil {
+			go standardMetrics.UnmarshalEventFailureCounter.Add(1)
+			errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), queue, err)
+			_ = callback(ctx, nil, errMsg)
+			return "", errMsg
+		}
+
+		return event.Name, callback(ctx, event, nil)
+	})
+	if err != nil {
+		_ = callback(ctx, nil, err)
+	}
+}
+
+// ConsumeMagentoProductEvents consumes magento product events from a topic
+func (k *KafkaClient) ConsumeMagentoProductEvents(queue string, callback base.MessageBrokerMagentoProductCallbackFunc) {
+	ctx := context.Background()
+	defer func() {
+		// reaching here means consumption meant to be long lived has stopped.
+		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
+	}()
+
+	err := k.consume(queue, "Kafka.ConsumeMagentoProductEvents", true, func(ctx context.Context, delivery base.Delivery) (string, error) {
+		var event *base.MagentoProductEvent
+
+		if err := json.Unmarshal(delivery.Body, &event); err != nil {
+			go standardMetrics.UnmarshalEventFailureCounter.Add(1)
+			errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), queue, err)
+			_ = callback(ctx, nil, errMsg)
+			return "", errMsg
+		}
+
+		return event.Name, callback(ctx, event, nil)
+	})
+	if err != nil {
+		_ = callback(ctx, nil, err)
+	}
+}
+
+// consume fetches messages from a topic as part of the consumer group until
+// the client is closed or fetching fails. A message's offset is committed
+// once it has been processed successfully or has been deadlettered. If a
+// message can be neither, consuming stops without committing it so it is
+// redelivered to the group.
+func (k *KafkaClient) consume(topic, spanName string, magento bool, process processFunc) error {
+	reader, err := k.getReader(topic)
+	if err != nil {
+		return fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), topic, err)
+	}
+
+	// unblock any pending fetch once the client is closed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-k.done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	log.Info(fmt.Sprintf("Listening to %s for new messages...", topic))
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), topic, err)
+		}
+
+		delivery := newDelivery(reader, msg)
+		if magento {
+			// ensures base.MagentoProductEvent is deadlettered instead of base.EyewaEvent
+			delivery.Headers[eventTypeHeader] = "magento"
+		}
+
+		if err := k.handleDelivery(spanName, delivery, msg, process); err != nil {
+			return err
+		}
+	}
+}
+
+func (k *KafkaClient) handleDelivery(spanName string, delivery base.Delivery, msg kafka.Message, process processFunc) error {
+	started := time.Now()
+
+	// set kafka message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("KAFKA"),
+			semconv.MessagingDestinationKindKeyTopic,
+			semconv.MessagingDestinationKey.String(msg.Topic),
+			semconv.MessagingOperationReceive,
+			attribute.String("messaging.kafka.message_key", string(msg.Key)),
+			attribute.Int("messaging.kafka.partition", msg.Partition),
+			attribute.Int64("messaging.kafka.offset", msg.Offset)),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+	}
+
+	// extract context from headers, if none, the
+	// context will use the background context.
+	carrier := kafkatracing.NewHeaderCarrier(&msg.Headers)
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+
+	// start the span and and receive a new ctx containing the parent
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+	defer span.End()
+
+	go standardMetrics.ActiveConsumingEventCounter.Add(1)
+	defer func() {
+		go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
+		go standardMetrics.ActiveConsumingEventCounter.Add(-1)
+	}()
+
+	name, err := process(ctx, delivery)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		// publish message to DL
+		if errDL := k.sendToDeadletterQueue(ctx, delivery, err); errDL != nil {
+			go standardMetrics.DeadletterPublishFailureCounter.Add(1)
+			span.RecordError(errDL)
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errDL.Error())
+			return errDL
+		}
+	} else {
+		go standardMetrics.ConsumedEventCounter.Add(1, attribute.Any("event_name", name))
+	}
+
+	// commit offset
+	if err := delivery.Ack(); err != nil {
+		go standardMetrics.CommitFailureCounter.Add(1)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
+			err.Error(),
+			zap.String("topic", delivery.Queue),
+			zap.String("event", string(delivery.Body)))
+
+		return err
+	}
+
+	return nil
+}
+
+// Publish publishes a message to a topic. Priority is not supported by kafka
+// and is ignored.
+func (k *KafkaClient) Publish(ctx context.Context, queue string, priority int, event *base.EyewaEvent, callback base.MessageBrokerCallbackFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	eventJSON, err := json.Marshal(&event)
+	if err != nil {
+		go standardMetrics.MarshalEventFailureCounter.Add(1)
+		_ = callback(ctx, event, err)
+		return
+	}
+
+	ctx, err = k.publish(ctx, kafka.Message{
+		Topic: queue,
+		Key:   eyewaEventKey(event),
+		Value: eventJSON,
+	}, "Kafka.Publish")
+	if err != nil {
+		go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", event.Name))
+		_ = callback(ctx, event, libErrs.ErrorFailedToPublishEvent)
+		return
+	}
+
+	go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", event.Name))
+	_ = callback(ctx, event, nil)
+}
+
+// PublishMagentoProductEvent publishes a magento product event to a topic.
+// Priority is not supported by kafka and is ignored.
+func (k *KafkaClient) PublishMagentoProductEvent(ctx context.Context, queue string, priority int, event *base.MagentoProductEvent, callback base.MessageBrokerMagentoProductCallbackFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	eventJSON, err := json.Marshal(&event)
+	if err != nil {
+		go standardMetrics.MarshalEventFailureCounter.Add(1)
+		_ = callback(ctx, event, err)
+		return
+	}
+
+	ctx, err = k.publish(ctx, kafka.Message{
+		Topic:   queue,
+		Key:     magentoProductEventKey(event),
+		Value:   eventJSON,
+		Headers: []kafka.Header{{Key: eventTypeHeader, Value: []byte("magento")}},
+	}, "Kafka.PublishMagentoProductEvent")
+	if err != nil {
+		go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", event.Name))
+		_ = callback(ctx, event, libErrs.ErrorFailedToPublishEvent)
+		return
+	}
+
+	go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", event.Name))
+	_ = callback(ctx, event, nil)
+}
+
+// PublishEvent publishes any event structure to a topic. The message has no
+// key so it is spread across partitions. Clients choose to handle publishing errors.
+func (k *KafkaClient) PublishEvent(ctx context.Context, queue string, priority int, event *[]byte, wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	if event == nil {
+		return errors.New("Event is empty!")
+	}
+
+	_, err := k.publish(ctx, kafka.Message{Topic: queue, Value: *event}, "Kafka.PublishEvent")
+	return err
+}
+
+func (k *KafkaClient) publish(ctx context.Context, msg kafka.Message, spanName string) (context.Context, error) {
+	k.mutex.RLock()
+	writer := k.writer
+	k.mutex.RUnlock()
+
+	if writer == nil {
+		return ctx, libErrs.ErrorNoKafkaConnection
+	}
+
+	// set kafka message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("KAFKA"),
+			semconv.MessagingDestinationKindKeyTopic,
+			semconv.MessagingDestinationKey.String(msg.Topic),
+			attribute.String("messaging.kafka.message_key", string(msg.Key))),
+		trace.WithSpanKind(trace.SpanKindProducer),
+	}
+
+	// start the span and and receive a new ctx containing the parent
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+	defer span.End()
+
+	// inject context into headers
+	carrier := kafkatracing.NewHeaderCarrier(&msg.Headers)
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	if err := writer.WriteMessages(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return ctx, err
+	}
+
+	return ctx, nil
+}
+
+// SendToDeadletterQueue publishes a failed delivery to the deadletter topic
+// of the topic it was received from. The message's errors are replaced by eventErr.
+func (k *KafkaClient) SendToDeadletterQueue(msg base.Delivery, eventErr error) error {
+	return k.sendToDeadletterQueue(context.Background(), msg, eventErr)
+}
+
+func (k *KafkaClient) sendToDeadletterQueue(ctx context.Context, msg base.Delivery, eventErr error) error {
+	eventErrors := []base.Error{
+		{
+			ErrorMessage: eventErr.Error(),
+			CreatedAt:    utils.NowRFC3339(),
+		},
+	}
+
+	var (
+		eventData []byte
+		err       error
+	)
+
+	if msg.Header(eventTypeHeader) == "magento" {
+		var event *base.MagentoProductEvent
+		if err = json.Unmarshal(msg.Body, &event); err != nil {
+			// not an event - deadletter it as is so it is not lost
+			eventData, err = msg.Body, nil
+		} else {
+			event.Errors = eventErrors
+			eventData, err = json.Marshal(event)
+		}
+	} else {
+		var event *base.EyewaEvent
+		if err = json.Unmarshal(msg.Body, &event); err != nil {
+			// not an event - deadletter it as is so it is not lost
+			eventData, err = msg.Body, nil
+		} else {
+			event.Errors = eventErrors
+			eventData, err = json.Marshal(event)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	topic := msg.Queue
+	if topic == "" {
+		topic = config.ConsumerQueueName
+	}
+
+	deadletterTopic := deadletterTopicName(topic)
+	_, err = k.publish(ctx, kafka.Message{
+		Topic:   deadletterTopic,
+		Key:     []byte(msg.RoutingKey),
+		Value:   eventData,
+		Headers: kafkaHeaders(msg),
+	}, "Kafka.SendToDeadletterQueue")
+	if err != nil {
+		log.Error(libErrs.ErrorFailedToPublishToDeadletter.Error(),
+			zap.String("event", string(eventData)),
+			zap.String("deadletter_topic", deadletterTopic), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// getReader returns the consumer group reader for a topic, creating it if need be.
+func (k *KafkaClient) getReader(topic string) (messageReader, error) {
+	if topic == "" {
+		return nil, libErrs.ErrorNoConsumerQueueSpecified
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.newReader == nil {
+		return nil, libErrs.ErrorNoKafkaConnection
+	}
+
+	reader, exists := k.readers[topic]
+	if !exists {
+		reader = k.newReader(topic)
+		k.readers[topic] = reader
+	}
+
+	return reader, nil
+}
+
+// CloseConnection stops any active consumers and closes all readers as well
+// as the writer, flushing any pending messages.
+func (k *KafkaClient) CloseConnection() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	select {
+	case <-k.closed:
+	default:
+		close(k.closed)
+	}
+
+	var err error
+	for topic, reader := range k.readers {
+		if errClose := reader.Close(); errClose != nil {
+			err = errClose
+		}
+		delete(k.readers, topic)
+	}
+
+	if k.writer != nil {
+		if errClose := k.writer.Close(); errClose != nil {
+			err = errClose
+		}
+		k.writer = nil
+	}
+
+	return err
+}
+
+// ConnectionListener is a no-op for kafka. kafka-go manages and re-dials
+// broker connections internally, consumers are notified via their
+// callback should fetching from a topic fail.
+func (k *KafkaClient) ConnectionListener() {}
+
+// IsConnectionOpen reports if the client has been connected and not closed since.
+func (k *KafkaClient) IsConnectionOpen() bool {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.writer != nil
+}
+
+func (k *KafkaClient) done() <-chan struct{} {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.closed
+}
+
+// eyewaEventKey keys an event by its ID or, if configured, by the ID of the
+// entity in its payload. Falls back to the event ID if the payload has none.
+func eyewaEventKey(event *base.EyewaEvent) []byte {
+	if config.PartitionKey == PartitionKeyEntityID {
+		var payload struct {
+			EntityID *int `json:"id"`
+		}
+
+		if err := json.Unmarshal(event.Payload, &payload); err == nil && payload.EntityID != nil {
+			return []byte(strconv.Itoa(*payload.EntityID))
+		}
+	}
+
+	return []byte(event.ID)
+}
+
+// magentoProductEventKey keys an event by its ID or, if configured, by its entity ID.
+func magentoProductEventKey(event *base.MagentoProductEvent) []byte {
+	if config.PartitionKey == PartitionKeyEntityID {
+		return []byte(strconv.Itoa(event.EntityID))
+	}
+
+	return []byte(event.ID)
+}
+
+func deadletterTopicName(topic string) string {
+	return fmt.Sprintf("%s-%s", "deadletter", topic)
+}
@@ -0,0 +1,212 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeReader hands out a fixed set of messages then blocks until cancelled.
+type fakeReader struct {
+	mutex     sync.Mutex
+	messages  []kafka.Message
+	committed []int64
+	closed    bool
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mutex.Lock()
+	if len(r.messages) > 0 {
+		msg := r.messages[0]
+		r.messages = r.messages[1:]
+		r.mutex.Unlock()
+		return msg, nil
+	}
+	r.mutex.Unlock()
+
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, msg := range msgs {
+		r.committed = append(r.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	return nil
+}
+
+// fakeWriter records written messages, failing writes to failTopic.
+type fakeWriter struct {
+	mutex     sync.Mutex
+	written   []kafka.Message
+	failTopic string
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, msg := range msgs {
+		if msg.Topic == w.failTopic {
+			return errors.New("leader not available")
+		}
+	}
+
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func connectFakeClient(t *testing.T, reader *fakeReader) (*KafkaClient, *fakeWriter) {
+	os.Clearenv()
+	os.Setenv("KAFKA_BROKERS", "localhost:9092")
+	os.Setenv("CONSUMER_QUEUE_NAME", "catalog")
+	os.Setenv("SERVICE_NAME", "catalogconsumer")
+
+	writer := new(fakeWriter)
+	client := NewKafkaClient()
+	client.writer = writer
+	client.newReader = func(topic string) messageReader {
+		return reader
+	}
+
+	assert.NoError(t, client.Connect())
+	return client, writer
+}
+
+func TestConnectionConfig(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("KAFKA_BROKERS", "localhost:9092,localhost:9093")
+	os.Setenv("SERVICE_NAME", "catalogconsumer")
+
+	cfg, err := initConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "localhost:9092,localhost:9093", cfg.Brokers)
+	assert.Equal(t, "catalogconsumer", cfg.ConsumerGroup)
+	assert.Equal(t, PartitionKeyEventID, cfg.PartitionKey)
+
+	os.Clearenv()
+}
+
+func TestConnectNoBrokers(t *testing.T) {
+	os.Clearenv()
+	config = Config{}
+	os.Setenv("CONSUMER_QUEUE_NAME", "catalog")
+
+	client := NewKafkaClient()
+	assert.Equal(t, libErrs.ErrorNoKafkaBrokersSpecified, client.Connect())
+	assert.False(t, client.IsConnectionOpen())
+
+	os.Clearenv()
+}
+
+func TestPublish(t *testing.T) {
+	client, writer := connectFakeClient(t, new(fakeReader))
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+
+	event := &base.EyewaEvent{ID: "abc", Name: "product.created", Payload: []byte(`{"id": 42}`)}
+	client.Publish(context.Background(), "catalog", 0, event, func(ctx context.Context, e *base.EyewaEvent, err error) error {
+		assert.NoError(t, err)
+		return nil
+	}, wg)
+	wg.Wait()
+
+	assert.Len(t, writer.written, 1)
+	assert.Equal(t, "catalog", writer.written[0].Topic)
+	assert.Equal(t, []byte("abc"), writer.written[0].Key)
+
+	config.PartitionKey = PartitionKeyEntityID
+	assert.Equal(t, []byte("42"), eyewaEventKey(event))
+	assert.Equal(t, []byte("7"), magentoProductEventKey(&base.MagentoProductEvent{ID: "abc", EntityID: 7}))
+}
+
+func TestConsume(t *testing.T) {
+	reader := &fakeReader{
+		messages: []kafka.Message{
+			{Topic: "catalog", Offset: 1, Value: []byte(`{"id":"1","name":"product.created"}`)},
+			{Topic: "catalog", Offset: 2, Value: []byte(`{"id":"2","name":"product.deleted"}`)},
+		},
+	}
+	client, writer := connectFakeClient(t, reader)
+
+	var consumed []string
+	closed := make(chan struct{})
+	client.Consume("catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if event == nil {
+			return nil
+		}
+
+		consumed = append(consumed, event.ID)
+		if event.ID == "2" {
+			// stop consuming once the last message is processed
+			go func() {
+				_ = client.CloseConnection()
+				close(closed)
+			}()
+			return fmt.Errorf("failed to persist")
+		}
+		return nil
+	})
+
+	// Consume returns once closing starts - wait for it to finish
+	<-closed
+
+	assert.Equal(t, []string{"1", "2"}, consumed)
+	assert.Equal(t, []int64{1, 2}, reader.committed)
+	assert.True(t, reader.closed)
+
+	assert.Len(t, writer.written, 1)
+	assert.Equal(t, "deadletter-catalog", writer.written[0].Topic)
+
+	var event base.EyewaEvent
+	assert.NoError(t, json.Unmarshal(writer.written[0].Value, &event))
+	assert.Equal(t, "failed to persist", event.Errors[0].ErrorMessage)
+}
+
+func TestConsumeStopsWhenDeadletterFails(t *testing.T) {
+	reader := &fakeReader{
+		messages: []kafka.Message{
+			{Topic: "catalog", Offset: 1, Value: []byte(`{"id":"1","name":"product.created"}`)},
+		},
+	}
+	client, writer := connectFakeClient(t, reader)
+	writer.failTopic = "deadletter-catalog"
+
+	var errs []error
+	client.Consume("catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if event == nil {
+			errs = append(errs, err)
+			return nil
+		}
+		return fmt.Errorf("failed to persist")
+	})
+
+	// offset is left uncommitted so the message is redelivered to the group
+	assert.Empty(t, reader.committed)
+	assert.Equal(t, libErrs.ErrorLostConnectionToMessageBroker, errs[len(errs)-1])
+}
@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/unit"
+
+	"github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/metrics"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// KafkaMetrics is a collection of standard metrics
+type KafkaMetrics struct {
+	PublishedEventCounter           *metrics.Counter
+	PublishEventFailureCounter      *metrics.Counter
+	ConsumedEventCounter            *metrics.Counter
+	UnmarshalEventFailureCounter    *metrics.Counter
+	MarshalEventFailureCounter      *metrics.Counter
+	CommitFailureCounter            *metrics.Counter
+	DeadletterPublishFailureCounter *metrics.Counter
+	ActiveConsumingEventCounter     *metrics.UpDownCounter
+	ConsumedEventLatencyRecorder    *metrics.ValueRecorder
+}
+
+// NewKafkaMetrics creates a instance of KafkaMetrics
+func NewKafkaMetrics() *KafkaMetrics {
+	meter := metrics.NewMeter("kafka.meter", context.Background())
+
+	publishedEventCounter, err := meter.NewCounter("kafka.published.event.counter",
+		metric.WithDescription("Counts published events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	publishEventFailureCounter, err := meter.NewCounter("kafka.publish.event.failure.counter",
+		metric.WithDescription("Counts failed published events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	consumedEventCounter, err := meter.NewCounter("kafka.consumed.event.counter",
+		metric.WithDescription("Counts consumed events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	marshalEventFailureCounter, err := meter.NewCounter("kafka.marshal.event.failure.counter",
+		metric.WithDescription("Counts marshal event failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	unmarshalEventFailureCounter, err := meter.NewCounter("kafka.unmarshal.event.failure.counter",
+		metric.WithDescription("Counts unmarshal event failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	commitFailureCounter, err := meter.NewCounter("kafka.commit.failure.counter",
+		metric.WithDescription("Counts offset commit failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	deadletterPublishFailureCounter, err := meter.NewCounter("kafka.deadletter.publish.failure.counter",
+		metric.WithDescription("Counts deadletter publishing failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	activeConsumingEventCounter, err := meter.NewUpDownCounter("kafka.active.consuming.event.counter",
+		metric.WithDescription("Counts active consuming events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	consumedEventLatencyRecorder, err := meter.NewValueRecorder("kafka.consumed.event.latency.recorder",
+		metric.WithUnit(unit.Milliseconds),
+		metric.WithDescription("Records consumed event latency"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	return &KafkaMetrics{
+		PublishedEventCounter:           publishedEventCounter,
+		PublishEventFailureCounter:      publishEventFailureCounter,
+		ConsumedEventCounter:            consumedEventCounter,
+		MarshalEventFailureCounter:      marshalEventFailureCounter,
+		UnmarshalEventFailureCounter:    unmarshalEventFailureCounter,
+		CommitFailureCounter:            commitFailureCounter,
+		DeadletterPublishFailureCounter: deadletterPublishFailureCounter,
+		ActiveConsumingEventCounter:     activeConsumingEventCounter,
+		ConsumedEventLatencyRecorder:    consumedEventLatencyRecorder,
+	}
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/segmentio/kafka-go"
+)
+
+// Config for all Kafka env vars
+type Config struct {
+	MessageBroker string `mapstructure:"message_broker"`
+
+	// Comma separated list of kafka brokers - host:port
+	Brokers string `mapstructure:"kafka_brokers"`
+
+	// Consumer group consumers join. Defaults to SERVICE_NAME.
+	ConsumerGroup string `mapstructure:"kafka_consumer_group"`
+
+	// Event field used as the message key - event_id|entity_id. Messages
+	// sharing a key land on the same partition and are consumed in order.
+	PartitionKey string `mapstructure:"kafka_partition_key"`
+
+	// Topics for consuming + publishing
+	PublisherQueueName string `mapstructure:"publisher_queue_name"`
+	ConsumerQueueName  string `mapstructure:"consumer_queue_name"`
+
+	// Purely for identifying what service/service instance is connected
+	ServiceName string `mapstructure:"service_name"`
+}
+
+// KafkaClient Kafka client for implementing the MessageBroker interface and handling all things Kafka.
+type KafkaClient struct {
+	mutex *sync.RWMutex
+
+	writer messageWriter
+
+	// Map of readers for all consumed topics
+	readers map[string]messageReader
+
+	// creates a reader for a topic - swappable for tests
+	newReader func(topic string) messageReader
+
+	// closed once CloseConnection is called to stop consuming
+	closed chan struct{}
+}
+
+// messageReader the subset of *kafka.Reader used by the client.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// messageWriter the subset of *kafka.Writer used by the client.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// processFunc is invoked for every delivery fetched. It returns the name
+// of the event for metrics and an error if the message should be deadlettered.
+type processFunc func(ctx context.Context, delivery base.Delivery) (string, error)
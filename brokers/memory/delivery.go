@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+)
+
+// acknowledger settles a base.Delivery with the queue it was delivered from.
+type acknowledger struct {
+	client *MemoryClient
+	queue  string
+	tag    uint64
+}
+
+// Ack removes the message from the queue.
+func (a acknowledger) Ack() error {
+	if !a.client.settle(a.queue, a.tag, false) {
+		return fmt.Errorf("%s %s", libErrs.ErrorAckFailure.Error(), libErrs.ErrorDeliveryNotAcknowledgeable)
+	}
+
+	return nil
+}
+
+// Nack removes the message from the queue or, if requeue is true, returns it
+// to the queue to be redelivered.
+func (a acknowledger) Nack(requeue bool) error {
+	if !a.client.settle(a.queue, a.tag, requeue) {
+		return fmt.Errorf("%s %s", libErrs.ErrorNackFailure.Error(), libErrs.ErrorDeliveryNotAcknowledgeable)
+	}
+
+	return nil
+}
+
+// Reject behaves like Nack as there's no distinction between the two in memory.
+func (a acknowledger) Reject(requeue bool) error {
+	return a.Nack(requeue)
+}
+
+// newDelivery maps a message delivered with tag onto a base.Delivery.
+func newDelivery(client *MemoryClient, msg Message, tag uint64) base.Delivery {
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	return base.Delivery{
+		Acknowledger:    acknowledger{client, msg.Queue, tag},
+		MessageID:       msg.ID,
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		Body:            msg.Body,
+		Queue:           msg.Queue,
+		RoutingKey:      msg.Queue,
+		Priority:        msg.Priority,
+		Redelivered:     msg.Redelivered,
+		RedeliveryCount: msg.DeliveryCount - 1,
+		Timestamp:       msg.Timestamp,
+	}
+}
+
+// headerCarrier carries trace context in the headers of a message.
+type headerCarrier map[string]interface{}
+
+// Get returns the value of a header - empty if not a string.
+func (c headerCarrier) Get(key string) string {
+	if v, ok := c[key].(string); ok {
+		return v
+	}
+
+	return ""
+}
+
+// Set sets a header.
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys lists the headers.
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
@@ -0,0 +1,432 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	tracerName      = "github.com/eyewa/eyewa-go-lib/brokers/memory"
+	eventTypeHeader = "x-type-of-event"
+	contentTypeJSON = "application/json"
+)
+
+// NewMemoryClient creates an in-memory broker client.
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		mutex:  new(sync.Mutex),
+		queues: make(map[string]*queue),
+		closed: make(chan struct{}),
+	}
+}
+
+// Connect connects the client - there's nothing to connect to, so it never fails.
+func (m *MemoryClient) Connect() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.connected {
+		m.connected = true
+		m.closed = make(chan struct{})
+	}
+
+	return nil
+}
+
+// CloseConnection stops any active consumers. Messages in the queues are kept
+// - reconnecting the client delivers them to new consumers.
+func (m *MemoryClient) CloseConnection() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.connected {
+		m.connected = false
+		close(m.closed)
+	}
+
+	return nil
+}
+
+// ConnectionListener is a no-op - an in-memory connection is never lost.
+func (m *MemoryClient) ConnectionListener() {}
+
+// IsConnectionOpen reports if the client has been connected and not closed since.
+func (m *MemoryClient) IsConnectionOpen() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.connected
+}
+
+// Consume consumes events from a queue until the connection is closed.
+func (m *MemoryClient) Consume(queue string, callback base.MessageBrokerCallbackFunc) {
+	m.ConsumeWithContext(context.Background(), queue, callback)
+}
+
+// ConsumeWithContext consumes events from a queue until ctx is done or the
+// connection is closed.
+func (m *MemoryClient) ConsumeWithContext(ctx context.Context, queue string, callback base.MessageBrokerCallbackFunc) {
+	m.run(ctx, queue, "Memory.Consume", false, func(err error) { _ = callback(ctx, nil, err) },
+		func(ctx context.Context, delivery base.Delivery) (string, error) {
+			var event *base.EyewaEvent
+
+			if err := json.Unmarshal(delivery.Body, &event); err != nil {
+				errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), queue, err)
+				_ = callback(ctx, nil, errMsg)
+				return "", errMsg
+			}
+
+			return event.Name, callback(ctx, event, nil)
+		})
+}
+
+// ConsumeMagentoProductEvents consumes magento product events from a queue
+// until the connection is closed.
+func (m *MemoryClient) ConsumeMagentoProductEvents(queue string, callback base.MessageBrokerMagentoProductCallbackFunc) {
+	m.ConsumeMagentoProductEventsWithContext(context.Background(), queue, callback)
+}
+
+// ConsumeMagentoProductEventsWithContext consumes magento product events from
+// a queue until ctx is done or the connection is closed.
+func (m *MemoryClient) ConsumeMagentoProductEventsWithContext(ctx context.Context, queue string, callback base.MessageBrokerMagentoProductCallbackFunc) {
+	m.run(ctx, queue, "Memory.ConsumeMagentoProductEvents", true, func(err error) { _ = callback(ctx, nil, err) },
+		func(ctx context.Context, delivery base.Delivery) (string, error) {
+			var event *base.MagentoProductEvent
+
+			if err := json.Unmarshal(delivery.Body, &event); err != nil {
+				errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), queue, err)
+				_ = callback(ctx, nil, errMsg)
+				return "", errMsg
+			}
+
+			return event.Name, callback(ctx, event, nil)
+		})
+}
+
+// run consumes from a queue, notifying the consumer once it stops.
+func (m *MemoryClient) run(ctx context.Context, queue, spanName string, magento bool, stopped func(error), process processFunc) {
+	err := m.consume(ctx, queue, spanName, magento, process)
+	switch {
+	case err != nil:
+		stopped(err)
+	case ctx.Err() != nil:
+		stopped(libErrs.ErrorConsumerStopped)
+	default:
+		// reaching here means the connection was closed.
+		stopped(libErrs.ErrorLostConnectionToMessageBroker)
+	}
+}
+
+// consume delivers the messages of a queue one at a time until ctx is done or
+// the connection is closed. A message is acked once processed successfully, or
+// deadlettered and acked if it fails.
+func (m *MemoryClient) consume(ctx context.Context, queue, spanName string, magento bool, process processFunc) error {
+	if queue == "" {
+		return libErrs.ErrorNoConsumerQueueSpecified
+	}
+
+	if !m.IsConnectionOpen() {
+		return libErrs.ErrorNoMemoryBrokerConnection
+	}
+
+	log.Info(fmt.Sprintf("Listening to %s for new messages...", queue))
+
+	for {
+		delivery, ok := m.next(ctx, queue)
+		if !ok {
+			return nil
+		}
+
+		if magento {
+			// ensures base.MagentoProductEvent is deadlettered instead of base.EyewaEvent
+			delivery.Headers[eventTypeHeader] = "magento"
+		}
+
+		m.handleDelivery(spanName, delivery, process)
+	}
+}
+
+// next waits for the next message of a queue to be delivered - false once
+// ctx is done or the connection is closed.
+func (m *MemoryClient) next(ctx context.Context, queueName string) (base.Delivery, bool) {
+	for {
+		m.mutex.Lock()
+		closed := m.closed
+		q := m.queue(queueName)
+
+		if m.connected {
+			if e, ok := q.pop(m.deliveries + 1); ok {
+				m.deliveries++
+				delivery := newDelivery(m, e.Message, m.deliveries)
+				m.mutex.Unlock()
+				return delivery, true
+			}
+		}
+
+		changed := q.changed
+		m.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-closed:
+			return base.Delivery{}, false
+		case <-ctx.Done():
+			return base.Delivery{}, false
+		}
+	}
+}
+
+func (m *MemoryClient) handleDelivery(spanName string, delivery base.Delivery, process processFunc) {
+	// set message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("MEMORY"),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingDestinationKey.String(delivery.Queue),
+			semconv.MessagingOperationReceive,
+			semconv.MessagingMessageIDKey.String(delivery.MessageID)),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+	}
+
+	// extract context from headers, if none, the
+	// context will use the background context.
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), headerCarrier(delivery.Headers))
+
+	// start the span and and receive a new ctx containing the parent
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+	defer span.End()
+
+	if _, err := process(ctx, delivery); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		if errDL := m.sendToDeadletterQueue(ctx, delivery, err); errDL != nil {
+			span.RecordError(errDL)
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errDL.Error())
+
+			// keep the message rather than lose it
+			_ = delivery.Nack(true)
+			return
+		}
+	}
+
+	_ = delivery.Ack()
+}
+
+// Publish publishes an event to a queue.
+func (m *MemoryClient) Publish(ctx context.Context, queue string, priority int, event *base.EyewaEvent, callback base.MessageBrokerCallbackFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	eventJSON, err := json.Marshal(&event)
+	if err != nil {
+		_ = callback(ctx, event, err)
+		return
+	}
+
+	ctx, err = m.publish(ctx, queue, priority, eventJSON, nil, "Memory.Publish")
+	_ = callback(ctx, event, err)
+}
+
+// PublishMagentoProductEvent publishes a magento product event to a queue.
+func (m *MemoryClient) PublishMagentoProductEvent(ctx context.Context, queue string, priority int, event *base.MagentoProductEvent, callback base.MessageBrokerMagentoProductCallbackFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	eventJSON, err := json.Marshal(&event)
+	if err != nil {
+		_ = callback(ctx, event, err)
+		return
+	}
+
+	ctx, err = m.publish(ctx, queue, priority, eventJSON, map[string]interface{}{eventTypeHeader: "magento"}, "Memory.PublishMagentoProductEvent")
+	_ = callback(ctx, event, err)
+}
+
+// PublishEvent publishes any event to a queue.
+func (m *MemoryClient) PublishEvent(ctx context.Context, queue string, priority int, event *[]byte, wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	if event == nil {
+		return errors.New("Event is empty!")
+	}
+
+	_, err := m.publish(ctx, queue, priority, *event, nil, "Memory.PublishEvent")
+	return err
+}
+
+// publish publishes a message to a queue with the trace context of ctx in its headers.
+func (m *MemoryClient) publish(ctx context.Context, queueName string, priority int, body []byte, headers map[string]interface{}, spanName string) (context.Context, error) {
+	if queueName == "" {
+		return ctx, libErrs.ErrorNoPublisherQueueSpecified
+	}
+
+	// set message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("MEMORY"),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingDestinationKey.String(queueName)),
+		trace.WithSpanKind(trace.SpanKindProducer),
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+	defer span.End()
+
+	carrier := make(headerCarrier, len(headers)+2)
+	for k, v := range headers {
+		carrier[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.connected {
+		span.RecordError(libErrs.ErrorNoMemoryBrokerConnection)
+		span.SetStatus(codes.Error, libErrs.ErrorNoMemoryBrokerConnection.Error())
+		return ctx, libErrs.ErrorNoMemoryBrokerConnection
+	}
+
+	if priority < 0 {
+		priority = 0
+	}
+
+	m.seq++
+	msg := Message{
+		ID:          strconv.FormatUint(m.seq, 10),
+		Queue:       queueName,
+		Headers:     carrier,
+		ContentType: contentTypeJSON,
+		Body:        body,
+		Priority:    uint8(priority),
+		Timestamp:   time.Now(),
+	}
+
+	q := m.queue(queueName)
+	q.published = append(q.published, msg)
+	q.push(&entry{Message: msg, seq: m.seq})
+
+	return ctx, nil
+}
+
+// SendToDeadletterQueue publishes a failed delivery to the deadletter queue of
+// the queue it was consumed from, appending eventErr to the errors of the event.
+func (m *MemoryClient) SendToDeadletterQueue(msg base.Delivery, eventErr error) error {
+	return m.sendToDeadletterQueue(context.Background(), msg, eventErr)
+}
+
+func (m *MemoryClient) sendToDeadletterQueue(ctx context.Context, msg base.Delivery, eventErr error) error {
+	eventError := base.Error{
+		ErrorMessage: eventErr.Error(),
+		CreatedAt:    utils.NowRFC3339(),
+	}
+
+	var event base.ErrorRecorder = new(base.EyewaEvent)
+	if msg.Header(eventTypeHeader) == "magento" {
+		event = new(base.MagentoProductEvent)
+	}
+
+	// not an event - deadletter it as is so it is not lost
+	eventData := msg.Body
+	if err := json.Unmarshal(msg.Body, event); err == nil {
+		event.RecordErrors(eventError)
+		if eventData, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	deadletterQueue := deadletterQueueName(msg.Queue)
+	if _, err := m.publish(ctx, deadletterQueue, int(msg.Priority), eventData, headers, "Memory.SendToDeadletterQueue"); err != nil {
+		log.Error(libErrs.ErrorFailedToPublishToDeadletter.Error(),
+			zap.String("event", string(eventData)),
+			zap.String("deadletter_queue", deadletterQueue), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// settle settles a delivery of a queue - false if it was already settled.
+func (m *MemoryClient) settle(queueName string, tag uint64, requeue bool) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.queue(queueName).settle(tag, requeue)
+}
+
+// queue gets a queue, declaring it if need be. The mutex must be held.
+func (m *MemoryClient) queue(name string) *queue {
+	q, exists := m.queues[name]
+	if !exists {
+		q = newQueue()
+		m.queues[name] = q
+	}
+
+	return q
+}
+
+// Published every message published to a queue, in the order published -
+// including those consumed since.
+func (m *MemoryClient) Published(queue string) []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]Message(nil), m.queue(queue).published...)
+}
+
+// Deadlettered every message deadlettered from a queue.
+func (m *MemoryClient) Deadlettered(queue string) []Message {
+	return m.Published(deadletterQueueName(queue))
+}
+
+// Pending no. of messages of a queue not yet consumed or not yet settled.
+func (m *MemoryClient) Pending(queue string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.queue(queue).pending()
+}
+
+// Wait waits for every message of a queue to be consumed and settled, or ctx to be done.
+func (m *MemoryClient) Wait(ctx context.Context, queueName string) error {
+	for {
+		m.mutex.Lock()
+		q := m.queue(queueName)
+		pending, changed := q.pending(), q.changed
+		m.mutex.Unlock()
+
+		if pending == 0 {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func deadletterQueueName(queue string) string {
+	return fmt.Sprintf("%s-%s", "deadletter", queue)
+}
@@ -0,0 +1,263 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func connectedClient(t *testing.T) *MemoryClient {
+	log.SetLogLevel()
+	client := NewMemoryClient()
+	assert.Nil(t, client.Connect())
+
+	return client
+}
+
+func publish(t *testing.T, client *MemoryClient, queue string, priority int, event *base.EyewaEvent) {
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	client.Publish(context.Background(), queue, priority, event, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		assert.Nil(t, err)
+		return nil
+	}, wg)
+	wg.Wait()
+}
+
+// consume consumes from queue until the test ends.
+func consume(t *testing.T, client *MemoryClient, queue string, callback base.MessageBrokerCallbackFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	go func() {
+		defer close(done)
+		client.ConsumeWithContext(ctx, queue, callback)
+	}()
+}
+
+func wait(t *testing.T, client *MemoryClient, queue string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, client.Wait(ctx, queue))
+}
+
+func TestPublishAndConsume(t *testing.T) {
+	client := connectedClient(t)
+
+	publish(t, client, "catalog", 0, &base.EyewaEvent{ID: "1", Name: "product.created"})
+	publish(t, client, "catalog", 0, &base.EyewaEvent{ID: "2", Name: "product.updated"})
+	assert.Len(t, client.Published("catalog"), 2)
+	assert.Equal(t, 2, client.Pending("catalog"))
+
+	var (
+		mutex    sync.Mutex
+		consumed []string
+	)
+	consume(t, client, "catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		consumed = append(consumed, event.ID)
+		return nil
+	})
+
+	wait(t, client, "catalog")
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"1", "2"}, consumed)
+	assert.Empty(t, client.Deadlettered("catalog"))
+}
+
+func TestPriorities(t *testing.T) {
+	client := connectedClient(t)
+
+	publish(t, client, "catalog", 1, &base.EyewaEvent{ID: "low"})
+	publish(t, client, "catalog", 5, &base.EyewaEvent{ID: "high"})
+	publish(t, client, "catalog", 1, &base.EyewaEvent{ID: "low-2"})
+	publish(t, client, "catalog", 3, &base.EyewaEvent{ID: "medium"})
+
+	var consumed []string
+	consume(t, client, "catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err == nil {
+			consumed = append(consumed, event.ID)
+		}
+		return nil
+	})
+
+	wait(t, client, "catalog")
+	assert.Equal(t, []string{"high", "medium", "low", "low-2"}, consumed)
+}
+
+func TestPublishEmptyEvent(t *testing.T) {
+	client := connectedClient(t)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	assert.EqualError(t, client.PublishEvent(context.Background(), "catalog", 0, nil, wg), "Event is empty!")
+	wg.Wait()
+}
+
+func TestFailedEventsDeadlettered(t *testing.T) {
+	client := connectedClient(t)
+
+	publish(t, client, "catalog", 0, &base.EyewaEvent{ID: "1"})
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	body := []byte("not json")
+	assert.Nil(t, client.PublishEvent(context.Background(), "catalog", 0, &body, wg))
+
+	consume(t, client, "catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+
+	wait(t, client, "catalog")
+
+	deadlettered := client.Deadlettered("catalog")
+	assert.Len(t, deadlettered, 2)
+
+	var event base.EyewaEvent
+	assert.Nil(t, json.Unmarshal(deadlettered[0].Body, &event))
+	assert.Equal(t, "1", event.ID)
+	assert.Equal(t, "failed", event.Errors[0].ErrorMessage)
+
+	// not an event - deadlettered as is
+	assert.Equal(t, body, deadlettered[1].Body)
+}
+
+func TestAckNackRequeue(t *testing.T) {
+	client := connectedClient(t)
+	publish(t, client, "catalog", 0, &base.EyewaEvent{ID: "1"})
+
+	delivery, ok := client.next(context.Background(), "catalog")
+	assert.True(t, ok)
+	assert.False(t, delivery.Redelivered)
+	assert.Equal(t, 1, client.Pending("catalog"))
+
+	// requeued + redelivered
+	assert.Nil(t, delivery.Nack(true))
+	assert.NotNil(t, delivery.Ack())
+
+	redelivery, ok := client.next(context.Background(), "catalog")
+	assert.True(t, ok)
+	assert.True(t, redelivery.Redelivered)
+	assert.Equal(t, 1, redelivery.RedeliveryCount)
+	assert.Equal(t, delivery.MessageID, redelivery.MessageID)
+
+	// dropped
+	assert.Nil(t, redelivery.Reject(false))
+	assert.Equal(t, 0, client.Pending("catalog"))
+}
+
+func TestTracePropagated(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	client := connectedClient(t)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "test")
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	client.Publish(ctx, "catalog", 0, &base.EyewaEvent{ID: "1"}, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		return err
+	}, wg)
+	span.End()
+
+	traceIDs := make(chan trace.TraceID, 1)
+	consume(t, client, "catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err == nil {
+			traceIDs <- trace.SpanContextFromContext(ctx).TraceID()
+		}
+		return nil
+	})
+
+	select {
+	case traceID := <-traceIDs:
+		assert.Equal(t, span.SpanContext().TraceID(), traceID)
+	case <-time.After(time.Second):
+		assert.Fail(t, "event not consumed")
+	}
+
+	assert.NotEmpty(t, client.Published("catalog")[0].Headers["traceparent"])
+}
+
+func TestConsumeStopped(t *testing.T) {
+	log.SetLogLevel()
+	client := NewMemoryClient()
+
+	var errs []error
+	callback := func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		errs = append(errs, err)
+		return nil
+	}
+
+	// not connected
+	client.Consume("catalog", callback)
+
+	assert.Nil(t, client.Connect())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.ConsumeWithContext(ctx, "catalog", callback)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = client.CloseConnection()
+	}()
+	client.Consume("catalog", callback)
+
+	assert.Equal(t, []error{
+		libErrs.ErrorNoMemoryBrokerConnection,
+		libErrs.ErrorConsumerStopped,
+		libErrs.ErrorLostConnectionToMessageBroker,
+	}, errs)
+}
+
+func TestConsumeMagentoProductEvents(t *testing.T) {
+	client := connectedClient(t)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	client.PublishMagentoProductEvent(context.Background(), "products", 0, &base.MagentoProductEvent{ID: "1", EntityID: 10},
+		func(ctx context.Context, event *base.MagentoProductEvent, err error) error { return err }, wg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go client.ConsumeMagentoProductEventsWithContext(ctx, "products", func(ctx context.Context, event *base.MagentoProductEvent, err error) error {
+		if err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+
+	wait(t, client, "products")
+
+	var event base.MagentoProductEvent
+	assert.Nil(t, json.Unmarshal(client.Deadlettered("products")[0].Body, &event))
+	assert.Equal(t, 10, event.EntityID)
+	assert.Len(t, event.Errors, 1)
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"container/heap"
+)
+
+func (h messageHeap) Len() int { return len(h) }
+
+func (h messageHeap) Less(i, j int) bool {
+	if h[i].Priority != h[j].Priority {
+		return h[i].Priority > h[j].Priority
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h messageHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *messageHeap) Push(x interface{}) { *h = append(*h, x.(*entry)) }
+
+func (h *messageHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+
+	return e
+}
+
+func newQueue() *queue {
+	return &queue{
+		unacked: make(map[uint64]*entry),
+		changed: make(chan struct{}),
+	}
+}
+
+// push adds a message to be delivered.
+func (q *queue) push(e *entry) {
+	heap.Push(&q.ready, e)
+	q.notify()
+}
+
+// pop takes the next message to be delivered - if any - tracking it as unacked under tag.
+func (q *queue) pop(tag uint64) (*entry, bool) {
+	if q.ready.Len() == 0 {
+		return nil, false
+	}
+
+	e := heap.Pop(&q.ready).(*entry)
+	e.DeliveryCount++
+	q.unacked[tag] = e
+	q.notify()
+
+	return e, true
+}
+
+// settle removes an unacked message, returning it to be redelivered if requeue is true.
+func (q *queue) settle(tag uint64, requeue bool) bool {
+	e, ok := q.unacked[tag]
+	if !ok {
+		return false
+	}
+
+	delete(q.unacked, tag)
+	if requeue {
+		e.Redelivered = true
+		heap.Push(&q.ready, e)
+	}
+	q.notify()
+
+	return true
+}
+
+// pending no. of messages not yet settled.
+func (q *queue) pending() int {
+	return q.ready.Len() + len(q.unacked)
+}
+
+// notify wakes up anyone waiting for the queue to change.
+func (q *queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+)
+
+// Message a message published to an in-memory queue.
+type Message struct {
+	ID            string                 // unique per message published
+	Queue         string                 // queue message was published to
+	Headers       map[string]interface{} // includes the trace context of the publisher
+	ContentType   string
+	Body          []byte
+	Priority      uint8     // higher priority messages are delivered first
+	Redelivered   bool      // if message was requeued after being delivered
+	DeliveryCount int       // no. of times message has been delivered
+	Timestamp     time.Time // when message was published
+}
+
+// MemoryClient an in-process message broker implementing the MessageBroker
+// interface. Messages published are delivered to consumers of the same client -
+// by priority, then in the order published - and settled with ack/nack/requeue
+// semantics. Failed messages are deadlettered to deadletter-<queue>.
+//
+// Meant for tests and local development - nothing is persisted.
+type MemoryClient struct {
+	mutex *sync.Mutex
+
+	queues map[string]*queue
+
+	// sequence no. of the last message published + tag of the last delivery
+	seq        uint64
+	deliveries uint64
+
+	connected bool
+
+	// closed once CloseConnection is called to stop consuming
+	closed chan struct{}
+}
+
+// queue an in-memory queue.
+type queue struct {
+	// messages ready to be delivered - by priority, then sequence no.
+	ready messageHeap
+
+	// messages delivered but not settled yet - by delivery tag
+	unacked map[uint64]*entry
+
+	// every message ever published to the queue - for assertions
+	published []Message
+
+	// closed + replaced whenever the queue changes, waking up any waiters
+	changed chan struct{}
+}
+
+// entry a message in a queue.
+type entry struct {
+	Message
+	seq uint64
+}
+
+// messageHeap a priority queue of messages.
+type messageHeap []*entry
+
+// processFunc is invoked for every delivery consumed. It returns the name
+// of the event and an error if the message should be deadlettered.
+type processFunc func(ctx context.Context, delivery base.Delivery) (string, error)
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Chain wraps callback with middlewares - the first being the outermost i.e
+// invoked first on every event.
+func Chain(callback base.MessageBrokerCallbackFunc, middlewares ...Middleware) base.MessageBrokerCallbackFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		callback = middlewares[i](callback)
+	}
+
+	return callback
+}
+
+// ChainMagentoProduct wraps a magento product event callback with middlewares - see Chain.
+func ChainMagentoProduct(callback base.MessageBrokerMagentoProductCallbackFunc, middlewares ...MagentoProductMiddleware) base.MessageBrokerMagentoProductCallbackFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		callback = middlewares[i](callback)
+	}
+
+	return callback
+}
+
+// Recover recovers panics in the callback, failing the event instead.
+func Recover() Middleware {
+	return recoverer[base.EyewaEvent, base.MessageBrokerCallbackFunc]
+}
+
+// RecoverMagentoProduct recovers panics in the callback - see Recover.
+func RecoverMagentoProduct() MagentoProductMiddleware {
+	return recoverer[base.MagentoProductEvent, base.MessageBrokerMagentoProductCallbackFunc]
+}
+
+// Timeout gives the callback d to process each event, cancelling its ctx after.
+func Timeout(d time.Duration) Middleware {
+	return timeout[base.EyewaEvent, base.MessageBrokerCallbackFunc](d)
+}
+
+// TimeoutMagentoProduct gives the callback d to process each event - see Timeout.
+func TimeoutMagentoProduct(d time.Duration) MagentoProductMiddleware {
+	return timeout[base.MagentoProductEvent, base.MessageBrokerMagentoProductCallbackFunc](d)
+}
+
+// Validate fails events validate reports an error for, without invoking the callback.
+func Validate(validate func(ctx context.Context, event *base.EyewaEvent) error) Middleware {
+	return validator[base.EyewaEvent, base.MessageBrokerCallbackFunc](validate)
+}
+
+// ValidateMagentoProduct fails events validate reports an error for - see Validate.
+func ValidateMagentoProduct(validate func(ctx context.Context, event *base.MagentoProductEvent) error) MagentoProductMiddleware {
+	return validator[base.MagentoProductEvent, base.MessageBrokerMagentoProductCallbackFunc](validate)
+}
+
+// RateLimit limits the rate events are passed to the callback at, waiting for
+// limiter to allow each event e.g rate.NewLimiter(100, 10) for 100 events a
+// second in bursts of up to 10.
+func RateLimit(limiter *rate.Limiter) Middleware {
+	return rateLimiter[base.EyewaEvent, base.MessageBrokerCallbackFunc](limiter)
+}
+
+// RateLimitMagentoProduct limits the rate events are passed to the callback at - see RateLimit.
+func RateLimitMagentoProduct(limiter *rate.Limiter) MagentoProductMiddleware {
+	return rateLimiter[base.MagentoProductEvent, base.MessageBrokerMagentoProductCallbackFunc](limiter)
+}
+
+// Log logs the outcome of processing each event along with how long it took.
+func Log() Middleware {
+	return logger[base.EyewaEvent, base.MessageBrokerCallbackFunc](func(event *base.EyewaEvent) []zap.Field {
+		return []zap.Field{zap.String("id", event.ID), zap.String("event", event.Name)}
+	})
+}
+
+// LogMagentoProduct logs the outcome of processing each event - see Log.
+func LogMagentoProduct() MagentoProductMiddleware {
+	return logger[base.MagentoProductEvent, base.MessageBrokerMagentoProductCallbackFunc](func(event *base.MagentoProductEvent) []zap.Field {
+		return []zap.Field{zap.String("id", event.ID), zap.String("event", event.Name), zap.Int("entity_id", event.EntityID)}
+	})
+}
+
+// Failures to consume - an event of nil along with an error - are passed to
+// the callback by all the middlewares as is.
+
+func recoverer[T any, C callback[T]](next C) C {
+	return func(ctx context.Context, event *T, err error) (errResult error) {
+		defer func() {
+			if r := recover(); r != nil {
+				errResult = fmt.Errorf(libErrs.ErrorCallbackPanic.Error(), r)
+			}
+		}()
+
+		return next(ctx, event, err)
+	}
+}
+
+func timeout[T any, C callback[T]](d time.Duration) func(C) C {
+	return func(next C) C {
+		return func(ctx context.Context, event *T, err error) error {
+			if err != nil || event == nil {
+				return next(ctx, event, err)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			return next(ctx, event, err)
+		}
+	}
+}
+
+func validator[T any, C callback[T]](validate func(context.Context, *T) error) func(C) C {
+	return func(next C) C {
+		return func(ctx context.Context, event *T, err error) error {
+			if err != nil || event == nil {
+				return next(ctx, event, err)
+			}
+
+			if err := validate(ctx, event); err != nil {
+				return fmt.Errorf(libErrs.ErrorInvalidEvent.Error(), base.EventNameOf(event), err)
+			}
+
+			return next(ctx, event, err)
+		}
+	}
+}
+
+func rateLimiter[T any, C callback[T]](limiter *rate.Limiter) func(C) C {
+	return func(next C) C {
+		return func(ctx context.Context, event *T, err error) error {
+			if err != nil || event == nil {
+				return next(ctx, event, err)
+			}
+
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+
+			return next(ctx, event, err)
+		}
+	}
+}
+
+func logger[T any, C callback[T]](fields func(*T) []zap.Field) func(C) C {
+	return func(next C) C {
+		return func(ctx context.Context, event *T, err error) error {
+			if err != nil || event == nil {
+				return next(ctx, event, err)
+			}
+
+			started := time.Now()
+			errCallback := next(ctx, event, err)
+
+			logFields := append(fields(event), zap.Duration("duration", time.Since(started)))
+			if errCallback != nil {
+				log.Error("Failed to process event.", append(logFields, zap.Error(errCallback))...)
+				return errCallback
+			}
+
+			log.Info("Processed event.", logFields...)
+			return nil
+		}
+	}
+}
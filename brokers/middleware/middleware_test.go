@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/brokers/dedup"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+// tag a middleware appending name to the calls made.
+func tag(name string, calls *[]string) Middleware {
+	return func(next base.MessageBrokerCallbackFunc) base.MessageBrokerCallbackFunc {
+		return func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			*calls = append(*calls, name)
+			return next(ctx, event, err)
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+
+	callback := Chain(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		calls = append(calls, "callback")
+		return nil
+	}, tag("first", &calls), tag("second", &calls))
+
+	assert.Nil(t, callback(context.Background(), &base.EyewaEvent{}, nil))
+	assert.Equal(t, []string{"first", "second", "callback"}, calls)
+}
+
+func TestChainWithDedup(t *testing.T) {
+	deduplicator, err := dedup.New(dedup.NewMemoryStore(10))
+	assert.Nil(t, err)
+
+	calls := 0
+	callback := Chain(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		calls++
+		return nil
+	}, Recover(), deduplicator.Callback)
+
+	event := &base.EyewaEvent{ID: "1"}
+	assert.Nil(t, callback(context.Background(), event, nil))
+	assert.Nil(t, callback(context.Background(), event, nil))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRecover(t *testing.T) {
+	callback := Chain(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		panic("boom")
+	}, Recover())
+
+	err := callback(context.Background(), &base.EyewaEvent{}, nil)
+	assert.EqualError(t, err, "Recovered from panic in callback. boom")
+
+	magentoCallback := ChainMagentoProduct(func(ctx context.Context, event *base.MagentoProductEvent, err error) error {
+		panic("boom")
+	}, RecoverMagentoProduct())
+
+	assert.NotNil(t, magentoCallback(context.Background(), &base.MagentoProductEvent{}, nil))
+}
+
+func TestTimeout(t *testing.T) {
+	callback := Chain(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err != nil {
+			return err
+		}
+
+		<-ctx.Done()
+		return ctx.Err()
+	}, Timeout(10*time.Millisecond))
+
+	assert.Equal(t, context.DeadlineExceeded, callback(context.Background(), &base.EyewaEvent{}, nil))
+
+	// failures to consume aren't timed
+	errConsume := errors.New("failed")
+	assert.Equal(t, errConsume, callback(context.Background(), nil, errConsume))
+}
+
+func TestValidate(t *testing.T) {
+	calls := 0
+	callback := Chain(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		calls++
+		return nil
+	}, Validate(func(ctx context.Context, event *base.EyewaEvent) error {
+		if event.ID == "" {
+			return errors.New("no id")
+		}
+		return nil
+	}))
+
+	tests := []struct {
+		name  string
+		event *base.EyewaEvent
+		err   string
+		calls int
+	}{
+		{"valid", &base.EyewaEvent{ID: "1", Name: "product.created"}, "", 1},
+		{"invalid", &base.EyewaEvent{Name: "product.created"}, "Invalid event(product.created). no id", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls = 0
+			err := callback(context.Background(), test.event, nil)
+			if test.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+			assert.Equal(t, test.calls, calls)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	callback := ChainMagentoProduct(func(ctx context.Context, event *base.MagentoProductEvent, err error) error {
+		return nil
+	}, RateLimitMagentoProduct(rate.NewLimiter(rate.Every(time.Hour), 1)))
+
+	assert.Nil(t, callback(context.Background(), &base.MagentoProductEvent{}, nil))
+
+	// out of tokens - waits until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, callback(ctx, &base.MagentoProductEvent{}, nil))
+}
+
+func TestLog(t *testing.T) {
+	errCallback := errors.New("failed")
+
+	callback := Chain(func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		return errCallback
+	}, Log())
+	assert.Equal(t, errCallback, callback(context.Background(), &base.EyewaEvent{}, nil))
+
+	magentoCallback := ChainMagentoProduct(func(ctx context.Context, event *base.MagentoProductEvent, err error) error {
+		return nil
+	}, LogMagentoProduct())
+	assert.Nil(t, magentoCallback(context.Background(), &base.MagentoProductEvent{}, nil))
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+)
+
+// Middleware wraps a consumer callback with behavior of its own e.g recovering
+// panics or logging events - invoking the callback it wraps, or not.
+type Middleware func(base.MessageBrokerCallbackFunc) base.MessageBrokerCallbackFunc
+
+// MagentoProductMiddleware wraps a magento product event consumer callback.
+type MagentoProductMiddleware func(base.MessageBrokerMagentoProductCallbackFunc) base.MessageBrokerMagentoProductCallbackFunc
+
+// callback a consumer callback of events of type T - any of the broker callbacks.
+type callback[T any] interface {
+	~func(ctx context.Context, event *T, err error) error
+}
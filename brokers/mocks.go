@@ -5,7 +5,6 @@ import (
 	"sync"
 
 	"github.com/eyewa/eyewa-go-lib/base"
-	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -67,7 +66,7 @@ func (mock *ClientMock) IsConnectionOpen() bool {
 	return args.Bool(0)
 }
 
-func (mock *ClientMock) SendToDeadletterQueue(msg amqp.Delivery, err error) error {
+func (mock *ClientMock) SendToDeadletterQueue(msg base.Delivery, err error) error {
 	args := mock.Called(msg, err)
 	return args.Error(0)
 }
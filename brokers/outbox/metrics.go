@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/unit"
+
+	"github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/metrics"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OutboxMetrics is a collection of standard metrics
+type OutboxMetrics struct {
+	RelayedEventCounter         *metrics.Counter
+	RelayFailureCounter         *metrics.Counter
+	RelayedEventLatencyRecorder *metrics.ValueRecorder
+	PendingEventRecorder        *metrics.ValueRecorder
+	LagRecorder                 *metrics.ValueRecorder
+}
+
+// NewOutboxMetrics creates a instance of OutboxMetrics
+func NewOutboxMetrics() *OutboxMetrics {
+	meter := metrics.NewMeter("outbox.meter", context.Background())
+
+	relayedEventCounter, err := meter.NewCounter("outbox.relayed.event.counter",
+		metric.WithDescription("Counts events relayed from the outbox"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	relayFailureCounter, err := meter.NewCounter("outbox.relay.failure.counter",
+		metric.WithDescription("Counts failed attempts to relay events from the outbox"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	relayedEventLatencyRecorder, err := meter.NewValueRecorder("outbox.relayed.event.latency.recorder",
+		metric.WithUnit(unit.Milliseconds),
+		metric.WithDescription("Records how long events spent in the outbox before being relayed"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	pendingEventRecorder, err := meter.NewValueRecorder("outbox.pending.event.recorder",
+		metric.WithDescription("Records the no. of events waiting in the outbox"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	lagRecorder, err := meter.NewValueRecorder("outbox.lag.recorder",
+		metric.WithUnit(unit.Milliseconds),
+		metric.WithDescription("Records the age of the oldest event waiting in the outbox"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	return &OutboxMetrics{
+		RelayedEventCounter:         relayedEventCounter,
+		RelayFailureCounter:         relayFailureCounter,
+		RelayedEventLatencyRecorder: relayedEventLatencyRecorder,
+		PendingEventRecorder:        pendingEventRecorder,
+		LagRecorder:                 lagRecorder,
+	}
+}
@@ -0,0 +1,286 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/brokers"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/ory/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	config              Config
+	standardMetrics     *OutboxMetrics
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	maxErrorLength      = 1024
+)
+
+func initConfig() (Config, error) {
+	config = *new(Config)
+
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	envVars := []string{
+		"OUTBOX_POLL_INTERVAL",
+		"OUTBOX_BATCH_SIZE",
+	}
+
+	for _, v := range envVars {
+		if err := viper.BindEnv(v); err != nil {
+			return config, err
+		}
+	}
+
+	if err := viper.Unmarshal(&config); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// TableName overrides the table name for Event
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Migrate creates/updates the outbox table.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Event{})
+}
+
+// Store stores an event in the outbox as part of tx - the transaction of the
+// domain writes it belongs to. The event is published to queue by a Relay
+// once tx is committed, and never if tx is rolled back.
+func Store(tx *gorm.DB, queue string, priority int, event *base.EyewaEvent) error {
+	if queue == "" {
+		return libErrs.ErrorQueueNotSpecified
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&Event{
+		EventID:  event.ID,
+		Queue:    queue,
+		Priority: priority,
+		Payload:  payload,
+	}).Error
+}
+
+// NewRelay creates a relay publishing the events in the outbox of db via publisher.
+func NewRelay(db *gorm.DB, publisher brokers.Publisher) (*Relay, error) {
+	if _, err := initConfig(); err != nil {
+		return nil, err
+	}
+
+	standardMetrics = NewOutboxMetrics()
+
+	pollInterval, err := time.ParseDuration(config.PollInterval)
+	if err != nil || pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	batchSize, err := strconv.Atoi(config.BatchSize)
+	if err != nil || batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &Relay{
+		mutex:        new(sync.Mutex),
+		db:           db,
+		publisher:    publisher,
+		PollInterval: pollInterval,
+		BatchSize:    batchSize,
+		notify:       make(chan struct{}, 1),
+	}, nil
+}
+
+// Notify wakes up the relay to relay events right away e.g after storing
+// events, rather than waiting for the next poll.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays events until ctx is done. Events are relayed in the order stored
+// - at least once. An event that fails to be published is retried on the next
+// poll and holds back the events stored after it.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		relayed, err := r.RelayPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error(libErrs.ErrorOutboxRelayFailure.Error(), zap.Error(err))
+		}
+
+		// more events might be waiting
+		if err == nil && relayed == r.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.notify:
+		}
+	}
+}
+
+// RelayPending publishes a batch of events waiting in the outbox, marking each
+// as sent once published. It returns the no. of events relayed.
+//
+// On MySQL/Postgres the batch is locked while relayed, so several relays
+// can run side by side - each relaying different events.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	// relays in the same process take turns
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	relayed := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("sent_at IS NULL").Order("id").Limit(r.BatchSize)
+		if tx.Dialector.Name() != "sqlite" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var events []Event
+		if err := query.Find(&events).Error; err != nil {
+			return err
+		}
+
+		for i := range events {
+			sent, err := r.relay(ctx, tx, &events[i])
+			if err != nil {
+				return err
+			}
+			if !sent {
+				// keep the order - later events wait for this one
+				return nil
+			}
+			relayed++
+		}
+
+		return nil
+	})
+
+	r.recordLag(ctx)
+
+	if err != nil {
+		// the batch was rolled back
+		return 0, err
+	}
+
+	return relayed, nil
+}
+
+// relay publishes an event, marking it as sent or recording why it failed. It
+// reports if the event was sent - an error only if the outcome couldn't be recorded.
+func (r *Relay) relay(ctx context.Context, tx *gorm.DB, event *Event) (bool, error) {
+	var eyewaEvent *base.EyewaEvent
+	err := json.Unmarshal(event.Payload, &eyewaEvent)
+	if err == nil {
+		err = r.publish(ctx, event.Queue, event.Priority, eyewaEvent)
+	}
+
+	if err != nil {
+		go standardMetrics.RelayFailureCounter.Add(1, attribute.String("queue", event.Queue))
+		log.Error(libErrs.ErrorOutboxRelayFailure.Error(),
+			zap.String("event", event.EventID),
+			zap.String("queue", event.Queue),
+			zap.Error(err))
+
+		lastError := err.Error()
+		if len(lastError) > maxErrorLength {
+			lastError = lastError[:maxErrorLength]
+		}
+
+		if err := tx.Model(event).Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": lastError,
+		}).Error; err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	sentAt := time.Now()
+	if err := tx.Model(event).Update("sent_at", sentAt).Error; err != nil {
+		return false, err
+	}
+
+	go standardMetrics.RelayedEventCounter.Add(1, attribute.String("queue", event.Queue))
+	go standardMetrics.RelayedEventLatencyRecorder.Record(float64(sentAt.Sub(event.CreatedAt).Milliseconds()))
+
+	return true, nil
+}
+
+// publish publishes an event via the publisher, waiting for the outcome.
+func (r *Relay) publish(ctx context.Context, queue string, priority int, event *base.EyewaEvent) error {
+	var (
+		wg          = new(sync.WaitGroup)
+		errResult   error
+		resultMutex sync.Mutex
+	)
+
+	wg.Add(1)
+	r.publisher.Publish(ctx, queue, priority, event, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		resultMutex.Lock()
+		defer resultMutex.Unlock()
+
+		errResult = err
+		return nil
+	}, wg)
+	wg.Wait()
+
+	resultMutex.Lock()
+	defer resultMutex.Unlock()
+
+	return errResult
+}
+
+// recordLag records the no. of events waiting in the outbox + the age of the oldest.
+func (r *Relay) recordLag(ctx context.Context) {
+	var pending int64
+	if err := r.db.WithContext(ctx).Model(&Event{}).Where("sent_at IS NULL").Count(&pending).Error; err != nil {
+		return
+	}
+
+	go standardMetrics.PendingEventRecorder.Record(float64(pending))
+
+	var lag float64
+	if pending > 0 {
+		var oldest Event
+		if err := r.db.WithContext(ctx).Where("sent_at IS NULL").Order("id").First(&oldest).Error; err == nil {
+			lag = float64(time.Since(oldest.CreatedAt).Milliseconds())
+		}
+	}
+
+	go standardMetrics.LagRecorder.Record(lag)
+}
+
+// Purge deletes events sent before t, returning how many were deleted.
+func (r *Relay) Purge(ctx context.Context, t time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("sent_at < ?", t).Delete(&Event{})
+	return result.RowsAffected, result.Error
+}
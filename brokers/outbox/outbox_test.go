@@ -0,0 +1,187 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/brokers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent)})
+	assert.Nil(t, err)
+	assert.Nil(t, Migrate(db))
+
+	return db
+}
+
+// publishing mocks publishing an event with the outcome err.
+func publishing(client *brokers.ClientMock, eventID string, err error) *mock.Call {
+	return client.On("Publish", mock.Anything, "catalog", brokers.PriorityNone,
+		mock.MatchedBy(func(event *base.EyewaEvent) bool { return event.ID == eventID }),
+		mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			callback := args.Get(4).(base.MessageBrokerCallbackFunc)
+			_ = callback(context.Background(), args.Get(3).(*base.EyewaEvent), err)
+		})
+}
+
+func pending(t *testing.T, db *gorm.DB) []Event {
+	var events []Event
+	assert.Nil(t, db.Where("sent_at IS NULL").Order("id").Find(&events).Error)
+
+	return events
+}
+
+func TestNewRelayConfig(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	relay, err := NewRelay(nil, brokers.NewMockClient())
+	assert.Nil(t, err)
+	assert.Equal(t, defaultPollInterval, relay.PollInterval)
+	assert.Equal(t, defaultBatchSize, relay.BatchSize)
+
+	os.Setenv("OUTBOX_POLL_INTERVAL", "250ms")
+	os.Setenv("OUTBOX_BATCH_SIZE", "10")
+
+	relay, err = NewRelay(nil, brokers.NewMockClient())
+	assert.Nil(t, err)
+	assert.Equal(t, 250*time.Millisecond, relay.PollInterval)
+	assert.Equal(t, 10, relay.BatchSize)
+}
+
+func TestStoreIsTransactional(t *testing.T) {
+	db := openDB(t)
+
+	// rolled back with the domain writes
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		assert.Nil(t, Store(tx, "catalog", brokers.PriorityNone, &base.EyewaEvent{ID: "1"}))
+		return errors.New("domain write failed")
+	})
+	assert.Empty(t, pending(t, db))
+
+	assert.Nil(t, db.Transaction(func(tx *gorm.DB) error {
+		return Store(tx, "catalog", brokers.PriorityNone, &base.EyewaEvent{ID: "2", Name: "product.created"})
+	}))
+
+	events := pending(t, db)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "2", events[0].EventID)
+	assert.Equal(t, "catalog", events[0].Queue)
+	assert.JSONEq(t, `{"id":"2","name":"product.created","event_type":"","payload":null,"created_at":""}`, string(events[0].Payload))
+
+	assert.NotNil(t, Store(db, "", brokers.PriorityNone, &base.EyewaEvent{ID: "3"}))
+}
+
+func TestRelayPending(t *testing.T) {
+	db := openDB(t)
+	for _, id := range []string{"1", "2", "3"} {
+		assert.Nil(t, Store(db, "catalog", brokers.PriorityNone, &base.EyewaEvent{ID: id}))
+	}
+
+	client := brokers.NewMockClient()
+	publishing(client, "1", nil).Once()
+	publishing(client, "2", errors.New("broker down")).Once()
+
+	relay, err := NewRelay(db, client)
+	assert.Nil(t, err)
+
+	// 2 fails and holds back 3
+	relayed, err := relay.RelayPending(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, relayed)
+
+	events := pending(t, db)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "2", events[0].EventID)
+	assert.Equal(t, 1, events[0].Attempts)
+	assert.Equal(t, "broker down", events[0].LastError)
+
+	publishing(client, "2", nil).Once()
+	publishing(client, "3", nil).Once()
+
+	relayed, err = relay.RelayPending(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, relayed)
+	assert.Empty(t, pending(t, db))
+	client.AssertExpectations(t)
+
+	// sent events are purged
+	purged, err := relay.Purge(context.Background(), time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), purged)
+}
+
+func TestRelayPendingRecordFailure(t *testing.T) {
+	db := openDB(t)
+	for _, id := range []string{"1", "2"} {
+		assert.Nil(t, Store(db, "catalog", brokers.PriorityNone, &base.EyewaEvent{ID: id}))
+	}
+
+	// recording the failure of 2 fails
+	assert.Nil(t, db.Callback().Update().Before("gorm:update").Register("fail_attempts", func(tx *gorm.DB) {
+		if fields, ok := tx.Statement.Dest.(map[string]interface{}); ok && fields["attempts"] != nil {
+			_ = tx.AddError(errors.New("database down"))
+		}
+	}))
+
+	client := brokers.NewMockClient()
+	publishing(client, "1", nil).Once()
+	publishing(client, "2", errors.New("broker down")).Once()
+
+	relay, err := NewRelay(db, client)
+	assert.Nil(t, err)
+
+	// the batch is rolled back - 1 is left to be relayed again
+	relayed, err := relay.RelayPending(context.Background())
+	assert.EqualError(t, err, "database down")
+	assert.Equal(t, 0, relayed)
+	assert.Len(t, pending(t, db), 2)
+	client.AssertExpectations(t)
+}
+
+func TestRelayRun(t *testing.T) {
+	db := openDB(t)
+
+	client := brokers.NewMockClient()
+	published := make(chan struct{})
+	publishing(client, "1", nil).Run(func(args mock.Arguments) {
+		_ = args.Get(4).(base.MessageBrokerCallbackFunc)(context.Background(), nil, nil)
+		close(published)
+	})
+
+	relay, err := NewRelay(db, client)
+	assert.Nil(t, err)
+	relay.PollInterval = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	assert.Nil(t, Store(db, "catalog", brokers.PriorityNone, &base.EyewaEvent{ID: "1"}))
+	relay.Notify()
+
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not relayed")
+	}
+
+	cancel()
+	<-done
+}
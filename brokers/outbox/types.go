@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/brokers"
+	"gorm.io/gorm"
+)
+
+// Config for all outbox env vars
+type Config struct {
+	// How often the outbox is polled for events to relay e.g 500ms
+	PollInterval string `mapstructure:"outbox_poll_interval"`
+
+	// Max no. of events relayed per poll
+	BatchSize string `mapstructure:"outbox_batch_size"`
+}
+
+// Event an event stored in the outbox until it is relayed to the message broker.
+type Event struct {
+	ID        uint64     `gorm:"primaryKey;autoIncrement"`
+	EventID   string     `gorm:"size:64;index"`     // ID of the stored event
+	Queue     string     `gorm:"size:255;not null"` // queue to publish the event to
+	Priority  int        // priority to publish the event with
+	Payload   []byte     `gorm:"not null"` // the event JSON
+	Attempts  int        // no. of failed attempts to publish the event
+	LastError string     `gorm:"size:1024"`
+	CreatedAt time.Time  `gorm:"index"`
+	SentAt    *time.Time `gorm:"index"` // nil until published
+}
+
+// Relay publishes the events stored in the outbox.
+type Relay struct {
+	mutex *sync.Mutex
+
+	db        *gorm.DB
+	publisher brokers.Publisher
+
+	PollInterval time.Duration
+	BatchSize    int
+
+	// wakes up the relay to poll right away
+	notify chan struct{}
+}
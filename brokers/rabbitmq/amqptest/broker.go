@@ -0,0 +1,666 @@
+package amqptest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// handleMethod handles a method sent on a channel. The mutex must be held.
+func (s *Server) handleMethod(ch *channel, m method, d *decoder) error {
+	c := ch.conn
+
+	switch m {
+	case exchangeDeclare:
+		d.short()
+		name, kind := d.shortstr(), d.shortstr()
+		passive, _, _, _, noWait := d.bit(), d.bit(), d.bit(), d.bit(), d.bit()
+		d.table()
+
+		if err := s.declareExchange(name, kind, passive); err != nil {
+			return err
+		}
+		if !noWait {
+			c.send(ch.id, exchangeDeclareOk, nil)
+		}
+
+	case queueDeclare:
+		d.short()
+		name := d.shortstr()
+		passive, _, _, _, noWait := d.bit(), d.bit(), d.bit(), d.bit(), d.bit()
+		args := d.table()
+
+		q, err := s.declareQueue(name, passive, args)
+		if err != nil {
+			return err
+		}
+		if !noWait {
+			c.send(ch.id, queueDeclareOk, newEncoder().shortstr(q.name).long(uint32(len(q.ready))).long(uint32(len(q.consumers))))
+		}
+
+	case queueBind:
+		d.short()
+		queue, exchange, key := d.shortstr(), d.shortstr(), d.shortstr()
+		noWait := d.bit()
+		args := d.table()
+
+		if err := s.bind(queue, exchange, key, args); err != nil {
+			return err
+		}
+		if !noWait {
+			c.send(ch.id, queueBindOk, nil)
+		}
+
+	case basicQos:
+		d.long()
+		ch.prefetch = int(d.short())
+		ch.globalQos = d.bit()
+
+		c.send(ch.id, basicQosOk, nil)
+		s.dispatchChannel(ch)
+
+	case basicConsume:
+		d.short()
+		queue, tag := d.shortstr(), d.shortstr()
+		_, noAck, _, noWait := d.bit(), d.bit(), d.bit(), d.bit()
+		d.table()
+
+		q, exists := s.queues[queue]
+		if !exists {
+			return channelException(replyNotFound, "NOT_FOUND - no queue '%s' in vhost '/'", queue)
+		}
+
+		if queueType, _ := q.args["x-queue-type"].(string); ch.globalQos && (queueType == "quorum" || queueType == "stream") {
+			return channelException(replyPreconditionFailed, "PRECONDITION_FAILED - global qos is not supported by %s queue '%s'", queueType, queue)
+		}
+
+		if tag == "" {
+			s.seq++
+			tag = fmt.Sprintf("amq.ctag-%d", s.seq)
+		}
+
+		if _, exists := ch.consumers[tag]; exists {
+			return connectionException(replyNotAllowed, "NOT_ALLOWED - attempt to reuse consumer tag '%s'", tag)
+		}
+
+		cons := &consumer{tag: tag, channel: ch, queue: q, noAck: noAck}
+		ch.consumers[tag] = cons
+		q.consumers = append(q.consumers, cons)
+
+		if !noWait {
+			c.send(ch.id, basicConsumeOk, newEncoder().shortstr(tag))
+		}
+		s.dispatch(q)
+
+	case basicCancel:
+		tag := d.shortstr()
+		noWait := d.bit()
+
+		if cons, exists := ch.consumers[tag]; exists {
+			s.cancel(cons)
+		}
+		if !noWait {
+			c.send(ch.id, basicCancelOk, newEncoder().shortstr(tag))
+		}
+
+	case basicPublish:
+		d.short()
+		exchange, key := d.shortstr(), d.shortstr()
+		mandatory, immediate := d.bit(), d.bit()
+
+		if immediate {
+			return connectionException(replyNotImplemented, "NOT_IMPLEMENTED - immediate=true")
+		}
+
+		ch.publishing = &publishing{exchange: exchange, routingKey: key, mandatory: mandatory}
+
+	case basicGet:
+		d.short()
+		queue := d.shortstr()
+		noAck := d.bit()
+
+		q, exists := s.queues[queue]
+		if !exists {
+			return channelException(replyNotFound, "NOT_FOUND - no queue '%s' in vhost '/'", queue)
+		}
+
+		if len(q.ready) == 0 {
+			c.send(ch.id, basicGetEmpty, newEncoder().shortstr(""))
+			return nil
+		}
+
+		msg := q.pop()
+		tag := ch.track(q, msg, noAck)
+		c.sendContent(ch.id, basicGetOk, newEncoder().
+			longlong(tag).
+			bit(msg.redelivered).
+			shortstr(msg.exchange).
+			shortstr(msg.routingKey).
+			long(uint32(len(q.ready))), msg)
+
+	case basicAck:
+		tag, multiple := d.longlong(), d.bit()
+
+		return s.settle(ch, tag, multiple, true, false)
+
+	case basicNack:
+		tag := d.longlong()
+		multiple, requeue := d.bit(), d.bit()
+
+		return s.settle(ch, tag, multiple, false, requeue)
+
+	case basicReject:
+		tag, requeue := d.longlong(), d.bit()
+
+		return s.settle(ch, tag, false, false, requeue)
+
+	case confirmSelect:
+		noWait := d.bit()
+
+		ch.confirm = true
+		if !noWait {
+			c.send(ch.id, confirmSelectOk, nil)
+		}
+
+	default:
+		return connectionException(replyNotImplemented, "NOT_IMPLEMENTED - method %d.%d", m.class(), m.id())
+	}
+
+	return nil
+}
+
+// declareExchange declares an exchange - or checks it exists if passive.
+func (s *Server) declareExchange(name, kind string, passive bool) error {
+	// the default exchange
+	if name == "" {
+		return nil
+	}
+
+	ex, exists := s.exchanges[name]
+	switch {
+	case passive && !exists:
+		return channelException(replyNotFound, "NOT_FOUND - no exchange '%s' in vhost '/'", name)
+	case passive:
+	case exists && ex.kind != kind:
+		return channelException(replyPreconditionFailed,
+			"PRECONDITION_FAILED - inequivalent arg 'type' for exchange '%s' in vhost '/': received '%s' but current is '%s'",
+			name, kind, ex.kind)
+	case !exists:
+		switch kind {
+		case amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeTopic, amqp.ExchangeHeaders:
+		default:
+			return connectionException(replyCommandInvalid, "COMMAND_INVALID - unknown exchange type '%s'", kind)
+		}
+
+		s.exchanges[name] = &exchange{name: name, kind: kind}
+	}
+
+	return nil
+}
+
+// declareQueue declares a queue - or checks it exists if passive. A queue
+// can only be redeclared with the arguments it was declared with.
+func (s *Server) declareQueue(name string, passive bool, args amqp.Table) (*queue, error) {
+	if name == "" {
+		s.seq++
+		name = fmt.Sprintf("amq.gen-%d", s.seq)
+	}
+
+	q, exists := s.queues[name]
+	switch {
+	case passive && !exists:
+		return nil, channelException(replyNotFound, "NOT_FOUND - no queue '%s' in vhost '/'", name)
+	case passive:
+	case exists:
+		for key := range union(q.args, args) {
+			if strings.HasPrefix(key, "x-") && !fieldEqual(q.args[key], args[key]) {
+				return nil, channelException(replyPreconditionFailed,
+					"PRECONDITION_FAILED - inequivalent arg '%s' for queue '%s' in vhost '/': received %v but current is %v",
+					key, name, args[key], q.args[key])
+			}
+		}
+	default:
+		q = &queue{name: name, args: args}
+
+		if priority, ok := args["x-max-priority"]; ok {
+			max, ok := toInt64(priority)
+			if !ok || max < 0 || max > 255 {
+				return nil, channelException(replyPreconditionFailed, "PRECONDITION_FAILED - invalid arg 'x-max-priority' for queue '%s'", name)
+			}
+			q.maxPriority = uint8(max)
+		}
+
+		if ttl, ok := args["x-message-ttl"]; ok {
+			ms, ok := toInt64(ttl)
+			if !ok || ms < 0 {
+				return nil, channelException(replyPreconditionFailed, "PRECONDITION_FAILED - invalid arg 'x-message-ttl' for queue '%s'", name)
+			}
+			q.ttl = time.Duration(ms) * time.Millisecond
+		}
+
+		s.queues[name] = q
+	}
+
+	return q, nil
+}
+
+// bind binds a queue to an exchange.
+func (s *Server) bind(queue, exchange, key string, args amqp.Table) error {
+	if exchange == "" {
+		return channelException(replyAccessRefused, "ACCESS_REFUSED - operation not permitted on the default exchange")
+	}
+
+	if _, exists := s.queues[queue]; !exists {
+		return channelException(replyNotFound, "NOT_FOUND - no queue '%s' in vhost '/'", queue)
+	}
+
+	ex, exists := s.exchanges[exchange]
+	if !exists {
+		return channelException(replyNotFound, "NOT_FOUND - no exchange '%s' in vhost '/'", exchange)
+	}
+
+	for _, b := range ex.bindings {
+		if b.queue == queue && b.key == key && fieldEqual(b.args, args) {
+			return nil
+		}
+	}
+
+	ex.bindings = append(ex.bindings, binding{queue: queue, key: key, args: args})
+
+	return nil
+}
+
+// publish routes a message published on a channel to the queues bound. An
+// unroutable message is returned if mandatory, and the message is confirmed
+// if the channel is in confirm mode.
+func (s *Server) publish(ch *channel, pub *publishing) error {
+	queues, err := s.route(pub.exchange, pub.routingKey, pub.props.Headers)
+	if err != nil {
+		return err
+	}
+
+	msg := &message{
+		exchange:   pub.exchange,
+		routingKey: pub.routingKey,
+		props:      pub.props,
+		body:       pub.body,
+	}
+
+	if len(queues) == 0 && pub.mandatory {
+		ch.conn.sendContent(ch.id, basicReturn, newEncoder().
+			short(replyNoRoute).
+			shortstr("NO_ROUTE").
+			shortstr(pub.exchange).
+			shortstr(pub.routingKey), msg)
+	}
+
+	for _, q := range queues {
+		copied := *msg
+		s.enqueue(q, &copied)
+	}
+
+	if ch.confirm {
+		ch.publishSeq++
+		ch.conn.send(ch.id, basicAck, newEncoder().longlong(ch.publishSeq).bit(false))
+	}
+
+	return nil
+}
+
+// route the queues a message is routed to by an exchange.
+func (s *Server) route(exchange, key string, headers amqp.Table) ([]*queue, error) {
+	// the default exchange routes to the queue named after the key
+	if exchange == "" {
+		if q, exists := s.queues[key]; exists {
+			return []*queue{q}, nil
+		}
+		return nil, nil
+	}
+
+	ex, exists := s.exchanges[exchange]
+	if !exists {
+		return nil, channelException(replyNotFound, "NOT_FOUND - no exchange '%s' in vhost '/'", exchange)
+	}
+
+	var queues []*queue
+	routed := make(map[string]bool)
+
+	for _, b := range ex.bindings {
+		var matches bool
+		switch ex.kind {
+		case amqp.ExchangeDirect:
+			matches = b.key == key
+		case amqp.ExchangeFanout:
+			matches = true
+		case amqp.ExchangeTopic:
+			matches = topicMatches(strings.Split(b.key, "."), strings.Split(key, "."))
+		case amqp.ExchangeHeaders:
+			matches = headersMatch(b.args, headers)
+		}
+
+		if q, exists := s.queues[b.queue]; matches && exists && !routed[b.queue] {
+			routed[b.queue] = true
+			queues = append(queues, q)
+		}
+	}
+
+	return queues, nil
+}
+
+// topicMatches reports if the words of a routing key match those of a topic
+// pattern - * matching exactly one word and # zero or more.
+func topicMatches(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if topicMatches(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && topicMatches(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && topicMatches(pattern[1:], words[1:])
+	}
+}
+
+// headersMatch reports if the headers of a message match the arguments of a
+// binding to a headers exchange - all of them or any if x-match is any.
+func headersMatch(args, headers amqp.Table) bool {
+	matchAny := args["x-match"] == "any"
+
+	for key, value := range args {
+		if strings.HasPrefix(key, "x-") {
+			continue
+		}
+
+		header, exists := headers[key]
+		matches := exists && fieldEqual(header, value)
+
+		if matchAny && matches {
+			return true
+		}
+		if !matchAny && !matches {
+			return false
+		}
+	}
+
+	return !matchAny
+}
+
+// enqueue adds a message to a queue and delivers it if a consumer can take it.
+func (s *Server) enqueue(q *queue, msg *message) {
+	s.seq++
+	msg.seq = s.seq
+
+	msg.priority = msg.props.Priority
+	if msg.priority > q.maxPriority {
+		msg.priority = q.maxPriority
+	}
+
+	q.push(msg)
+
+	if _, expires := q.args["x-message-ttl"]; expires {
+		msg.expiry = time.AfterFunc(q.ttl, func() {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			if q.remove(msg) {
+				s.deadletter(q, msg)
+			}
+		})
+	}
+
+	s.dispatch(q)
+}
+
+// deadletter routes a message expired or rejected without being requeued to
+// the dead letter exchange of its queue - if any, otherwise it is dropped.
+func (s *Server) deadletter(q *queue, msg *message) {
+	dlx, ok := q.args["x-dead-letter-exchange"].(string)
+	if !ok {
+		return
+	}
+
+	key := msg.routingKey
+	if dlKey, ok := q.args["x-dead-letter-routing-key"].(string); ok {
+		key = dlKey
+	}
+
+	queues, err := s.route(dlx, key, msg.props.Headers)
+	if err != nil {
+		return
+	}
+
+	for _, target := range queues {
+		s.enqueue(target, &message{
+			exchange:   dlx,
+			routingKey: key,
+			props:      msg.props,
+			body:       msg.body,
+		})
+	}
+}
+
+// dispatch delivers the messages of a queue to its consumers - round robin,
+// to those with room for more unacked messages.
+func (s *Server) dispatch(q *queue) {
+	for len(q.ready) > 0 {
+		cons := q.nextConsumer()
+		if cons == nil {
+			return
+		}
+
+		msg := q.pop()
+		ch := cons.channel
+		tag := ch.track(q, msg, cons.noAck)
+
+		ch.conn.sendContent(ch.id, basicDeliver, newEncoder().
+			shortstr(cons.tag).
+			longlong(tag).
+			bit(msg.redelivered).
+			shortstr(msg.exchange).
+			shortstr(msg.routingKey), msg)
+	}
+}
+
+// dispatchChannel dispatches the queues consumed from on a channel.
+func (s *Server) dispatchChannel(ch *channel) {
+	for _, cons := range ch.consumers {
+		s.dispatch(cons.queue)
+	}
+}
+
+// settle acks or nacks a delivery - or all up to tag if multiple, all if tag is
+// also 0. Nacked deliveries are requeued or dead lettered.
+func (s *Server) settle(ch *channel, tag uint64, multiple, ack, requeue bool) error {
+	var tags []uint64
+	if multiple {
+		for t := range ch.unacked {
+			if t <= tag || tag == 0 {
+				tags = append(tags, t)
+			}
+		}
+	} else {
+		if _, exists := ch.unacked[tag]; !exists {
+			return channelException(replyPreconditionFailed, "PRECONDITION_FAILED - unknown delivery tag %d", tag)
+		}
+		tags = []uint64{tag}
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	for _, t := range tags {
+		d := ch.unacked[t]
+		delete(ch.unacked, t)
+
+		switch {
+		case ack:
+		case requeue:
+			d.message.redelivered = true
+			d.queue.push(d.message)
+			s.dispatch(d.queue)
+		default:
+			s.deadletter(d.queue, d.message)
+		}
+	}
+
+	s.dispatchChannel(ch)
+
+	return nil
+}
+
+// cancel cancels a consumer. Messages delivered to it stay unacked.
+func (s *Server) cancel(cons *consumer) {
+	delete(cons.channel.consumers, cons.tag)
+
+	q := cons.queue
+	for i, c := range q.consumers {
+		if c == cons {
+			q.consumers = append(q.consumers[:i], q.consumers[i+1:]...)
+			break
+		}
+	}
+}
+
+// closeChannel cancels the consumers of a channel and requeues any messages unacked on it.
+func (s *Server) closeChannel(ch *channel) {
+	for _, cons := range ch.consumers {
+		s.cancel(cons)
+	}
+
+	unacked := ch.unacked
+	ch.unacked = make(map[uint64]*delivery)
+
+	requeued := make(map[*queue]bool)
+	for _, d := range unacked {
+		d.message.redelivered = true
+		d.queue.push(d.message)
+		requeued[d.queue] = true
+	}
+
+	for q := range requeued {
+		s.dispatch(q)
+	}
+}
+
+// track tracks a message delivered on a channel until it is settled - unless
+// it is not to be acked. Returns the delivery tag.
+func (ch *channel) track(q *queue, msg *message, noAck bool) uint64 {
+	ch.deliveryTag++
+	if !noAck {
+		ch.unacked[ch.deliveryTag] = &delivery{queue: q, message: msg}
+	}
+
+	return ch.deliveryTag
+}
+
+// full reports if a channel has as many unacked messages as it may have.
+func (ch *channel) full() bool {
+	return ch.closing || (ch.prefetch > 0 && len(ch.unacked) >= ch.prefetch)
+}
+
+// push adds a message to the queue - after those of a higher or the same
+// priority published before it.
+func (q *queue) push(msg *message) {
+	i := sort.Search(len(q.ready), func(i int) bool {
+		m := q.ready[i]
+		return m.priority < msg.priority || (m.priority == msg.priority && m.seq > msg.seq)
+	})
+
+	q.ready = append(q.ready, nil)
+	copy(q.ready[i+1:], q.ready[i:])
+	q.ready[i] = msg
+}
+
+// pop takes the next message of the queue.
+func (q *queue) pop() *message {
+	msg := q.ready[0]
+	q.ready = q.ready[1:]
+
+	return msg
+}
+
+// remove removes a message from the queue - false if not in the queue.
+func (q *queue) remove(msg *message) bool {
+	for i, m := range q.ready {
+		if m == msg {
+			q.ready = append(q.ready[:i], q.ready[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// nextConsumer the consumer to deliver to next - nil if none can take a message.
+func (q *queue) nextConsumer() *consumer {
+	for i := range q.consumers {
+		n := (q.next + i) % len(q.consumers)
+		if cons := q.consumers[n]; !cons.channel.full() {
+			q.next = (n + 1) % len(q.consumers)
+			return cons
+		}
+	}
+
+	return nil
+}
+
+// union the keys of both tables.
+func union(a, b amqp.Table) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+
+	return keys
+}
+
+// fieldEqual reports if field values are equal - integers of any size being
+// equal if of the same value, as clients encode them differently.
+func fieldEqual(a, b interface{}) bool {
+	x, xInt := toInt64(a)
+	y, yInt := toInt64(b)
+	if xInt && yInt {
+		return x == y
+	}
+
+	if ta, ok := a.(amqp.Table); ok && len(ta) == 0 {
+		a = nil
+	}
+	if tb, ok := b.(amqp.Table); ok && len(tb) == 0 {
+		b = nil
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// toInt64 converts an integer field value to an int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case byte:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
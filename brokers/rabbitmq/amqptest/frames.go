@@ -0,0 +1,509 @@
+package amqptest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// frame types + the octet every frame ends with
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xCE
+
+	// max size of a frame - header + end octet included
+	frameMax = 131072
+)
+
+// method class<<16 | method id of an AMQP method.
+type method uint32
+
+// the methods handled - and sent - by the server.
+const (
+	connectionStart   method = 10<<16 | 10
+	connectionStartOk method = 10<<16 | 11
+	connectionTune    method = 10<<16 | 30
+	connectionTuneOk  method = 10<<16 | 31
+	connectionOpen    method = 10<<16 | 40
+	connectionOpenOk  method = 10<<16 | 41
+	connectionClose   method = 10<<16 | 50
+	connectionCloseOk method = 10<<16 | 51
+
+	channelOpen    method = 20<<16 | 10
+	channelOpenOk  method = 20<<16 | 11
+	channelClose   method = 20<<16 | 40
+	channelCloseOk method = 20<<16 | 41
+
+	exchangeDeclare   method = 40<<16 | 10
+	exchangeDeclareOk method = 40<<16 | 11
+
+	queueDeclare   method = 50<<16 | 10
+	queueDeclareOk method = 50<<16 | 11
+	queueBind      method = 50<<16 | 20
+	queueBindOk    method = 50<<16 | 21
+
+	basicQos       method = 60<<16 | 10
+	basicQosOk     method = 60<<16 | 11
+	basicConsume   method = 60<<16 | 20
+	basicConsumeOk method = 60<<16 | 21
+	basicCancel    method = 60<<16 | 30
+	basicCancelOk  method = 60<<16 | 31
+	basicPublish   method = 60<<16 | 40
+	basicReturn    method = 60<<16 | 50
+	basicDeliver   method = 60<<16 | 60
+	basicGet       method = 60<<16 | 70
+	basicGetOk     method = 60<<16 | 71
+	basicGetEmpty  method = 60<<16 | 72
+	basicAck       method = 60<<16 | 80
+	basicReject    method = 60<<16 | 90
+	basicNack      method = 60<<16 | 120
+
+	confirmSelect   method = 85<<16 | 10
+	confirmSelectOk method = 85<<16 | 11
+)
+
+func (m method) class() uint16 { return uint16(m >> 16) }
+func (m method) id() uint16    { return uint16(m) }
+
+// reply codes of the exceptions raised
+const (
+	replySuccess            = 200
+	replyNoRoute            = 312
+	replyAccessRefused      = 403
+	replyNotFound           = 404
+	replyPreconditionFailed = 406
+	replyFrameError         = 501
+	replySyntaxError        = 502
+	replyCommandInvalid     = 503
+	replyChannelError       = 504
+	replyUnexpectedFrame    = 505
+	replyNotAllowed         = 530
+	replyNotImplemented     = 540
+)
+
+// protocolHeader what every AMQP 0-9-1 connection starts with.
+var protocolHeader = []byte("AMQP\x00\x00\x09\x01")
+
+// frame a frame read from a client.
+type frame struct {
+	typ     byte
+	channel uint16
+	payload []byte
+}
+
+func readFrame(r *bufio.Reader) (frame, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > frameMax-8 {
+		return frame{}, fmt.Errorf("frame of %d bytes exceeds the max of %d", size, frameMax-8)
+	}
+
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return frame{}, err
+	}
+
+	if payload[size] != frameEnd {
+		return frame{}, fmt.Errorf("frame ends with %#x instead of %#x", payload[size], frameEnd)
+	}
+
+	return frame{
+		typ:     header[0],
+		channel: binary.BigEndian.Uint16(header[1:3]),
+		payload: payload[:size],
+	}, nil
+}
+
+// appendFrame appends a frame to buf.
+func appendFrame(buf []byte, typ byte, channel uint16, payload []byte) []byte {
+	var header [7]byte
+	header[0] = typ
+	binary.BigEndian.PutUint16(header[1:3], channel)
+	binary.BigEndian.PutUint32(header[3:], uint32(len(payload)))
+
+	buf = append(buf, header[:]...)
+	buf = append(buf, payload...)
+
+	return append(buf, frameEnd)
+}
+
+// methodFrame a method frame - args is nil for methods without any.
+func methodFrame(channel uint16, m method, args *encoder) []byte {
+	payload := newEncoder().short(m.class()).short(m.id())
+	if args != nil {
+		payload.raw(args.bytes())
+	}
+
+	return appendFrame(nil, frameMethod, channel, payload.bytes())
+}
+
+// contentFrames a method frame followed by the header + body frames of msg.
+func contentFrames(channel uint16, m method, args *encoder, msg *message) []byte {
+	buf := methodFrame(channel, m, args)
+
+	header := newEncoder().short(basicPublish.class()).short(0).longlong(uint64(len(msg.body))).raw(msg.props.raw)
+	buf = appendFrame(buf, frameHeader, channel, header.bytes())
+
+	for body := msg.body; len(body) > 0; {
+		n := len(body)
+		if n > frameMax-8 {
+			n = frameMax - 8
+		}
+
+		buf = appendFrame(buf, frameBody, channel, body[:n])
+		body = body[n:]
+	}
+
+	return buf
+}
+
+// decoder decodes the fields of a method or content header. Once decoding
+// fails, every field decoded after is zero and err is set.
+type decoder struct {
+	buf []byte
+	err error
+
+	// bits are packed into octets - the octet of the last bit + bits decoded from it
+	bits  byte
+	nbits int
+}
+
+func newDecoder(buf []byte) *decoder {
+	return &decoder{buf: buf}
+}
+
+func (d *decoder) next(n int) []byte {
+	d.nbits = 0
+
+	if d.err != nil {
+		return make([]byte, n)
+	}
+
+	if len(d.buf) < n {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+
+	return b
+}
+
+func (d *decoder) octet() byte       { return d.next(1)[0] }
+func (d *decoder) short() uint16     { return binary.BigEndian.Uint16(d.next(2)) }
+func (d *decoder) long() uint32      { return binary.BigEndian.Uint32(d.next(4)) }
+func (d *decoder) longlong() uint64  { return binary.BigEndian.Uint64(d.next(8)) }
+func (d *decoder) shortstr() string  { return string(d.next(int(d.octet()))) }
+func (d *decoder) longstr() string   { return string(d.next(int(d.long()))) }
+func (d *decoder) timestamp() uint64 { return d.longlong() }
+
+func (d *decoder) bit() bool {
+	if d.nbits == 0 || d.nbits == 8 {
+		d.bits = d.octet()
+	}
+
+	bit := d.bits&(1<<d.nbits) != 0
+	d.nbits++
+
+	return bit
+}
+
+func (d *decoder) table() amqp.Table {
+	fields := newDecoder(d.next(int(d.long())))
+	table := make(amqp.Table)
+
+	for len(fields.buf) > 0 && fields.err == nil {
+		key := fields.shortstr()
+		table[key] = fields.field()
+	}
+
+	if d.err == nil {
+		d.err = fields.err
+	}
+
+	return table
+}
+
+func (d *decoder) array() []interface{} {
+	fields := newDecoder(d.next(int(d.long())))
+
+	var array []interface{}
+	for len(fields.buf) > 0 && fields.err == nil {
+		array = append(array, fields.field())
+	}
+
+	if d.err == nil {
+		d.err = fields.err
+	}
+
+	return array
+}
+
+// field decodes a field value into the same type as the client does.
+func (d *decoder) field() interface{} {
+	switch typ := d.octet(); typ {
+	case 't':
+		return d.octet() != 0
+	case 'b':
+		return d.octet()
+	case 's':
+		return int16(d.short())
+	case 'I':
+		return int32(d.long())
+	case 'l':
+		return int64(d.longlong())
+	case 'f':
+		return math.Float32frombits(d.long())
+	case 'd':
+		return math.Float64frombits(d.longlong())
+	case 'D':
+		return amqp.Decimal{Scale: d.octet(), Value: int32(d.long())}
+	case 'S':
+		return d.longstr()
+	case 'A':
+		return d.array()
+	case 'T':
+		return time.Unix(int64(d.longlong()), 0)
+	case 'F':
+		return d.table()
+	case 'x':
+		return []byte(d.longstr())
+	case 'V':
+		return nil
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown field type %q", typ)
+		}
+		return nil
+	}
+}
+
+// encoder encodes the fields of a method or content header.
+type encoder struct {
+	buf bytes.Buffer
+
+	// bits are packed into octets - the octet being packed + bits packed into it
+	bits  byte
+	nbits int
+}
+
+func newEncoder() *encoder {
+	return new(encoder)
+}
+
+// flush writes any bits being packed.
+func (e *encoder) flush() {
+	if e.nbits > 0 {
+		e.buf.WriteByte(e.bits)
+		e.bits, e.nbits = 0, 0
+	}
+}
+
+func (e *encoder) bytes() []byte {
+	e.flush()
+	return e.buf.Bytes()
+}
+
+func (e *encoder) raw(b []byte) *encoder {
+	e.flush()
+	e.buf.Write(b)
+	return e
+}
+
+func (e *encoder) octet(v byte) *encoder {
+	return e.raw([]byte{v})
+}
+
+func (e *encoder) short(v uint16) *encoder {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return e.raw(b[:])
+}
+
+func (e *encoder) long(v uint32) *encoder {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return e.raw(b[:])
+}
+
+func (e *encoder) longlong(v uint64) *encoder {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return e.raw(b[:])
+}
+
+func (e *encoder) shortstr(v string) *encoder {
+	return e.octet(byte(len(v))).raw([]byte(v))
+}
+
+func (e *encoder) longstr(v string) *encoder {
+	return e.long(uint32(len(v))).raw([]byte(v))
+}
+
+func (e *encoder) bit(v bool) *encoder {
+	if e.nbits == 8 {
+		e.flush()
+	}
+
+	if v {
+		e.bits |= 1 << e.nbits
+	}
+	e.nbits++
+
+	return e
+}
+
+func (e *encoder) table(table amqp.Table) *encoder {
+	fields := newEncoder()
+	for key, value := range table {
+		fields.shortstr(key).field(value)
+	}
+
+	return e.long(uint32(len(fields.bytes()))).raw(fields.bytes())
+}
+
+// field encodes a field value of any of the types a table is decoded into.
+func (e *encoder) field(value interface{}) *encoder {
+	switch v := value.(type) {
+	case bool:
+		var b byte
+		if v {
+			b = 1
+		}
+		return e.octet('t').octet(b)
+	case byte:
+		return e.octet('b').octet(v)
+	case int16:
+		return e.octet('s').short(uint16(v))
+	case int:
+		return e.octet('I').long(uint32(v))
+	case int32:
+		return e.octet('I').long(uint32(v))
+	case int64:
+		return e.octet('l').longlong(uint64(v))
+	case float32:
+		return e.octet('f').long(math.Float32bits(v))
+	case float64:
+		return e.octet('d').longlong(math.Float64bits(v))
+	case amqp.Decimal:
+		return e.octet('D').octet(v.Scale).long(uint32(v.Value))
+	case string:
+		return e.octet('S').longstr(v)
+	case []interface{}:
+		values := newEncoder()
+		for _, value := range v {
+			values.field(value)
+		}
+		return e.octet('A').long(uint32(len(values.bytes()))).raw(values.bytes())
+	case time.Time:
+		return e.octet('T').longlong(uint64(v.Unix()))
+	case amqp.Table:
+		return e.octet('F').table(v)
+	case []byte:
+		return e.octet('x').longstr(string(v))
+	default:
+		return e.octet('V')
+	}
+}
+
+// properties the properties of a message - as published along with the raw
+// property flags + list, so they are delivered exactly as published.
+type properties struct {
+	raw []byte
+
+	ContentType     string
+	ContentEncoding string
+	Headers         amqp.Table
+	DeliveryMode    uint8
+	Priority        uint8
+	CorrelationID   string
+	ReplyTo         string
+	Expiration      string
+	MessageID       string
+	Timestamp       time.Time
+	Type            string
+	UserID          string
+	AppID           string
+}
+
+// property flags of the basic class - in the order properties are listed.
+const (
+	flagContentType     = 0x8000
+	flagContentEncoding = 0x4000
+	flagHeaders         = 0x2000
+	flagDeliveryMode    = 0x1000
+	flagPriority        = 0x0800
+	flagCorrelationID   = 0x0400
+	flagReplyTo         = 0x0200
+	flagExpiration      = 0x0100
+	flagMessageID       = 0x0080
+	flagTimestamp       = 0x0040
+	flagType            = 0x0020
+	flagUserID          = 0x0010
+	flagAppID           = 0x0008
+)
+
+// parseProperties parses the property flags + list of a content header.
+func parseProperties(raw []byte) (properties, error) {
+	props := properties{raw: raw}
+	d := newDecoder(raw)
+
+	flags := d.short()
+	has := func(flag uint16) bool { return flags&flag != 0 }
+
+	if has(flagContentType) {
+		props.ContentType = d.shortstr()
+	}
+	if has(flagContentEncoding) {
+		props.ContentEncoding = d.shortstr()
+	}
+	if has(flagHeaders) {
+		props.Headers = d.table()
+	}
+	if has(flagDeliveryMode) {
+		props.DeliveryMode = d.octet()
+	}
+	if has(flagPriority) {
+		props.Priority = d.octet()
+	}
+	if has(flagCorrelationID) {
+		props.CorrelationID = d.shortstr()
+	}
+	if has(flagReplyTo) {
+		props.ReplyTo = d.shortstr()
+	}
+	if has(flagExpiration) {
+		props.Expiration = d.shortstr()
+	}
+	if has(flagMessageID) {
+		props.MessageID = d.shortstr()
+	}
+	if has(flagTimestamp) {
+		props.Timestamp = time.Unix(int64(d.timestamp()), 0)
+	}
+	if has(flagType) {
+		props.Type = d.shortstr()
+	}
+	if has(flagUserID) {
+		props.UserID = d.shortstr()
+	}
+	if has(flagAppID) {
+		props.AppID = d.shortstr()
+	}
+
+	return props, d.err
+}
@@ -0,0 +1,578 @@
+package amqptest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// NewServer starts a server listening on a random port of localhost.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener:  listener,
+		exchanges: make(map[string]*exchange),
+		queues:    make(map[string]*queue),
+		conns:     make(map[*connection]struct{}),
+	}
+
+	// exchanges every RabbitMQ vhost comes with
+	for name, kind := range map[string]string{
+		"amq.direct":  amqp.ExchangeDirect,
+		"amq.fanout":  amqp.ExchangeFanout,
+		"amq.topic":   amqp.ExchangeTopic,
+		"amq.headers": amqp.ExchangeHeaders,
+		"amq.match":   amqp.ExchangeHeaders,
+	} {
+		s.exchanges[name] = &exchange{name: name, kind: kind}
+	}
+
+	s.wg.Add(1)
+	go s.accept()
+
+	return s, nil
+}
+
+// Host the host the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Port the port the server listens on.
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// URL the URL to dial the server with.
+func (s *Server) URL() string {
+	return fmt.Sprintf("amqp://guest:guest@%s/", s.listener.Addr())
+}
+
+// Close stops the server, dropping all connections.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+
+	err := s.listener.Close()
+	s.DropConnections()
+	s.wg.Wait()
+
+	return err
+}
+
+// DropConnections drops every client connection without closing it - as
+// when the network fails or RabbitMQ goes down. Messages unacked are
+// returned to their queues, as RabbitMQ does.
+func (s *Server) DropConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for c := range s.conns {
+		_ = c.conn.Close()
+	}
+}
+
+// Connections no. of client connections open.
+func (s *Server) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.conns)
+}
+
+// Messages the messages of a queue ready to be delivered - in the order
+// they would be. Messages delivered but not settled yet aren't included.
+func (s *Server) Messages(queue string) []Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	q, exists := s.queues[queue]
+	if !exists {
+		return nil
+	}
+
+	messages := make([]Message, 0, len(q.ready))
+	for _, m := range q.ready {
+		messages = append(messages, Message{
+			Exchange:        m.exchange,
+			RoutingKey:      m.routingKey,
+			ContentType:     m.props.ContentType,
+			ContentEncoding: m.props.ContentEncoding,
+			Headers:         m.props.Headers,
+			Priority:        m.priority,
+			MessageID:       m.props.MessageID,
+			Redelivered:     m.redelivered,
+			Body:            m.body,
+		})
+	}
+
+	return messages
+}
+
+// Consumers no. of consumers of a queue.
+func (s *Server) Consumers(queue string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if q, exists := s.queues[queue]; exists {
+		return len(q.consumers)
+	}
+
+	return 0
+}
+
+// QueueArguments the arguments a queue was declared with - false if it hasn't been.
+func (s *Server) QueueArguments(queue string) (amqp.Table, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	q, exists := s.queues[queue]
+	if !exists {
+		return nil, false
+	}
+
+	return q.args, true
+}
+
+// Bound reports if a queue is bound to an exchange with key.
+func (s *Server) Bound(exchange, queue, key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ex, exists := s.exchanges[exchange]
+	if !exists {
+		return false
+	}
+
+	for _, b := range ex.bindings {
+		if b.queue == queue && b.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &connection{
+			server:   s,
+			conn:     conn,
+			out:      newOutbox(),
+			channels: make(map[uint16]*channel),
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.mutex.Unlock()
+
+		s.wg.Add(2)
+		go c.write()
+		go c.serve()
+	}
+}
+
+// serve reads + handles the frames of a connection until it is closed. Once
+// closed, the channels of the connection are closed, returning any messages
+// they had unacked to their queues.
+func (c *connection) serve() {
+	defer c.server.wg.Done()
+	defer c.out.close()
+
+	defer func() {
+		c.server.mutex.Lock()
+		defer c.server.mutex.Unlock()
+
+		for _, ch := range c.channels {
+			c.server.closeChannel(ch)
+		}
+		delete(c.server.conns, c)
+	}()
+
+	r := bufio.NewReader(c.conn)
+	if err := c.handshake(r); err != nil {
+		return
+	}
+
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			return
+		}
+
+		c.server.mutex.Lock()
+		closed, err := c.handle(f)
+		c.server.mutex.Unlock()
+
+		if closed {
+			return
+		}
+
+		var e *exception
+		if errors.As(err, &e) {
+			c.raise(f.channel, e, f)
+		}
+	}
+}
+
+// handshake negotiates + opens a connection. Credentials and vhosts are not checked.
+func (c *connection) handshake(r *bufio.Reader) error {
+	header := make([]byte, len(protocolHeader))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(header, protocolHeader) {
+		c.out.push(protocolHeader)
+		return fmt.Errorf("unsupported protocol %q", header)
+	}
+
+	c.send(0, connectionStart, newEncoder().
+		octet(0).
+		octet(9).
+		table(amqp.Table{
+			"product": "amqptest",
+			"capabilities": amqp.Table{
+				"publisher_confirms":     true,
+				"basic.nack":             true,
+				"consumer_cancel_notify": true,
+				"per_consumer_qos":       true,
+			},
+		}).
+		longstr("PLAIN AMQPLAIN").
+		longstr("en_US"))
+
+	if _, err := c.expect(r, connectionStartOk); err != nil {
+		return err
+	}
+
+	c.send(0, connectionTune, newEncoder().short(2047).long(frameMax).short(0))
+
+	tune, err := c.expect(r, connectionTuneOk)
+	if err != nil {
+		return err
+	}
+	tune.short()
+	tune.long()
+
+	// heartbeats are sent at the interval the client asked for
+	if heartbeat := time.Duration(tune.short()) * time.Second; heartbeat > 0 {
+		c.server.wg.Add(1)
+		go c.heartbeat(heartbeat)
+	}
+
+	if _, err := c.expect(r, connectionOpen); err != nil {
+		return err
+	}
+
+	c.send(0, connectionOpenOk, newEncoder().shortstr(""))
+
+	return nil
+}
+
+// expect reads the next method of the handshake, failing if it isn't m.
+func (c *connection) expect(r *bufio.Reader, m method) (*decoder, error) {
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.typ == frameHeartbeat {
+			continue
+		}
+
+		d := newDecoder(f.payload)
+		if got := method(uint32(d.short())<<16 | uint32(d.short())); f.typ != frameMethod || got != m {
+			return nil, fmt.Errorf("expected method %#x, got %#x", m, got)
+		}
+
+		return d, d.err
+	}
+}
+
+// handle handles a frame - reporting if the connection has been closed.
+func (c *connection) handle(f frame) (closed bool, err error) {
+	switch f.typ {
+	case frameHeartbeat:
+		return false, nil
+	case frameHeader, frameBody:
+		if c.closing {
+			return false, nil
+		}
+		return false, c.handleContent(f)
+	case frameMethod:
+	default:
+		return false, connectionException(replyFrameError, "FRAME_ERROR - unknown frame type %d", f.typ)
+	}
+
+	d := newDecoder(f.payload)
+	m := method(uint32(d.short())<<16 | uint32(d.short()))
+	if d.err != nil {
+		return false, connectionException(replySyntaxError, "SYNTAX_ERROR - method frame too short")
+	}
+
+	switch m {
+	case connectionClose:
+		// the client closes the socket once it receives close-ok
+		for _, ch := range c.channels {
+			c.server.closeChannel(ch)
+		}
+		c.channels = make(map[uint16]*channel)
+		c.closing = true
+		c.send(0, connectionCloseOk, nil)
+		return false, nil
+	case connectionCloseOk:
+		return true, nil
+	}
+
+	// only the close handshake is completed once closing
+	if c.closing {
+		return false, nil
+	}
+
+	if f.channel == 0 {
+		return false, connectionException(replyCommandInvalid, "COMMAND_INVALID - unexpected method %#x on channel 0", m)
+	}
+
+	if m == channelOpen {
+		if _, exists := c.channels[f.channel]; exists {
+			return false, connectionException(replyChannelError, "CHANNEL_ERROR - second 'channel.open' seen")
+		}
+
+		c.channels[f.channel] = &channel{
+			id:        f.channel,
+			conn:      c,
+			unacked:   make(map[uint64]*delivery),
+			consumers: make(map[string]*consumer),
+		}
+		c.send(f.channel, channelOpenOk, newEncoder().longstr(""))
+		return false, nil
+	}
+
+	ch, exists := c.channels[f.channel]
+	if !exists {
+		return false, connectionException(replyChannelError, "CHANNEL_ERROR - expected 'channel.open'")
+	}
+
+	switch m {
+	case channelClose:
+		c.server.closeChannel(ch)
+		delete(c.channels, ch.id)
+		c.send(ch.id, channelCloseOk, nil)
+		return false, nil
+	case channelCloseOk:
+		delete(c.channels, ch.id)
+		return false, nil
+	}
+
+	// only the close handshake is completed once closing
+	if ch.closing {
+		return false, nil
+	}
+
+	if err := c.server.handleMethod(ch, m, d); err != nil {
+		return false, err
+	}
+
+	if d.err != nil {
+		return false, connectionException(replySyntaxError, "SYNTAX_ERROR - malformed method %#x", m)
+	}
+
+	return false, nil
+}
+
+// handleContent handles the content header + body frames of a message published.
+func (c *connection) handleContent(f frame) error {
+	ch, exists := c.channels[f.channel]
+	if !exists || ch.publishing == nil {
+		if exists && ch.closing {
+			return nil
+		}
+		return connectionException(replyUnexpectedFrame, "UNEXPECTED_FRAME - content frame without 'basic.publish'")
+	}
+
+	pub := ch.publishing
+
+	if f.typ == frameHeader {
+		d := newDecoder(f.payload)
+		d.short()
+		d.short()
+		pub.size = d.longlong()
+
+		props, err := parseProperties(d.buf)
+		if d.err != nil || err != nil {
+			return connectionException(replySyntaxError, "SYNTAX_ERROR - malformed content header")
+		}
+		pub.props = props
+	} else {
+		pub.body = append(pub.body, f.payload...)
+	}
+
+	switch {
+	case uint64(len(pub.body)) > pub.size:
+		return connectionException(replyFrameError, "FRAME_ERROR - body exceeds the size of %d bytes", pub.size)
+	case uint64(len(pub.body)) == pub.size:
+		ch.publishing = nil
+		return c.server.publish(ch, pub)
+	}
+
+	return nil
+}
+
+// raise raises an exception raised handling a frame - closing the channel, or
+// the connection if hard. The client is expected to complete the close handshake.
+func (c *connection) raise(channelID uint16, e *exception, f frame) {
+	var class, id uint16
+	if f.typ == frameMethod && len(f.payload) >= 4 {
+		d := newDecoder(f.payload)
+		class, id = d.short(), d.short()
+	}
+
+	args := newEncoder().short(e.code).shortstr(e.text).short(class).short(id)
+
+	c.server.mutex.Lock()
+	defer c.server.mutex.Unlock()
+
+	ch, exists := c.channels[channelID]
+	if e.hard || !exists {
+		c.closing = true
+		for _, ch := range c.channels {
+			c.server.closeChannel(ch)
+		}
+		c.send(0, connectionClose, args)
+		return
+	}
+
+	ch.closing = true
+	ch.publishing = nil
+	c.server.closeChannel(ch)
+	c.send(ch.id, channelClose, args)
+}
+
+// send sends a method.
+func (c *connection) send(channelID uint16, m method, args *encoder) {
+	c.out.push(methodFrame(channelID, m, args))
+}
+
+// sendContent sends a method along with a message.
+func (c *connection) sendContent(channelID uint16, m method, args *encoder, msg *message) {
+	c.out.push(contentFrames(channelID, m, args, msg))
+}
+
+// heartbeat sends heartbeats at an interval until the connection is closed.
+func (c *connection) heartbeat(interval time.Duration) {
+	defer c.server.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.out.push(appendFrame(nil, frameHeartbeat, 0, nil))
+		case <-c.out.done:
+			return
+		}
+	}
+}
+
+// write writes the frames pushed to the outbox. Once the outbox is closed
+// any frames pending are written and the connection is closed.
+func (c *connection) write() {
+	defer c.server.wg.Done()
+	defer c.conn.Close()
+
+	w := bufio.NewWriter(c.conn)
+	for {
+		done := false
+		select {
+		case <-c.out.wake:
+		case <-c.out.done:
+			done = true
+		}
+
+		for _, frames := range c.out.take() {
+			if _, err := w.Write(frames); err != nil {
+				return
+			}
+		}
+
+		if err := w.Flush(); err != nil || done {
+			return
+		}
+	}
+}
+
+func newOutbox() *outbox {
+	return &outbox{
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// push queues frames to be sent - in one piece.
+func (o *outbox) push(frames []byte) {
+	o.mutex.Lock()
+	o.pending = append(o.pending, frames)
+	o.mutex.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take takes all frames pending.
+func (o *outbox) take() [][]byte {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	pending := o.pending
+	o.pending = nil
+
+	return pending
+}
+
+func (o *outbox) close() {
+	o.once.Do(func() { close(o.done) })
+}
+
+func (e *exception) Error() string {
+	return e.text
+}
+
+// channelException an exception closing the channel it was raised on.
+func channelException(code uint16, format string, args ...interface{}) *exception {
+	return &exception{code: code, text: fmt.Sprintf(format, args...)}
+}
+
+// connectionException an exception closing the whole connection.
+func connectionException(code uint16, format string, args ...interface{}) *exception {
+	return &exception{code: code, text: fmt.Sprintf(format, args...), hard: true}
+}
@@ -0,0 +1,266 @@
+package amqptest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func dial(t *testing.T) (*Server, *amqp.Connection, *amqp.Channel) {
+	server, err := NewServer()
+	assert.Nil(t, err)
+
+	conn, err := amqp.Dial(server.URL())
+	assert.Nil(t, err)
+
+	ch, err := conn.Channel()
+	assert.Nil(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		_ = server.Close()
+	})
+
+	return server, conn, ch
+}
+
+func receive(t *testing.T, msgs <-chan amqp.Delivery) amqp.Delivery {
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(time.Second):
+		assert.Fail(t, "no message delivered")
+		return amqp.Delivery{}
+	}
+}
+
+func TestPublishConsumeByPriority(t *testing.T) {
+	server, conn, ch := dial(t)
+
+	_, err := ch.QueueDeclare("catalog", true, false, false, false, amqp.Table{"x-max-priority": 5})
+	assert.Nil(t, err)
+
+	for _, p := range []uint8{1, 9, 3} {
+		err := ch.Publish("", "catalog", false, false, amqp.Publishing{
+			Headers:  amqp.Table{"priority": int32(p)},
+			Priority: p,
+			Body:     []byte(strings.Repeat("x", frameMax)), // split across body frames
+		})
+		assert.Nil(t, err)
+	}
+
+	// capped to the max priority of the queue
+	assert.Eventually(t, func() bool { return len(server.Messages("catalog")) == 3 }, time.Second, time.Millisecond)
+	messages := server.Messages("catalog")
+	assert.Equal(t, []uint8{5, 3, 1}, []uint8{messages[0].Priority, messages[1].Priority, messages[2].Priority})
+	assert.Equal(t, amqp.Table{"priority": int32(9)}, messages[0].Headers)
+	assert.Len(t, messages[0].Body, frameMax)
+
+	assert.Nil(t, ch.Qos(1, 0, true))
+	msgs, err := ch.Consume("catalog", "consumer", false, false, false, false, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, server.Consumers("catalog"))
+
+	// one at a time as prefetch is 1
+	for i, p := range []int32{9, 3, 1} {
+		msg := receive(t, msgs)
+		assert.Equal(t, p, msg.Headers["priority"])
+		assert.Len(t, msg.Body, frameMax)
+		assert.Len(t, server.Messages("catalog"), 2-i)
+		assert.Nil(t, msg.Ack(false))
+	}
+
+	// redeclaring with other arguments fails
+	_, err = ch.QueueDeclare("catalog", true, false, false, false, nil)
+	assert.Equal(t, replyPreconditionFailed, err.(*amqp.Error).Code)
+
+	_, err = conn.Channel()
+	assert.Nil(t, err)
+}
+
+func TestNackRejectAndDrop(t *testing.T) {
+	server, conn, ch := dial(t)
+
+	_, err := ch.QueueDeclare("catalog", true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": "deadletter-catalog",
+	})
+	assert.Nil(t, err)
+	_, err = ch.QueueDeclare("deadletter-catalog", true, false, false, false, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, ch.Publish("", "catalog", false, false, amqp.Publishing{Body: []byte("1")}))
+
+	msgs, err := ch.Consume("catalog", "", false, false, false, false, nil)
+	assert.Nil(t, err)
+
+	msg := receive(t, msgs)
+	assert.False(t, msg.Redelivered)
+	assert.Nil(t, msg.Nack(false, true))
+
+	msg = receive(t, msgs)
+	assert.True(t, msg.Redelivered)
+	assert.Nil(t, msg.Reject(false))
+
+	// dead lettered
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-catalog")) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []byte("1"), server.Messages("deadletter-catalog")[0].Body)
+
+	// unacked messages are requeued once the connection is dropped
+	assert.Nil(t, ch.Publish("", "catalog", false, false, amqp.Publishing{Body: []byte("2")}))
+	msg = receive(t, msgs)
+	assert.Equal(t, []byte("2"), msg.Body)
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	server.DropConnections()
+	assert.NotNil(t, <-closed)
+
+	assert.Eventually(t, func() bool { return len(server.Messages("catalog")) == 1 }, time.Second, time.Millisecond)
+	assert.True(t, server.Messages("catalog")[0].Redelivered)
+	assert.Eventually(t, func() bool { return server.Connections() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestGlobalQosRefusedByQuorumQueues(t *testing.T) {
+	server, conn, ch := dial(t)
+
+	_, err := ch.QueueDeclare("catalog", true, false, false, false, amqp.Table{"x-queue-type": "quorum"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, ch.Qos(1, 0, true))
+	_, err = ch.Consume("catalog", "consumer", false, false, false, false, nil)
+	assert.Equal(t, replyPreconditionFailed, err.(*amqp.Error).Code)
+
+	// a prefetch per consumer is fine
+	ch, err = conn.Channel()
+	assert.Nil(t, err)
+	assert.Nil(t, ch.Qos(1, 0, false))
+	_, err = ch.Consume("catalog", "consumer", false, false, false, false, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, server.Consumers("catalog"))
+}
+
+func TestUnknownDeliveryTag(t *testing.T) {
+	_, _, ch := dial(t)
+
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	assert.Nil(t, ch.Ack(42, false))
+
+	err := <-closed
+	assert.Equal(t, replyPreconditionFailed, err.Code)
+	assert.Equal(t, "PRECONDITION_FAILED - unknown delivery tag 42", err.Reason)
+}
+
+func TestConfirmsAndReturns(t *testing.T) {
+	server, _, ch := dial(t)
+
+	assert.Nil(t, ch.ExchangeDeclare("catalog.topic", amqp.ExchangeTopic, true, false, false, false, nil))
+	_, err := ch.QueueDeclare("catalog", true, false, false, false, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, ch.QueueBind("catalog", "product.*.ae-en", "catalog.topic", false, nil))
+	assert.True(t, server.Bound("catalog.topic", "catalog", "product.*.ae-en"))
+
+	assert.Nil(t, ch.Confirm(false))
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 2))
+	returns := ch.NotifyReturn(make(chan amqp.Return, 1))
+
+	assert.Nil(t, ch.Publish("catalog.topic", "product.updated.ae-en", true, false, amqp.Publishing{Body: []byte("routed")}))
+	assert.Nil(t, ch.Publish("catalog.topic", "product.updated.sa-ar", true, false, amqp.Publishing{Body: []byte("unroutable")}))
+
+	assert.Equal(t, amqp.Confirmation{DeliveryTag: 1, Ack: true}, <-confirms)
+	r := <-returns
+	assert.Equal(t, uint16(replyNoRoute), r.ReplyCode)
+	assert.Equal(t, []byte("unroutable"), r.Body)
+	assert.Equal(t, amqp.Confirmation{DeliveryTag: 2, Ack: true}, <-confirms)
+
+	msg, ok, err := ch.Get("catalog", true)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "product.updated.ae-en", msg.RoutingKey)
+	assert.Equal(t, []byte("routed"), msg.Body)
+
+	_, ok, err = ch.Get("catalog", true)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// binding to the default exchange is refused
+	err = ch.QueueBind("catalog", "catalog", "", false, nil)
+	assert.Equal(t, replyAccessRefused, err.(*amqp.Error).Code)
+}
+
+func TestMessageTTL(t *testing.T) {
+	server, _, ch := dial(t)
+
+	_, err := ch.QueueDeclare("retry-catalog", true, false, false, false, amqp.Table{
+		"x-message-ttl":             int64(10),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": "catalog",
+	})
+	assert.Nil(t, err)
+	_, err = ch.QueueDeclare("catalog", true, false, false, false, nil)
+	assert.Nil(t, err)
+
+	assert.Nil(t, ch.Publish("", "retry-catalog", false, false, amqp.Publishing{Body: []byte("retried")}))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("catalog")) == 1 }, time.Second, time.Millisecond)
+	assert.Empty(t, server.Messages("retry-catalog"))
+	assert.Equal(t, "catalog", server.Messages("catalog")[0].RoutingKey)
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		matches bool
+	}{
+		{"product.*.ae-en", "product.updated.ae-en", true},
+		{"product.*.ae-en", "product.updated.sa-ar", false},
+		{"product.*", "product.updated.ae-en", false},
+		{"product.#", "product.updated.ae-en", true},
+		{"product.#", "product", true},
+		{"#.ae-en", "product.updated.ae-en", true},
+		{"#", "", true},
+		{"product.#.ae-en", "product.ae-en", true},
+		{"*", "", true},
+		{"*.*", "product", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.matches, topicMatches(strings.Split(test.pattern, "."), strings.Split(test.key, ".")), test.pattern+" "+test.key)
+	}
+}
+
+func TestHeadersMatch(t *testing.T) {
+	headers := amqp.Table{"store": "ae-en", "entity": "product"}
+
+	assert.True(t, headersMatch(amqp.Table{"store": "ae-en"}, headers))
+	assert.True(t, headersMatch(amqp.Table{"store": "ae-en", "entity": "product", "x-match": "all"}, headers))
+	assert.False(t, headersMatch(amqp.Table{"store": "ae-en", "entity": "category"}, headers))
+	assert.True(t, headersMatch(amqp.Table{"store": "sa-ar", "entity": "product", "x-match": "any"}, headers))
+	assert.False(t, headersMatch(amqp.Table{"store": "sa-ar", "x-match": "any"}, headers))
+}
+
+func TestFieldTableRoundTrip(t *testing.T) {
+	table := amqp.Table{
+		"bool":    true,
+		"byte":    byte(1),
+		"int16":   int16(2),
+		"int32":   int32(3),
+		"int64":   int64(4),
+		"float32": float32(5.5),
+		"float64": 6.5,
+		"decimal": amqp.Decimal{Scale: 2, Value: 700},
+		"string":  "eight",
+		"array":   []interface{}{"nine", int32(10)},
+		"time":    time.Unix(11, 0),
+		"table":   amqp.Table{"twelve": int64(12)},
+		"bytes":   []byte("thirteen"),
+		"void":    nil,
+	}
+
+	d := newDecoder(newEncoder().table(table).bytes())
+	assert.Equal(t, table, d.table())
+	assert.Nil(t, d.err)
+}
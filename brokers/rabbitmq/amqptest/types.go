@@ -0,0 +1,177 @@
+package amqptest
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Server a lightweight, in-process stand-in for a RabbitMQ server speaking AMQP
+// 0-9-1 over TCP - for testing clients end to end without a real broker. It
+// supports what clients of this lib rely on:
+//
+//   - direct, fanout, topic + headers exchanges and queue bindings
+//   - publishing, consuming with prefetch, basic.get and ack/nack/reject
+//   - publisher confirms + returns of unroutable mandatory messages
+//   - priority queues (x-max-priority)
+//   - message TTLs (x-message-ttl) + dead lettering (x-dead-letter-exchange/routing-key)
+//
+// Everything lives in memory and a single vhost. Credentials aren't checked.
+type Server struct {
+	mutex sync.Mutex
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	exchanges map[string]*exchange
+	queues    map[string]*queue
+	conns     map[*connection]struct{}
+
+	// for generating queue names + consumer tags, and ordering messages
+	seq uint64
+
+	closed bool
+}
+
+// Message a message in a queue.
+type Message struct {
+	Exchange        string
+	RoutingKey      string
+	ContentType     string
+	ContentEncoding string
+	Headers         amqp.Table
+	Priority        uint8
+	MessageID       string
+	Redelivered     bool
+	Body            []byte
+}
+
+// exchange an exchange messages are routed by.
+type exchange struct {
+	name     string
+	kind     string
+	bindings []binding
+}
+
+// binding a binding of a queue to an exchange.
+type binding struct {
+	queue string
+	key   string
+	args  amqp.Table
+}
+
+// queue a queue - messages ready to be delivered are ordered by priority, then
+// the order they were published in.
+type queue struct {
+	name string
+	args amqp.Table
+
+	maxPriority uint8
+	ttl         time.Duration
+
+	ready     []*message
+	consumers []*consumer
+
+	// consumer to deliver to next - round robin
+	next int
+}
+
+// message a message published.
+type message struct {
+	exchange   string
+	routingKey string
+	props      properties
+	body       []byte
+
+	priority    uint8
+	seq         uint64
+	redelivered bool
+
+	// expires the message once its TTL is up - if still in the queue
+	expiry *time.Timer
+}
+
+// connection a client connection. Frames are read + handled one at a time, and
+// sent via the outbox so the server never blocks on a slow client.
+type connection struct {
+	server *Server
+	conn   net.Conn
+	out    *outbox
+
+	channels map[uint16]*channel
+
+	// a hard exception was raised - awaiting the client's connection.close-ok
+	closing bool
+}
+
+// outbox the frames pending to be sent on a connection.
+type outbox struct {
+	mutex   sync.Mutex
+	pending [][]byte
+
+	wake chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// channel a channel of a connection.
+type channel struct {
+	id   uint16
+	conn *connection
+
+	// max no. of unacked deliveries - no limit if 0. global if shared by all
+	// consumers of the channel, which quorum queues and streams don't support
+	prefetch  int
+	globalQos bool
+
+	// publisher confirms enabled + no. of messages published since
+	confirm    bool
+	publishSeq uint64
+
+	// tag of the last delivery + deliveries not settled yet
+	deliveryTag uint64
+	unacked     map[uint64]*delivery
+
+	consumers map[string]*consumer
+
+	// message being published - awaiting its content
+	publishing *publishing
+
+	// an exception was raised - awaiting the client's channel.close-ok
+	closing bool
+}
+
+// consumer a consumer of a queue.
+type consumer struct {
+	tag     string
+	channel *channel
+	queue   *queue
+	noAck   bool
+}
+
+// delivery a message delivered on a channel.
+type delivery struct {
+	queue   *queue
+	message *message
+}
+
+// publishing a message being published, whose content is being received.
+type publishing struct {
+	exchange   string
+	routingKey string
+	mandatory  bool
+
+	size  uint64
+	props properties
+	body  []byte
+}
+
+// exception an AMQP exception - closing the channel it was raised on, or the
+// whole connection if hard.
+type exception struct {
+	code uint16
+	text string
+	hard bool
+}
@@ -0,0 +1,280 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/uuid"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// PublishBatch publishes events of any type to a queue, encoding them with c and
+// routing each as Publish does. The events are pipelined on a channel of their own
+// in confirm mode - all are published before waiting for RMQ to confirm them - so
+// a batch takes about as long as publishing a single event with confirms.
+//
+// The outcome of publishing each event is returned in the order of events - nil
+// once confirmed, otherwise why it failed e.g errors.ErrorPublishNacked. A span is
+// emitted for the batch, with a child span for every event published that is
+// propagated in its headers, so the spans of consuming them are its children.
+func PublishBatch[T any](ctx context.Context, rmq *RMQClient, queue string, priority int, events []*T, c codec.Codec) []error {
+	return publishBatch(ctx, rmq, queue, priority, events, c, func(event *T) (routing, error) {
+		return queueConfig(queue).route(event)
+	})
+}
+
+// PublishBatch publishes events to a queue, returning the outcome of publishing
+// each - see PublishBatch. Ideal for bulk syncs/migrations publishing thousands of events.
+func (rmq *RMQClient) PublishBatch(ctx context.Context, queue string, priority int, events []*base.EyewaEvent) []error {
+	return PublishBatch(ctx, rmq, queue, priority, events, codec.JSON{})
+}
+
+// PublishMagentoProductEventBatch publishes magento product events to a queue,
+// returning the outcome of publishing each - see PublishBatch.
+func (rmq *RMQClient) PublishMagentoProductEventBatch(ctx context.Context, queue string, priority int, events []*base.MagentoProductEvent) []error {
+	return publishBatch(ctx, rmq, queue, priority, events, codec.JSON{}, func(*base.MagentoProductEvent) (routing, error) {
+		return routing{key: queue}, nil
+	})
+}
+
+func publishBatch[T any](ctx context.Context, rmq *RMQClient, queue string, priority int, events []*T, c codec.Codec, route func(*T) (routing, error)) []error {
+	results := make([]error, len(events))
+	if len(events) == 0 {
+		return results
+	}
+
+	fail := func(err error) []error {
+		for i := range results {
+			results[i] = err
+		}
+		return results
+	}
+
+	if rmq.currentConnection() == nil {
+		return fail(libErrs.ErrorNoRMQConnection)
+	}
+
+	// set amqp message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingDestinationKey.String(queue),
+			attribute.Int("messaging.batch.message_count", len(events))),
+		trace.WithSpanKind(trace.SpanKindProducer),
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.PublishBatch", spanOpts...)
+	defer span.End()
+
+	batch, err := rmq.batchConfirmer(queue, len(events))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fail(err)
+	}
+	defer batch.channel.Close()
+
+	// index of the event published with each delivery tag - tags start at 1
+	published := make([]int, 0, len(events))
+	for i, event := range events {
+		if results[i] = publishBatched(ctx, batch, queue, priority, event, c, route); results[i] == nil {
+			published = append(published, i)
+		}
+	}
+
+	for tag, err := range batch.waitAll(len(published), confirmTimeout()) {
+		results[published[tag]] = err
+	}
+
+	failed := 0
+	for i, err := range results {
+		if err != nil {
+			failed++
+			go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", base.EventNameOf(events[i])))
+			continue
+		}
+
+		go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", base.EventNameOf(events[i])))
+	}
+
+	span.SetAttributes(attribute.Int("messaging.batch.failure_count", failed))
+	if failed > 0 {
+		err := fmt.Errorf(libErrs.ErrorBatchPublishFailure.Error(), failed, len(events))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.ErrorWithTraceID(span.SpanContext().TraceID().String(), err.Error(), zap.String("queue", queue))
+	}
+
+	return results
+}
+
+// batchConfirmer opens a channel in confirm mode to publish a batch of n messages
+// to a queue on, declaring the queue. Confirms + returns are buffered for the
+// whole batch, so none are dropped while the batch is being published.
+func (rmq *RMQClient) batchConfirmer(queue string, n int) (*confirmer, error) {
+	channel, err := rmq.currentConnection().Channel()
+	if err != nil {
+		return nil, fmt.Errorf(libErrs.ErrorChannelCreateFailure.Error(), queue, err)
+	}
+
+	if err := rmq.declareQueue(channel, queueConfig(queue)); err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	return &confirmer{
+		channel:     channel,
+		confirms:    channel.NotifyPublish(make(chan amqp.Confirmation, n)),
+		returns:     channel.NotifyReturn(make(chan amqp.Return, n)),
+		nextTag:     1,
+		messageTags: make(map[string]uint64, n),
+	}, nil
+}
+
+// publishBatched publishes an event of a batch without waiting for RMQ to confirm
+// it. A span is started for the event as a child of the batch's span in ctx and
+// propagated in the message's headers.
+func publishBatched[T any](ctx context.Context, batch *confirmer, queue string, priority int, event *T, c codec.Codec, route func(*T) (routing, error)) (err error) {
+	r, err := route(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.Publish",
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingDestinationKey.String(queue),
+			semconv.MessagingRabbitMQRoutingKeyKey.String(r.key)),
+		trace.WithSpanKind(trace.SpanKindProducer))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	encoded, err := encodeEvent(queue, event, c)
+	if err != nil {
+		go standardMetrics.MarshalEventFailureCounter.Add(1)
+		return err
+	}
+
+	headers := withTraceContext(ctx, withHeaders(r.headers, encoded.Headers))
+
+	// returns are matched to messages by their message id - see waitAll
+	messageID := uuid.NewString()
+
+	err = batch.channel.Publish(r.exchange, r.key, true, false, amqp.Publishing{
+		MessageId:       messageID,
+		Headers:         headers,
+		ContentType:     encoded.ContentType,
+		ContentEncoding: encoded.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        uint8(priority),
+		Body:            encoded.Body,
+	})
+	if err != nil {
+		return publishError(err)
+	}
+
+	batch.messageTags[messageID] = batch.nextTag
+	batch.nextTag++
+
+	return nil
+}
+
+// waitAll waits for RMQ to confirm n messages published since the channel was
+// put in confirm mode, returning the outcome of each by delivery tag - from 1.
+// Messages not confirmed within timeout of calling waitAll time out.
+//
+// Returns are matched to messages by their message id - unique per message of
+// the batch - as returns of later messages may be received before the confirms
+// of earlier ones.
+func (c *confirmer) waitAll(n int, timeout time.Duration) []error {
+	results := make([]error, n)
+	for i := range results {
+		results[i] = libErrs.ErrorPublishConfirmTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	returned := make(map[uint64]bool)
+	markReturned := func(r amqp.Return) {
+		if tag, ok := c.messageTags[r.MessageId]; ok {
+			returned[tag] = true
+		}
+	}
+
+	for confirmed := 0; confirmed < n; {
+		select {
+		case r := <-c.returns:
+			markReturned(r)
+
+		case confirmation, ok := <-c.confirms:
+			if !ok {
+				for i, err := range results {
+					if err == libErrs.ErrorPublishConfirmTimeout {
+						results[i] = libErrs.ErrorChannelDoesNotExist
+					}
+				}
+				return results
+			}
+
+			// the broker sends a return right before the ack of the same message,
+			// pick up any received since
+			for pending := true; pending; {
+				select {
+				case r := <-c.returns:
+					markReturned(r)
+				default:
+					pending = false
+				}
+			}
+
+			tag := int(confirmation.DeliveryTag) - 1
+			if tag < 0 || tag >= n {
+				continue
+			}
+			confirmed++
+
+			switch {
+			case !confirmation.Ack:
+				go standardMetrics.NackedEventCounter.Add(1)
+				results[tag] = libErrs.ErrorPublishNacked
+			case returned[confirmation.DeliveryTag]:
+				go standardMetrics.ReturnedEventCounter.Add(1)
+				results[tag] = libErrs.ErrorPublishReturned
+			default:
+				go standardMetrics.ConfirmedEventCounter.Add(1)
+				results[tag] = nil
+			}
+
+		case <-timer.C:
+			go standardMetrics.ConfirmTimeoutCounter.Add(float64(n - confirmed))
+			return results
+		}
+	}
+
+	return results
+}
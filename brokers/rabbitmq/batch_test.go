@@ -0,0 +1,77 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfirmerWaitAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns []amqp.Return
+		confirm []amqp.Confirmation
+		errs    []error
+	}{
+		{
+			name:    "acked",
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}, {DeliveryTag: 2, Ack: true}, {DeliveryTag: 3, Ack: true}},
+			errs:    []error{nil, nil, nil},
+		},
+		{
+			name:    "nacked",
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}, {DeliveryTag: 2, Ack: false}, {DeliveryTag: 3, Ack: true}},
+			errs:    []error{nil, libErrs.ErrorPublishNacked, nil},
+		},
+		{
+			// the return of 3 is received before the acks of 1 + 2
+			name:    "returned",
+			returns: []amqp.Return{{MessageId: "c", ReplyCode: amqp.NoRoute}},
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}, {DeliveryTag: 2, Ack: true}, {DeliveryTag: 3, Ack: true}},
+			errs:    []error{nil, nil, libErrs.ErrorPublishReturned},
+		},
+		{
+			name:    "timed out",
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}},
+			errs:    []error{nil, libErrs.ErrorPublishConfirmTimeout, libErrs.ErrorPublishConfirmTimeout},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfirmer()
+			c.messageTags = map[string]uint64{"a": 1, "b": 2, "c": 3}
+			for _, r := range tt.returns {
+				c.returns <- r
+			}
+			for _, conf := range tt.confirm {
+				c.confirms <- conf
+			}
+
+			assert.Equal(t, tt.errs, c.waitAll(3, 50*time.Millisecond))
+		})
+	}
+}
+
+func TestConfirmerWaitAllChannelClosed(t *testing.T) {
+	c := newTestConfirmer()
+	c.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	close(c.confirms)
+
+	assert.Equal(t, []error{nil, libErrs.ErrorChannelDoesNotExist}, c.waitAll(2, 50*time.Millisecond))
+}
+
+func TestPublishBatchWithoutConnection(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	rmq := NewRMQClient()
+
+	assert.Empty(t, rmq.PublishBatch(context.Background(), "catalog", 0, nil))
+
+	errs := rmq.PublishBatch(context.Background(), "catalog", 0, []*base.EyewaEvent{{ID: "1"}, {ID: "2"}})
+	assert.Equal(t, []error{libErrs.ErrorNoRMQConnection, libErrs.ErrorNoRMQConnection}, errs)
+}
@@ -0,0 +1,202 @@
+package rabbitmq
+
+import (
+	"strings"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/cloudevents"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/streadway/amqp"
+)
+
+var defaultCloudEventsSource = "eyewa"
+
+// cloudEventsSource the source of cloud events published - RABBITMQ_CLOUD_EVENTS_SOURCE
+// or otherwise the service's name.
+func cloudEventsSource() string {
+	if config.CloudEventsSource != "" {
+		return config.CloudEventsSource
+	}
+
+	if config.ServiceName != "" {
+		return config.ServiceName
+	}
+
+	return defaultCloudEventsSource
+}
+
+// encodeEvent encodes an event published to a queue into the message carrying it.
+// If the queue publishes cloud events, the event is mapped onto one carried in
+// the queue's mode, otherwise it is encoded with the queue's codec - see queueCodec.
+// Either way it is compressed if the queue compresses events.
+func encodeEvent[T any](queue string, event *T, c codec.Codec) (amqp.Publishing, error) {
+	q := queueConfig(queue)
+	if q.CloudEvents == "" {
+		c, err := queueCodec(queue, c)
+		if err != nil {
+			return amqp.Publishing{}, err
+		}
+
+		body, err := c.Marshal(event)
+		if err != nil {
+			return amqp.Publishing{}, err
+		}
+
+		return q.compress(amqp.Publishing{ContentType: c.ContentType(), Headers: eventTypeHeaders(event), Body: body})
+	}
+
+	mode, err := cloudevents.ParseMode(q.CloudEvents)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	ce, err := cloudevents.From(event, cloudEventsSource())
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	msg, err := ce.Encode(mode)
+	if err != nil {
+		return amqp.Publishing{}, err
+	}
+
+	return q.compress(amqp.Publishing{ContentType: msg.ContentType, Headers: msg.Headers, Body: msg.Body})
+}
+
+// decodeEvent decodes the event carried by a delivery - a cloud event in either
+// mode or otherwise an event encoded with the codec of its content type - so
+// consumers accept both. See deliveryCodec. Compressed deliveries are
+// decompressed first.
+func decodeEvent[T any](msg base.Delivery, event *T, c codec.Codec) error {
+	msg, err := decompress(msg)
+	if err != nil {
+		return err
+	}
+
+	ce, mode, err := cloudevents.Decode(cloudEventsMessage(msg))
+	if mode == "" {
+		return deliveryCodec(msg.ContentType, c).Unmarshal(msg.Body, event)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return cloudevents.To(ce, event)
+}
+
+// deadletterMessage the message a failed delivery is deadlettered as, with errs
+// recorded on its event - see deadletterEvent. The delivery's headers e.g its trace
+// context, priority and id are carried over, so it is replayed as published.
+func deadletterMessage[T any](c codec.Codec, msg base.Delivery, errs []base.Error) amqp.Publishing {
+	dl := deadletterEvent[T](c, msg, errs)
+
+	// cloud event attributes are those of the event deadlettered
+	headers := make(amqp.Table, len(msg.Headers)+len(dl.Headers))
+	for k, v := range msg.Headers {
+		if !isCloudEventsHeader(k) {
+			headers[k] = v
+		}
+	}
+	for k, v := range dl.Headers {
+		headers[k] = v
+	}
+
+	dl.Headers = headers
+	dl.Priority = msg.Priority
+	dl.MessageId = msg.MessageID
+	dl.Timestamp = msg.Timestamp
+
+	return dl
+}
+
+// deadletterEvent the event a failed delivery is deadlettered as, with errs recorded
+// on it. Cloud events are deadlettered in the mode they were delivered in, other
+// events encoded with the codec they were delivered in - see withErrors. Compressed
+// events are deadlettered decompressed, unless they can't be decompressed.
+func deadletterEvent[T any](c codec.Codec, msg base.Delivery, errs []base.Error) amqp.Publishing {
+	msg, err := decompress(msg)
+	if err != nil {
+		return amqp.Publishing{ContentType: msg.ContentType, ContentEncoding: msg.ContentEncoding, Headers: cloudEventsHeaders(msg.Headers), Body: msg.Body}
+	}
+
+	ce, mode, err := cloudevents.Decode(cloudEventsMessage(msg))
+	if mode == "" {
+		c = deliveryCodec(msg.ContentType, c)
+		return amqp.Publishing{ContentType: c.ContentType(), Body: withErrors[T](c, msg.Body, errs)}
+	}
+
+	// if the event can't be mapped or doesn't record errors, it is deadlettered as is
+	asIs := amqp.Publishing{ContentType: msg.ContentType, Headers: cloudEventsHeaders(msg.Headers), Body: msg.Body}
+	if err != nil {
+		return asIs
+	}
+
+	event := new(T)
+	if err := cloudevents.To(ce, event); err != nil {
+		return asIs
+	}
+
+	recorder, ok := interface{}(event).(base.ErrorRecorder)
+	if !ok {
+		return asIs
+	}
+	recorder.RecordErrors(errs...)
+
+	mapped, err := cloudevents.From(event, ce.Source)
+	if err != nil {
+		return asIs
+	}
+
+	encoded, err := mapped.Encode(mode)
+	if err != nil {
+		return asIs
+	}
+
+	return amqp.Publishing{ContentType: encoded.ContentType, Headers: encoded.Headers, Body: encoded.Body}
+}
+
+// cloudEventsMessage the message of a delivery, to decode the cloud event it carries.
+func cloudEventsMessage(msg base.Delivery) cloudevents.Message {
+	return cloudevents.Message{ContentType: msg.ContentType, Headers: msg.Headers, Body: msg.Body}
+}
+
+// cloudEventsHeaders the headers of a message carrying cloud event attributes.
+func cloudEventsHeaders(headers map[string]interface{}) amqp.Table {
+	table := make(amqp.Table)
+	for k, v := range headers {
+		if isCloudEventsHeader(k) {
+			table[k] = v
+		}
+	}
+
+	return table
+}
+
+// isCloudEventsHeader if a header carries a cloud event attribute.
+func isCloudEventsHeader(header string) bool {
+	for _, prefix := range []string{cloudevents.HeaderPrefix, cloudevents.HeaderPrefixUnderscore} {
+		if strings.HasPrefix(header, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withHeaders headers along with more - more taking precedence.
+func withHeaders(headers, more amqp.Table) amqp.Table {
+	if len(more) == 0 {
+		return headers
+	}
+
+	merged := make(amqp.Table, len(headers)+len(more))
+	for k, v := range headers {
+		merged[k] = v
+	}
+	for k, v := range more {
+		merged[k] = v
+	}
+
+	return merged
+}
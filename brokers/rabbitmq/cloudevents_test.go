@@ -0,0 +1,125 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/cloudevents"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecodeEvent(t *testing.T) {
+	config = Config{
+		ServiceName: "catalogconsumer",
+		Queues:      `[{"name": "structured", "cloud_events": "structured"}, {"name": "binary", "cloud_events": "binary"}, {"name": "msgpack", "content_type": "application/msgpack"}, {"name": "avro", "content_type": "avro/binary"}]`,
+	}
+	defer func() {
+		config = Config{}
+	}()
+
+	queues, err := parseQueues(config.Queues)
+	assert.Nil(t, err)
+	config.queues = queues
+
+	event := &base.EyewaEvent{ID: "1", Name: "product.created", Payload: json.RawMessage(`{"sku":"123"}`)}
+
+	tests := []struct {
+		queue       string
+		contentType string
+		headers     bool
+		typed       bool
+	}{
+		{"eyewacatalog", "application/json", false, true},
+		{"structured", cloudevents.ContentType, false, false},
+		{"binary", "application/json", true, false},
+		{"msgpack", "application/msgpack", false, true},
+		{"avro", "avro/binary", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.queue, func(t *testing.T) {
+			msg, err := encodeEvent(tt.queue, event, codec.JSON{})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.contentType, msg.ContentType)
+			assert.Equal(t, tt.headers, msg.Headers["cloudEvents:source"] == "catalogconsumer")
+			assert.Equal(t, tt.typed, msg.Headers["x-type-of-event"] == "eyewa")
+
+			// consumers accept events in any format
+			delivery := base.Delivery{ContentType: msg.ContentType, Headers: msg.Headers, Body: msg.Body}
+			decoded := new(base.EyewaEvent)
+			assert.Nil(t, decodeEvent(delivery, decoded, codec.JSON{}))
+			assert.Equal(t, event, decoded)
+		})
+	}
+
+	// only events with a cloud event counterpart
+	_, err = encodeEvent("binary", &plainEvent{ID: "1"}, codec.JSON{})
+	assert.NotNil(t, err)
+}
+
+func TestDeadletterMessage(t *testing.T) {
+	errs := []base.Error{{ErrorMessage: "boom", CreatedAt: "2021-01-01T00:00:00Z"}}
+	event := &base.MagentoProductEvent{ID: "1", Name: "product.updated", EntityID: 42}
+
+	t.Run("errors recorded in the mode delivered in", func(t *testing.T) {
+		msg, err := cloudevents.FromMagentoProductEvent(event, "magento").Encode(cloudevents.Binary)
+		assert.Nil(t, err)
+
+		msg.Headers["x-retry-count"] = int32(3)
+		dl := deadletterMessage[base.MagentoProductEvent](codec.JSON{}, base.Delivery{Headers: msg.Headers, Body: msg.Body}, errs)
+		assert.Equal(t, int32(3), dl.Headers["x-retry-count"])
+
+		ce, mode, err := cloudevents.Decode(cloudevents.Message{ContentType: dl.ContentType, Headers: dl.Headers, Body: dl.Body})
+		assert.Nil(t, err)
+		assert.Equal(t, cloudevents.Binary, mode)
+		assert.Equal(t, "magento", ce.Source)
+
+		deadlettered, err := cloudevents.ToMagentoProductEvent(ce)
+		assert.Nil(t, err)
+		assert.Equal(t, 42, deadlettered.EntityID)
+		assert.Equal(t, errs, deadlettered.Errors)
+	})
+
+	t.Run("as is if not mappable", func(t *testing.T) {
+		msg, err := cloudevents.FromMagentoProductEvent(event, "magento").Encode(cloudevents.Structured)
+		assert.Nil(t, err)
+
+		dl := deadletterMessage[plainEvent](codec.JSON{}, base.Delivery{ContentType: msg.ContentType, Body: msg.Body}, errs)
+		assert.Equal(t, amqp.Publishing{ContentType: cloudevents.ContentType, Headers: amqp.Table{}, Body: msg.Body}, dl)
+	})
+
+	t.Run("encoded with the codec otherwise", func(t *testing.T) {
+		delivery := base.Delivery{
+			MessageID: "m1",
+			Headers:   map[string]interface{}{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			Priority:  3,
+			Body:      []byte(`{"id":"1"}`),
+		}
+
+		dl := deadletterMessage[base.EyewaEvent](codec.JSON{}, delivery, errs)
+		assert.Equal(t, "application/json", dl.ContentType)
+		assert.Contains(t, string(dl.Body), "boom")
+
+		// published as delivered
+		assert.Equal(t, amqp.Table{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, dl.Headers)
+		assert.Equal(t, uint8(3), dl.Priority)
+		assert.Equal(t, "m1", dl.MessageId)
+	})
+
+	t.Run("encoded with the codec of the content type delivered in", func(t *testing.T) {
+		c := codec.MsgPack{}
+		body, err := c.Marshal(event)
+		assert.Nil(t, err)
+
+		dl := deadletterMessage[base.MagentoProductEvent](codec.JSON{}, base.Delivery{ContentType: c.ContentType(), Body: body}, errs)
+		assert.Equal(t, c.ContentType(), dl.ContentType)
+
+		deadlettered := new(base.MagentoProductEvent)
+		assert.Nil(t, c.Unmarshal(dl.Body, deadlettered))
+		assert.Equal(t, 42, deadlettered.EntityID)
+		assert.Equal(t, errs, deadlettered.Errors)
+	})
+}
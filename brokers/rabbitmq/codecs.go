@@ -0,0 +1,116 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/streadway/amqp"
+)
+
+const (
+	// eventTypeHeader tells the type of event a message carries - see eventTypeHeaders
+	eventTypeHeader  = "x-type-of-event"
+	eventTypeMagento = "magento"
+	eventTypeEyewa   = "eyewa"
+)
+
+// queueCodec the codec events published to a queue are encoded with - the one
+// registered for the queue's content type if it has one, otherwise c.
+func queueCodec(queue string, c codec.Codec) (codec.Codec, error) {
+	contentType := queueConfig(queue).ContentType
+	if contentType == "" {
+		return c, nil
+	}
+
+	return codec.Lookup(contentType)
+}
+
+// deliveryCodec the codec a delivery with contentType is decoded with - c if it
+// encodes contentType or the delivery has none, otherwise the one registered for it.
+// Deliveries of unknown content types are left to c.
+func deliveryCodec(contentType string, c codec.Codec) codec.Codec {
+	if contentType == "" || contentType == c.ContentType() {
+		return c
+	}
+
+	registered, err := codec.Lookup(contentType)
+	if err != nil {
+		return c
+	}
+
+	return registered
+}
+
+// eventTypeHeaders the header telling the type of event a message carries - for
+// codecs encoding by the schema of a type e.g Avro, as their encoding doesn't tell.
+func eventTypeHeaders(event interface{}) amqp.Table {
+	switch event.(type) {
+	case *base.MagentoProductEvent:
+		return amqp.Table{eventTypeHeader: eventTypeMagento}
+	case *base.EyewaEvent:
+		return amqp.Table{eventTypeHeader: eventTypeEyewa}
+	}
+
+	return nil
+}
+
+// decodeTypedEvent decodes a body encoded with c as the event it carries, for codecs
+// encoding by the schema of a type e.g Avro - their encoding isn't self describing.
+// A *base.MagentoProductEvent if the x-type-of-event header tells, otherwise a
+// *base.EyewaEvent.
+func decodeTypedEvent(c codec.Codec, headers amqp.Table, body []byte) (interface{}, bool) {
+	var event interface{} = new(base.EyewaEvent)
+	if headers[eventTypeHeader] == eventTypeMagento {
+		event = new(base.MagentoProductEvent)
+	}
+
+	if err := c.Unmarshal(body, event); err != nil {
+		return nil, false
+	}
+
+	return event, true
+}
+
+// asJSON re-encodes a body encoded with c as JSON, so its fields can be looked up
+// whatever the codec. Bodies that can't be decoded into a map e.g Avro are decoded
+// as the event they carry - see decodeTypedEvent.
+func asJSON(c codec.Codec, headers amqp.Table, body []byte) ([]byte, error) {
+	if _, isJSON := c.(codec.JSON); isJSON {
+		return body, nil
+	}
+
+	var fields map[string]interface{}
+	err := c.Unmarshal(body, &fields)
+	if err == nil {
+		return json.Marshal(rawJSON(fields))
+	}
+
+	event, ok := decodeTypedEvent(c, headers, body)
+	if !ok {
+		return nil, err
+	}
+
+	return json.Marshal(event)
+}
+
+// rawJSON replaces bytes holding JSON e.g the payload of an event with the JSON
+// itself, so they are encoded as is instead of base64.
+func rawJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			v[k] = rawJSON(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = rawJSON(item)
+		}
+	case []byte:
+		if json.Valid(v) {
+			return json.RawMessage(v)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,70 @@
+package rabbitmq
+
+import (
+	"strconv"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var defaultCompressionThreshold = 1024
+
+// compressionThreshold min size in bytes of bodies compressed for the queue.
+func (q QueueConfig) compressionThreshold() int {
+	if q.CompressionThreshold > 0 {
+		return q.CompressionThreshold
+	}
+
+	threshold, err := strconv.Atoi(config.CompressionThreshold)
+	if err != nil || threshold < 0 {
+		return defaultCompressionThreshold
+	}
+
+	return threshold
+}
+
+// compress compresses the body of a message published to the queue with the
+// queue's compression - once larger than its threshold - recording the
+// compression ratio. Bodies compression doesn't shrink are published as is.
+func (q QueueConfig) compress(msg amqp.Publishing) (amqp.Publishing, error) {
+	if q.Compression == "" || len(msg.Body) <= q.compressionThreshold() {
+		return msg, nil
+	}
+
+	compressed, err := compression.Compress(q.Compression, msg.Body)
+	if err != nil {
+		return msg, err
+	}
+
+	ratio := float64(len(compressed)) / float64(len(msg.Body))
+	go standardMetrics.CompressionRatioRecorder.Record(ratio, attribute.Any("content_encoding", q.Compression))
+
+	if len(compressed) >= len(msg.Body) {
+		return msg, nil
+	}
+
+	msg.ContentEncoding = q.Compression
+	msg.Body = compressed
+
+	return msg, nil
+}
+
+// decompress decompresses the body of a delivery compressed with its content
+// encoding. Deliveries without one are returned as is.
+func decompress(msg base.Delivery) (base.Delivery, error) {
+	if msg.ContentEncoding == "" {
+		return msg, nil
+	}
+
+	body, err := compression.Decompress(msg.ContentEncoding, msg.Body)
+	if err != nil {
+		return msg, err
+	}
+
+	msg.ContentEncoding = ""
+	msg.Body = body
+
+	return msg, nil
+}
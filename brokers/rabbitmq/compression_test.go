@@ -0,0 +1,97 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	large := bytes.Repeat([]byte(`{"sku":"123"}`), 100)
+
+	tests := []struct {
+		name     string
+		queue    QueueConfig
+		body     []byte
+		encoding string
+	}{
+		{"no compression", QueueConfig{}, large, ""},
+		{"below threshold", QueueConfig{Compression: compression.Gzip}, []byte(`{"sku":"123"}`), ""},
+		{"gzip", QueueConfig{Compression: compression.Gzip}, large, compression.Gzip},
+		{"zstd", QueueConfig{Compression: compression.Zstd, CompressionThreshold: 10}, large, compression.Zstd},
+		{"not shrunk", QueueConfig{Compression: compression.Gzip, CompressionThreshold: 10}, []byte(`{"sku":"123"}`), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.queue.compress(amqp.Publishing{ContentType: "application/json", Body: tt.body})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.encoding, msg.ContentEncoding)
+
+			delivery, err := decompress(base.Delivery{ContentEncoding: msg.ContentEncoding, Body: msg.Body})
+			assert.Nil(t, err)
+			assert.Equal(t, "", delivery.ContentEncoding)
+			assert.Equal(t, tt.body, delivery.Body)
+		})
+	}
+
+	_, err := decompress(base.Delivery{ContentEncoding: "br", Body: large})
+	assert.NotNil(t, err)
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	defer func() {
+		config = Config{}
+	}()
+
+	assert.Equal(t, defaultCompressionThreshold, QueueConfig{}.compressionThreshold())
+	assert.Equal(t, 10, QueueConfig{CompressionThreshold: 10}.compressionThreshold())
+
+	config = Config{CompressionThreshold: "0"}
+	assert.Equal(t, 0, QueueConfig{}.compressionThreshold())
+}
+
+func TestCompressedEvents(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	config = Config{Queues: `[{"name": "compressed", "compression": "zstd", "compression_threshold": 10}]`}
+	defer func() {
+		config = Config{}
+	}()
+
+	queues, err := parseQueues(config.Queues)
+	assert.Nil(t, err)
+	config.queues = queues
+
+	event := &base.EyewaEvent{ID: "1", Name: "product.updated", Payload: json.RawMessage(`{"sku":"123","name":"Ray-Ban","variants":["a","a","a","a"]}`)}
+
+	msg, err := encodeEvent("compressed", event, codec.JSON{})
+	assert.Nil(t, err)
+	assert.Equal(t, compression.Zstd, msg.ContentEncoding)
+
+	delivery := base.Delivery{ContentType: msg.ContentType, ContentEncoding: msg.ContentEncoding, Body: msg.Body}
+	decoded := new(base.EyewaEvent)
+	assert.Nil(t, decodeEvent(delivery, decoded, codec.JSON{}))
+	assert.Equal(t, event, decoded)
+
+	// deadlettered decompressed, with its errors
+	errs := []base.Error{{ErrorMessage: "boom", CreatedAt: "2021-01-01T00:00:00Z"}}
+	dl := deadletterMessage[base.EyewaEvent](codec.JSON{}, delivery, errs)
+	assert.Equal(t, "", dl.ContentEncoding)
+
+	deadlettered := new(base.EyewaEvent)
+	assert.Nil(t, json.Unmarshal(dl.Body, deadlettered))
+	assert.Equal(t, errs, deadlettered.Errors)
+
+	// as is if it can't be decompressed
+	delivery.ContentEncoding = "br"
+	assert.NotNil(t, decodeEvent(delivery, decoded, codec.JSON{}))
+	dl = deadletterMessage[base.EyewaEvent](codec.JSON{}, delivery, errs)
+	assert.Equal(t, amqp.Publishing{ContentType: msg.ContentType, ContentEncoding: "br", Headers: amqp.Table{}, Body: msg.Body}, dl)
+}
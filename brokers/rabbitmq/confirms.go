@@ -0,0 +1,220 @@
+package rabbitmq
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// confirmer tracks the publisher confirms and returns of a channel in confirm mode.
+// Publishing through a confirmer is serialized so each confirmation can be
+// matched to the message it belongs to.
+type confirmer struct {
+	mutex sync.Mutex
+
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	returns  chan amqp.Return
+
+	// delivery tag the broker will assign to the next message published
+	nextTag uint64
+
+	// delivery tags of the messages of a batch by their message id - see waitAll
+	messageTags map[string]uint64
+}
+
+// publisherConfirmsEnabled reports if publishing should wait for broker confirms.
+func publisherConfirmsEnabled() bool {
+	enabled, _ := strconv.ParseBool(config.PublisherConfirms)
+	return enabled
+}
+
+// confirmTimeout how long to wait for the broker to confirm a message.
+func confirmTimeout() time.Duration {
+	timeout, err := time.ParseDuration(config.ConfirmTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultConfirmTimeout
+	}
+
+	return timeout
+}
+
+// newConfirmer puts a channel into confirm mode. Its confirms + returns are drained
+// as they arrive, so they never block the connection while nobody waits for them.
+func newConfirmer(channel *amqp.Channel) (*confirmer, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, err
+	}
+
+	c := &confirmer{
+		channel:  channel,
+		confirms: make(chan amqp.Confirmation, confirmBufferSize),
+		returns:  make(chan amqp.Return, confirmBufferSize),
+		nextTag:  1,
+	}
+	go c.drain(channel.NotifyPublish(make(chan amqp.Confirmation)), channel.NotifyReturn(make(chan amqp.Return)))
+
+	return c, nil
+}
+
+// drain passes the confirms + returns of the channel on to be waited for, until
+// the channel closes. Once confirmBufferSize are buffered - late confirmations of
+// messages that timed out, that nobody waits for - the oldest are dropped.
+//
+// RMQ sends the return of a message right before its ack and the notifications
+// are received unbuffered, so a return is always passed on before its ack.
+func (c *confirmer) drain(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	defer close(c.confirms)
+
+	for {
+		select {
+		case confirmation, ok := <-confirms:
+			if !ok {
+				return
+			}
+			offer(c.confirms, confirmation)
+
+		case r, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			offer(c.returns, r)
+		}
+	}
+}
+
+// offer sends v on ch, dropping the oldest value buffered if ch is full.
+func offer[T any](ch chan T, v T) {
+	for {
+		select {
+		case ch <- v:
+			return
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+}
+
+// enableConfirms puts the channel of a queue into confirm mode, if
+// publisher confirms are enabled.
+func (rmq *RMQClient) enableConfirms(queue string, channel *amqp.Channel) error {
+	if !publisherConfirmsEnabled() || channel == nil {
+		return nil
+	}
+
+	c, err := newConfirmer(channel)
+	if err != nil {
+		return err
+	}
+
+	rmq.mutex.Lock()
+	rmq.confirmers[queue] = c
+	rmq.mutex.Unlock()
+
+	return nil
+}
+
+// publish publishes a message on the channel of a queue. If the channel is
+// in confirm mode the message is published as mandatory and publish waits
+// for the broker to ack, nack or return it.
+func (rmq *RMQClient) publish(queue string, channel *amqp.Channel, exchange, key string, msg amqp.Publishing) error {
+	rmq.mutex.RLock()
+	c, exists := rmq.confirmers[queue]
+	rmq.mutex.RUnlock()
+
+	if !exists || c.channel != channel {
+		return channel.Publish(exchange, key, false, false, msg)
+	}
+
+	return c.publish(exchange, key, msg)
+}
+
+func (c *confirmer) publish(exchange, key string, msg amqp.Publishing) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.channel.Publish(exchange, key, true, false, msg); err != nil {
+		return err
+	}
+
+	tag := c.nextTag
+	c.nextTag++
+
+	return c.wait(tag, confirmTimeout())
+}
+
+// wait waits for the broker to confirm the message with the given delivery tag.
+func (c *confirmer) wait(tag uint64, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var returned *amqp.Return
+	for {
+		select {
+		// the broker sends a return right before the ack of the same message
+		case r := <-c.returns:
+			returned = &r
+
+		case confirmation, ok := <-c.confirms:
+			if !ok {
+				return libErrs.ErrorChannelDoesNotExist
+			}
+
+			// a return is delivered before its ack, pick it up if not already
+			select {
+			case r := <-c.returns:
+				returned = &r
+			default:
+			}
+
+			// belongs to a message that timed out earlier - as does any
+			// return received before it.
+			if confirmation.DeliveryTag < tag {
+				returned = nil
+				continue
+			}
+
+			if !confirmation.Ack {
+				go standardMetrics.NackedEventCounter.Add(1)
+				return libErrs.ErrorPublishNacked
+			}
+
+			if returned != nil {
+				go standardMetrics.ReturnedEventCounter.Add(1)
+				log.Warn(libErrs.ErrorPublishReturned.Error(),
+					zap.String("exchange", returned.Exchange),
+					zap.String("routing_key", returned.RoutingKey),
+					zap.Uint16("reply_code", returned.ReplyCode),
+					zap.String("reply_text", returned.ReplyText))
+				return libErrs.ErrorPublishReturned
+			}
+
+			go standardMetrics.ConfirmedEventCounter.Add(1)
+			return nil
+
+		case <-timer.C:
+			go standardMetrics.ConfirmTimeoutCounter.Add(1)
+			return libErrs.ErrorPublishConfirmTimeout
+		}
+	}
+}
+
+// publishError the error reported to publish callbacks. Confirm failures are
+// passed on as is so callers can tell them apart.
+func publishError(err error) error {
+	switch err {
+	case libErrs.ErrorPublishNacked, libErrs.ErrorPublishReturned, libErrs.ErrorPublishConfirmTimeout:
+		return err
+	default:
+		return libErrs.ErrorFailedToPublishEvent
+	}
+}
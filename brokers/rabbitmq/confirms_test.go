@@ -0,0 +1,123 @@
+package rabbitmq
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfirmer() *confirmer {
+	standardMetrics = NewRabbitMQMetrics()
+	return &confirmer{
+		confirms: make(chan amqp.Confirmation, confirmBufferSize),
+		returns:  make(chan amqp.Return, confirmBufferSize),
+		nextTag:  1,
+	}
+}
+
+func TestConfirmerDrain(t *testing.T) {
+	c := newTestConfirmer()
+	confirms, returns := make(chan amqp.Confirmation), make(chan amqp.Return)
+	go c.drain(confirms, returns)
+
+	// with nobody waiting, the channel's notifications never block
+	for tag := uint64(1); tag <= 2*uint64(confirmBufferSize); tag++ {
+		select {
+		case confirms <- amqp.Confirmation{DeliveryTag: tag, Ack: true}:
+		case <-time.After(time.Second):
+			assert.FailNow(t, "confirms blocked")
+		}
+	}
+	returns <- amqp.Return{ReplyCode: amqp.NoRoute}
+	close(confirms)
+
+	// the latest are kept, for the message waited for next
+	assert.Equal(t, uint64(confirmBufferSize+1), (<-c.confirms).DeliveryTag)
+	assert.Len(t, c.confirms, confirmBufferSize-1)
+	assert.Equal(t, amqp.Return{ReplyCode: amqp.NoRoute}, <-c.returns)
+}
+
+func TestConfirmConfig(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RABBITMQ_PUBLISHER_CONFIRMS", "true")
+	os.Setenv("RABBITMQ_CONFIRM_TIMEOUT", "250ms")
+
+	cfg, _, err := initConfig()
+	assert.Nil(t, err)
+
+	config = cfg
+	assert.True(t, publisherConfirmsEnabled())
+	assert.Equal(t, 250*time.Millisecond, confirmTimeout())
+
+	config = Config{}
+	assert.False(t, publisherConfirmsEnabled())
+	assert.Equal(t, defaultConfirmTimeout, confirmTimeout())
+
+	os.Clearenv()
+}
+
+func TestConfirmerWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		returns []amqp.Return
+		confirm []amqp.Confirmation
+		err     error
+	}{
+		{
+			name:    "acked",
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}},
+		},
+		{
+			name:    "nacked",
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: false}},
+			err:     libErrs.ErrorPublishNacked,
+		},
+		{
+			name:    "returned",
+			returns: []amqp.Return{{ReplyCode: amqp.NoRoute, ReplyText: "NO_ROUTE"}},
+			confirm: []amqp.Confirmation{{DeliveryTag: 1, Ack: true}},
+			err:     libErrs.ErrorPublishReturned,
+		},
+		{
+			name: "timed out",
+			err:  libErrs.ErrorPublishConfirmTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfirmer()
+			for _, r := range tt.returns {
+				c.returns <- r
+			}
+			for _, conf := range tt.confirm {
+				c.confirms <- conf
+			}
+
+			assert.Equal(t, tt.err, c.wait(1, 50*time.Millisecond))
+		})
+	}
+}
+
+func TestConfirmerWaitSkipsStaleConfirms(t *testing.T) {
+	c := newTestConfirmer()
+
+	// message 1 timed out and was returned late; message 2 was acked.
+	c.returns <- amqp.Return{ReplyCode: amqp.NoRoute}
+	c.confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	c.confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+
+	assert.NoError(t, c.wait(2, 50*time.Millisecond))
+}
+
+func TestPublishError(t *testing.T) {
+	assert.Equal(t, libErrs.ErrorPublishNacked, publishError(libErrs.ErrorPublishNacked))
+	assert.Equal(t, libErrs.ErrorPublishReturned, publishError(libErrs.ErrorPublishReturned))
+	assert.Equal(t, libErrs.ErrorPublishConfirmTimeout, publishError(libErrs.ErrorPublishConfirmTimeout))
+	assert.Equal(t, libErrs.ErrorFailedToPublishEvent, publishError(errors.New("channel closed")))
+}
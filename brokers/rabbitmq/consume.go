@@ -0,0 +1,235 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// shutdownTimeout how long a stopped consumer waits for messages in flight to be processed.
+func shutdownTimeout() time.Duration {
+	timeout, err := time.ParseDuration(config.ConsumerShutdownTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultShutdownTimeout
+	}
+
+	return timeout
+}
+
+// messageTimeout how long a callback has to process a message - none if 0.
+func messageTimeout() time.Duration {
+	timeout, err := time.ParseDuration(config.ConsumerMessageTimeout)
+	if err != nil || timeout <= 0 {
+		return 0
+	}
+
+	return timeout
+}
+
+// consumer the channel a queue is consumed on. Consumers have a channel of their
+// own - not shared with publishing - so it can be closed once they stop.
+type consumer struct {
+	mutex   sync.Mutex
+	channel *amqp.Channel
+}
+
+// replace sets the channel consumed on, closing the one it replaces.
+func (c *consumer) replace(channel *amqp.Channel) {
+	c.mutex.Lock()
+	stale := c.channel
+	c.channel = channel
+	c.mutex.Unlock()
+
+	if stale != nil {
+		_ = stale.Close()
+	}
+}
+
+// close closes the channel consumed on. Unacked messages on it are returned to the queue.
+func (c *consumer) close(queue string) {
+	c.mutex.Lock()
+	channel := c.channel
+	c.channel = nil
+	c.mutex.Unlock()
+
+	if channel == nil {
+		return
+	}
+
+	if err := channel.Close(); err != nil && err != amqp.ErrClosed {
+		log.Warn(fmt.Sprintf("Failed to close channel of %s", queue), zap.Error(err))
+	}
+}
+
+// newConsumerChannel opens a channel to consume from a queue on, applying its
+// QoS and declaring the queue.
+func (rmq *RMQClient) newConsumerChannel(q QueueConfig) (*amqp.Channel, error) {
+	conn := rmq.currentConnection()
+	if conn == nil {
+		return nil, libErrs.ErrorNoRMQConnection
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf(libErrs.ErrorChannelCreateFailure.Error(), q.Name, err)
+	}
+
+	if err := channel.Qos(q.prefetch(), 0, q.globalQos()); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+
+	if err := rmq.declareQueue(channel, q); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+// consume starts consuming from a queue until ctx is done. With auto recovery
+// enabled, the deliveries survive a lost connection or channel - consuming
+// resumes once recovered and the deliveries only close if recovery is not possible.
+//
+// Streams are consumed from the offset on ctx or the queue's stream offset, and
+// once recovered, resume after the last message received.
+//
+// The deliveries are consumed on a channel of their own, tracked by the consumer
+// returned - it is left open for the deliveries to be settled on after they close.
+func (rmq *RMQClient) consume(ctx context.Context, queue string) (<-chan amqp.Delivery, *consumer, error) {
+	q := queueConfig(queue)
+	args, err := q.consumeArguments(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	channel, err := rmq.newConsumerChannel(q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tag := getNameForChannel(queue)
+	msgs, err := channel.Consume(queue, tag, false, false, false, false, args)
+	if err != nil {
+		_ = channel.Close()
+		return nil, nil, err
+	}
+
+	c := &consumer{channel: channel}
+
+	deliveries := make(chan amqp.Delivery)
+	go func() {
+		defer close(deliveries)
+
+		// offset of the last message received from a stream
+		seen := func(msg amqp.Delivery) {
+			if offset, ok := streamOffset(msg); ok && args != nil {
+				args = resumeArguments(args, offset)
+			}
+		}
+
+		for {
+			if stopped := forward(ctx, channel, tag, msgs, deliveries, seen); stopped || !autoRecoveryEnabled() {
+				return
+			}
+
+			log.Warn(fmt.Sprintf("Stopped consuming from %s. Awaiting recovery...", queue))
+
+			if !rmq.awaitConnection(ctx) {
+				return
+			}
+
+			var err error
+			if channel, err = rmq.newConsumerChannel(q); err == nil {
+				c.replace(channel)
+				tag = getNameForChannel(queue)
+				msgs, err = channel.Consume(queue, tag, false, false, false, false, args)
+			}
+			if err != nil {
+				log.Error(fmt.Sprintf(libErrs.ErrorConsumeFailure.Error(), queue, err))
+				return
+			}
+
+			log.Info(fmt.Sprintf("Resumed consuming from %s.", queue))
+			go standardMetrics.ConsumerResumedCounter.Add(1)
+		}
+	}()
+
+	return deliveries, c, nil
+}
+
+// forward passes msgs on to deliveries until msgs close or ctx is done - in
+// which case it reports stopped. seen is invoked for every message passed on.
+func forward(ctx context.Context, channel *amqp.Channel, tag string, msgs <-chan amqp.Delivery, deliveries chan<- amqp.Delivery, seen func(amqp.Delivery)) (stopped bool) {
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				return false
+			}
+
+			select {
+			case deliveries <- msg:
+				seen(msg)
+			case <-ctx.Done():
+				_ = msg.Nack(false, true)
+				cancelConsumer(channel, tag, msgs)
+				return true
+			}
+
+		case <-ctx.Done():
+			cancelConsumer(channel, tag, msgs)
+			return true
+		}
+	}
+}
+
+// cancelConsumer cancels a consumer so RMQ stops sending it messages, returning
+// any messages it already received to the queue.
+func cancelConsumer(channel *amqp.Channel, tag string, msgs <-chan amqp.Delivery) {
+	if err := channel.Cancel(tag, false); err != nil {
+		// channel is gone - along with the messages it received
+		log.Warn(fmt.Sprintf("Failed to cancel consumer %s", tag), zap.Error(err))
+		return
+	}
+
+	for msg := range msgs {
+		_ = msg.Nack(false, true)
+	}
+}
+
+// process hands deliveries to handle until they close, then closes the consumer's
+// channel. Once ctx is done no new deliveries are taken and process waits for those
+// in flight to be handled - for up to the shutdown timeout. If they aren't by then,
+// process returns and the channel is closed once they are.
+func (rmq *RMQClient) process(ctx context.Context, queue string, c *consumer, msgs <-chan amqp.Delivery, handle func(amqp.Delivery)) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer c.close(queue)
+		rmq.dispatch(msgs, handle)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Info(fmt.Sprintf("Stopping consuming from %s...", queue))
+
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout()):
+			go standardMetrics.ShutdownTimeoutCounter.Add(1)
+			log.Warn(libErrs.ErrorConsumerShutdownTimeout.Error(), zap.String("queue", queue))
+		}
+	}
+
+	if ctx.Err() != nil {
+		log.Info(fmt.Sprintf("Stopped consuming from %s.", queue))
+	}
+}
@@ -0,0 +1,100 @@
+package rabbitmq
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownTimeout(t *testing.T) {
+	defer func() { config = Config{} }()
+
+	config = Config{}
+	assert.Equal(t, defaultShutdownTimeout, shutdownTimeout())
+
+	config = Config{ConsumerShutdownTimeout: "2s"}
+	assert.Equal(t, 2*time.Second, shutdownTimeout())
+}
+
+func TestMessageTimeout(t *testing.T) {
+	defer func() { config = Config{} }()
+
+	config = Config{}
+	assert.Equal(t, time.Duration(0), messageTimeout())
+
+	config = Config{ConsumerMessageTimeout: "5s"}
+	assert.Equal(t, 5*time.Second, messageTimeout())
+}
+
+// stoppedDeliveries mimics consume - deliveries close once ctx is done.
+func stoppedDeliveries(ctx context.Context, count int) <-chan amqp.Delivery {
+	msgs := make(chan amqp.Delivery, count)
+	for i := 0; i < count; i++ {
+		msgs <- amqp.Delivery{}
+	}
+
+	go func() {
+		<-ctx.Done()
+		close(msgs)
+	}()
+
+	return msgs
+}
+
+func TestProcessDrainsInFlight(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	config = Config{ConsumerShutdownTimeout: "1s"}
+	defer func() { config = Config{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var handled int32
+	NewRMQClient().process(ctx, "catalog", new(consumer), stoppedDeliveries(ctx, 1), func(msg amqp.Delivery) {
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&handled, 1)
+	})
+
+	// the delivery in flight was handled before returning
+	assert.Equal(t, int32(1), atomic.LoadInt32(&handled))
+}
+
+func TestProcessShutdownTimeout(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	config = Config{ConsumerShutdownTimeout: "20ms"}
+	defer func() { config = Config{} }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	release := make(chan struct{})
+	defer close(release)
+
+	started := time.Now()
+	NewRMQClient().process(ctx, "catalog", new(consumer), stoppedDeliveries(ctx, 1), func(msg amqp.Delivery) {
+		cancel()
+		<-release
+	})
+
+	assert.Less(t, int64(time.Since(started)), int64(time.Second))
+}
+
+func TestConsumeWithContextStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errs []error
+	NewRMQClient().ConsumeWithContext(ctx, "catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		errs = append(errs, err)
+		return nil
+	})
+
+	// a stopped consumer is not reported as a lost connection
+	assert.Equal(t, libErrs.ErrorConsumerStopped, errs[len(errs)-1])
+	assert.NotContains(t, errs, libErrs.ErrorLostConnectionToMessageBroker)
+}
@@ -0,0 +1,94 @@
+package rabbitmq
+
+import (
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/streadway/amqp"
+)
+
+// acknowledger settles a base.Delivery through the amqp.Delivery it was mapped from.
+type acknowledger struct {
+	msg amqp.Delivery
+}
+
+// Ack acknowledges the amqp delivery
+func (a acknowledger) Ack() error {
+	return a.msg.Ack(false)
+}
+
+// Nack negatively acknowledges the amqp delivery
+func (a acknowledger) Nack(requeue bool) error {
+	return a.msg.Nack(false, requeue)
+}
+
+// Reject rejects the amqp delivery
+func (a acknowledger) Reject(requeue bool) error {
+	return a.msg.Reject(requeue)
+}
+
+// newDelivery maps an amqp.Delivery received from queue onto a base.Delivery.
+func newDelivery(queue string, msg amqp.Delivery) base.Delivery {
+	headers := make(map[string]interface{}, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	return base.Delivery{
+		Acknowledger:    acknowledger{msg},
+		MessageID:       msg.MessageId,
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		Body:            msg.Body,
+		Queue:           queue,
+		Exchange:        msg.Exchange,
+		RoutingKey:      msg.RoutingKey,
+		Priority:        msg.Priority,
+		Redelivered:     msg.Redelivered,
+		RedeliveryCount: redeliveryCount(msg),
+		Timestamp:       msg.Timestamp,
+	}
+}
+
+// redeliveryCount determines how often a message has been delivered before.
+// Quorum queues keep count in the x-delivery-count header, messages that went
+// through a dead letter exchange in x-death. Otherwise RMQ only tells if a
+// message has been redelivered at all.
+func redeliveryCount(msg amqp.Delivery) int {
+	if count, ok := toInt(msg.Headers["x-delivery-count"]); ok {
+		return count
+	}
+
+	if deaths, ok := msg.Headers["x-death"].([]interface{}); ok {
+		total := 0
+		for _, death := range deaths {
+			if table, ok := death.(amqp.Table); ok {
+				if count, ok := toInt(table["count"]); ok {
+					total += count
+				}
+			}
+		}
+		return total
+	}
+
+	if msg.Redelivered {
+		return 1
+	}
+
+	return 0
+}
+
+// toInt converts the integer types amqp decodes header values into.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package rabbitmq
+
+import (
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDelivery(t *testing.T) {
+	msg := amqp.Delivery{
+		MessageId:   "1",
+		Headers:     amqp.Table{"x-type-of-event": "magento"},
+		ContentType: "application/json",
+		Body:        []byte(`{}`),
+		RoutingKey:  "catalog",
+		Priority:    3,
+	}
+
+	delivery := newDelivery("catalog", msg)
+	assert.Equal(t, "1", delivery.MessageID)
+	assert.Equal(t, "catalog", delivery.Queue)
+	assert.Equal(t, uint8(3), delivery.Priority)
+	assert.Equal(t, "magento", delivery.Header("x-type-of-event"))
+	assert.Equal(t, 0, delivery.RedeliveryCount)
+
+	// headers are copied so the delivery can be annotated freely
+	delivery.Headers["foo"] = "bar"
+	assert.NotContains(t, msg.Headers, "foo")
+}
+
+func TestRedeliveryCount(t *testing.T) {
+	testCases := []struct {
+		name     string
+		msg      amqp.Delivery
+		expected int
+	}{
+		{
+			name:     "first delivery",
+			msg:      amqp.Delivery{},
+			expected: 0,
+		},
+		{
+			name:     "redelivered",
+			msg:      amqp.Delivery{Redelivered: true},
+			expected: 1,
+		},
+		{
+			name:     "quorum queue delivery count",
+			msg:      amqp.Delivery{Redelivered: true, Headers: amqp.Table{"x-delivery-count": int64(4)}},
+			expected: 4,
+		},
+		{
+			name: "dead lettered",
+			msg: amqp.Delivery{Headers: amqp.Table{"x-death": []interface{}{
+				amqp.Table{"count": int64(2), "reason": "expired"},
+				amqp.Table{"count": int64(1), "reason": "rejected"},
+			}}},
+			expected: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, redeliveryCount(tc.msg))
+		})
+	}
+}
@@ -0,0 +1,742 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/brokers/rabbitmq/amqptest"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// connectToServer connects a client to an in-process AMQP server, with the
+// env vars given on top of those to connect + consume from eyewacatalog.
+func connectToServer(t *testing.T, env map[string]string) (*amqptest.Server, *RMQClient) {
+	log.SetLogLevel()
+
+	server, err := amqptest.NewServer()
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	os.Clearenv()
+	vars := map[string]string{
+		"RABBITMQ_SERVER":                 server.Host(),
+		"RABBITMQ_AMQP_PORT":              server.Port(),
+		"RABBITMQ_USERNAME":               "guest",
+		"RABBITMQ_PASSWORD":               "guest",
+		"MESSAGE_BROKER":                  "rabbitmq",
+		"CONSUMER_QUEUE_NAME":             "eyewacatalog",
+		"RABBITMQ_CONSUMER_EXCHANGE_TYPE": "direct",
+	}
+	for e, v := range env {
+		vars[e] = v
+	}
+	for e, v := range vars {
+		os.Setenv(e, v)
+	}
+
+	config = Config{}
+	client := NewRMQClient()
+	if !assert.Nil(t, client.Connect()) {
+		t.FailNow()
+	}
+
+	t.Cleanup(func() {
+		_ = client.CloseConnection()
+		_ = server.Close()
+		os.Clearenv()
+	})
+
+	return server, client
+}
+
+// consumeEvents consumes events from a queue until the test ends, handling them with handle.
+// The func returned stops consuming earlier, returning once it has.
+func consumeEvents(t *testing.T, client *RMQClient, queue string, handle func(*base.EyewaEvent) error) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		client.ConsumeWithContext(ctx, queue, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			if err != nil {
+				return err
+			}
+			return handle(event)
+		})
+	}()
+
+	stop := func() {
+		cancel()
+		<-stopped
+	}
+	t.Cleanup(stop)
+
+	return stop
+}
+
+func publishEvent(client *RMQClient, queue string, priority int, event *base.EyewaEvent) error {
+	var errPublish error
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	client.Publish(context.Background(), queue, priority, event, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		errPublish = err
+		return nil
+	}, wg)
+	wg.Wait()
+
+	return errPublish
+}
+
+func TestConnectDeclaresQueues(t *testing.T) {
+	server, client := connectToServer(t, nil)
+
+	assert.True(t, client.IsConnectionOpen())
+	assert.Equal(t, StateConnected, client.State())
+
+	args, declared := server.QueueArguments("eyewacatalog")
+	assert.True(t, declared)
+	assert.Equal(t, int32(defaultMaxPriority), args["x-max-priority"])
+	assert.True(t, server.Bound("eyewacatalog.direct", "eyewacatalog", "eyewacatalog"))
+
+	inspect, err := client.QueueInspect("eyewacatalog")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"Total Consumers": 0, "Total Messages": 0}, inspect)
+}
+
+func TestConnectWithoutQueues(t *testing.T) {
+	server, deadletterer := connectToServer(t, nil)
+
+	stop := consumeEvents(t, deadletterer, "eyewacatalog", func(event *base.EyewaEvent) error {
+		return errors.New("failed")
+	})
+	assert.Nil(t, publishEvent(deadletterer, "eyewacatalog", 0, &base.EyewaEvent{ID: "1", Name: "product.created"}))
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+
+	// nothing is left reading the config about to change
+	stop()
+	assert.Nil(t, deadletterer.CloseConnection())
+
+	os.Unsetenv("CONSUMER_QUEUE_NAME")
+	config = Config{}
+
+	client := NewRMQClient()
+	assert.Equal(t, libErrs.ErrorNoQueuesSpecified, client.Connect())
+
+	assert.Nil(t, client.ConnectWithoutQueues())
+	defer client.CloseConnection()
+	assert.True(t, client.IsConnectionOpen())
+
+	// queues are given explicitly
+	events, err := client.ListDeadletterEvents(context.Background(), "eyewacatalog", ReplayFilter{}, 0)
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "product.created", events[0].Name)
+}
+
+func TestStoppedConsumerKeepsPublishing(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"CONSUMER_SHUTDOWN_TIMEOUT": "20ms",
+	})
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, &base.EyewaEvent{ID: "1"}))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		client.ConsumeWithContext(ctx, "eyewacatalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			if err == nil {
+				close(started)
+				<-release
+			}
+			return nil
+		})
+	}()
+
+	<-started
+	cancel()
+	<-stopped
+
+	// the consumer's channel isn't shared with publishing
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, &base.EyewaEvent{ID: "2"}))
+
+	// nor closed before the message in flight past the shutdown timeout is acked
+	close(release)
+	assert.Eventually(t, func() bool {
+		messages := server.Messages("eyewacatalog")
+		return len(messages) == 1 && !messages[0].Redelivered
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestVerifyTopology(t *testing.T) {
+	server, client := connectToServer(t, nil)
+	assert.Nil(t, client.ApplyTopology(Topology{Queues: []QueueTopology{{Name: "orders", MessageTTL: "30s"}}}))
+	declared, _ := server.QueueArguments("eyewacatalog")
+
+	drift, err := client.VerifyTopology(Topology{
+		Exchanges: []ExchangeTopology{
+			{Name: "catalog", Type: "headers"},
+			{Name: "eyewacatalog.direct", Type: "direct"},
+			{Name: "eyewacatalog.direct", Type: "topic"},
+		},
+		Queues: []QueueTopology{
+			{Name: "eyewacatalog", MaxPriority: defaultMaxPriority},
+			{Name: "eyewacatalog", MaxPriority: 10},
+			{Name: "orders", MessageTTL: "1m"},
+			{Name: "missing"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []TopologyDrift{
+		{Kind: "exchange", Name: "catalog", Reason: "NOT_FOUND - no exchange 'catalog' in vhost '/'"},
+		{Kind: "exchange", Name: "eyewacatalog.direct", Reason: "PRECONDITION_FAILED - inequivalent arg 'type' for exchange 'eyewacatalog.direct' in vhost '/': received 'topic' but current is 'direct'"},
+		{Kind: "queue", Name: "eyewacatalog", Reason: "PRECONDITION_FAILED - inequivalent arg 'x-max-priority' for queue 'eyewacatalog' in vhost '/': received 10 but current is 5"},
+		{Kind: "queue", Name: "orders", Reason: "PRECONDITION_FAILED - inequivalent arg 'x-message-ttl' for queue 'orders' in vhost '/': received 60000 but current is 30000"},
+		{Kind: "queue", Name: "missing", Reason: "NOT_FOUND - no queue 'missing' in vhost '/'"},
+	}, drift)
+
+	// nothing is declared - not even what already exists
+	args, _ := server.QueueArguments("eyewacatalog")
+	assert.Equal(t, declared, args)
+	_, exists := server.QueueArguments("missing")
+	assert.False(t, exists)
+}
+
+func TestVerifyOnlyDeclaresNothing(t *testing.T) {
+	topology := filepath.Join(t.TempDir(), "topology.yaml")
+	assert.Nil(t, os.WriteFile(topology, []byte("queues:\n  - name: orders\n"), 0o600))
+
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_TOPOLOGY_FILE":   topology,
+		"RABBITMQ_TOPOLOGY_VERIFY": "true",
+	})
+	assert.True(t, client.IsConnectionOpen())
+
+	// neither the topology nor the queues consumed from are declared
+	for _, queue := range []string{"orders", "eyewacatalog"} {
+		_, declared := server.QueueArguments(queue)
+		assert.False(t, declared, queue)
+	}
+}
+
+func TestPublishAndConsume(t *testing.T) {
+	server, client := connectToServer(t, nil)
+
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, &base.EyewaEvent{ID: "1", Name: "product.updated"}))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "application/json", server.Messages("eyewacatalog")[0].ContentType)
+
+	consumed := make(chan *base.EyewaEvent, 1)
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		consumed <- event
+		return nil
+	})
+
+	select {
+	case event := <-consumed:
+		assert.Equal(t, "1", event.ID)
+		assert.Equal(t, "product.updated", event.Name)
+	case <-time.After(time.Second):
+		assert.Fail(t, "event not consumed")
+	}
+
+	assert.Eventually(t, func() bool { return server.Consumers("eyewacatalog") == 1 }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, server.Messages("eyewacatalog"))
+}
+
+func TestFailedEventsDeadlettered(t *testing.T) {
+	server, client := connectToServer(t, nil)
+
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		return errors.New("failed")
+	})
+
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, &base.EyewaEvent{ID: "1"}))
+
+	// not an event - deadlettered as is
+	garbage := []byte("not json")
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	assert.Nil(t, client.PublishEvent(context.Background(), "eyewacatalog", 0, &garbage, wg))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 2 }, time.Second, 10*time.Millisecond)
+	deadlettered := server.Messages("deadletter-eyewacatalog")
+
+	var event base.EyewaEvent
+	assert.Nil(t, json.Unmarshal(deadlettered[0].Body, &event))
+	assert.Equal(t, "1", event.ID)
+	assert.Len(t, event.Errors, 1)
+	assert.Equal(t, "failed", event.Errors[0].ErrorMessage)
+
+	assert.Equal(t, garbage, deadlettered[1].Body)
+
+	// nacked without being requeued
+	assert.Empty(t, server.Messages("eyewacatalog"))
+}
+
+func TestDeadletteredEventsReplayedAsPublished(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_RETRY_MAX_ATTEMPTS":  "1",
+		"RABBITMQ_RETRY_INITIAL_DELAY": "10ms",
+	})
+
+	stop := consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		return errors.New("failed")
+	})
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 3, &base.EyewaEvent{ID: "1", Name: "product.created"}))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+	stop()
+
+	deadlettered := server.Messages("deadletter-eyewacatalog")[0]
+	assert.Equal(t, uint8(3), deadlettered.Priority)
+	assert.NotEmpty(t, deadlettered.Headers["traceparent"])
+	assert.Equal(t, int32(1), deadlettered.Headers["x-retry-count"])
+
+	result, err := client.ReplayDeadletterEvents(context.Background(), "eyewacatalog", ReplayOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Replayed)
+
+	// replayed with the priority and trace context published with, retried afresh
+	replayed := server.Messages("eyewacatalog")
+	if assert.Len(t, replayed, 1) {
+		assert.Equal(t, uint8(3), replayed[0].Priority)
+		assert.Equal(t, deadlettered.Headers["traceparent"], replayed[0].Headers["traceparent"])
+		assert.NotContains(t, replayed[0].Headers, "x-retry-count")
+		assert.NotContains(t, replayed[0].Headers, "x-retry-errors")
+	}
+}
+
+func TestFailedEventsRetried(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_RETRY_MAX_ATTEMPTS":  "1",
+		"RABBITMQ_RETRY_INITIAL_DELAY": "10ms",
+	})
+
+	var mutex sync.Mutex
+	attempts := 0
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		attempts++
+		return errors.New("failed")
+	})
+
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, &base.EyewaEvent{ID: "1"}))
+
+	// retried once via retry-eyewacatalog-10 before being deadlettered
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+
+	_, declared := server.QueueArguments(retryQueueName("eyewacatalog", 10*time.Millisecond))
+	assert.True(t, declared)
+
+	mutex.Lock()
+	assert.Equal(t, 2, attempts)
+	mutex.Unlock()
+
+	var event base.EyewaEvent
+	assert.Nil(t, json.Unmarshal(server.Messages("deadletter-eyewacatalog")[0].Body, &event))
+	assert.Len(t, event.Errors, 2)
+}
+
+func TestFailedStreamEventsNotRetried(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_QUEUES":              `[{"name": "catalog", "consume": true, "publish": true, "exchange_type": "direct", "type": "stream"}]`,
+		"RABBITMQ_RETRY_MAX_ATTEMPTS":  "3",
+		"RABBITMQ_RETRY_INITIAL_DELAY": "10ms",
+	})
+
+	var mutex sync.Mutex
+	attempts := 0
+	consumeEvents(t, client, "catalog", func(event *base.EyewaEvent) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		attempts++
+		return errors.New("failed")
+	})
+
+	assert.Nil(t, publishEvent(client, "catalog", 0, &base.EyewaEvent{ID: "1"}))
+
+	// deadlettered straight away - not republished to the stream by a retry queue
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-catalog")) == 1 }, time.Second, 10*time.Millisecond)
+
+	_, declared := server.QueueArguments(retryQueueName("catalog", 10*time.Millisecond))
+	assert.False(t, declared)
+
+	mutex.Lock()
+	assert.Equal(t, 1, attempts)
+	mutex.Unlock()
+}
+
+func TestConsumeByPriority(t *testing.T) {
+	_, client := connectToServer(t, nil)
+
+	for _, priority := range []int{1, 5, 3, 0} {
+		event := &base.EyewaEvent{ID: string(rune('0' + priority))}
+		assert.Nil(t, publishEvent(client, "eyewacatalog", priority, event))
+	}
+
+	consumed := make(chan string, 4)
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		consumed <- event.ID
+		return nil
+	})
+
+	var ids []string
+	for len(ids) < 4 {
+		select {
+		case id := <-consumed:
+			ids = append(ids, id)
+		case <-time.After(time.Second):
+			assert.Fail(t, "events not consumed", ids)
+			return
+		}
+	}
+
+	assert.Equal(t, []string{"5", "3", "1", "0"}, ids)
+}
+
+func TestPublisherConfirms(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_PUBLISHER_CONFIRMS": "true",
+		"RABBITMQ_QUEUES": `[{
+			"name": "eyewaproducts",
+			"publish": true,
+			"exchange_type": "topic",
+			"routing_keys": ["product.#"],
+			"routing_key_template": "{{.Name}}"
+		}]`,
+	})
+
+	assert.Nil(t, publishEvent(client, "eyewaproducts", 0, &base.EyewaEvent{Name: "product.updated"}))
+
+	// no queue bound to the key
+	assert.Equal(t, libErrs.ErrorPublishReturned, publishEvent(client, "eyewaproducts", 0, &base.EyewaEvent{Name: "category.updated"}))
+
+	errs := client.PublishBatch(context.Background(), "eyewaproducts", 0, []*base.EyewaEvent{
+		{Name: "product.created"},
+		{Name: "category.created"},
+		{Name: "product.deleted"},
+	})
+	assert.Equal(t, []error{nil, libErrs.ErrorPublishReturned, nil}, errs)
+
+	messages := server.Messages("eyewaproducts")
+	assert.Len(t, messages, 3)
+	assert.Equal(t, "product.updated", messages[0].RoutingKey)
+	// returns are matched by message id - unique per event of the batch
+	assert.NotEmpty(t, messages[1].MessageID)
+	assert.NotEqual(t, messages[1].MessageID, messages[2].MessageID)
+}
+
+func TestTracePropagated(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	server, client := connectToServer(t, nil)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	client.Publish(ctx, "eyewacatalog", 0, &base.EyewaEvent{ID: "1"}, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		return err
+	}, wg)
+	wg.Wait()
+
+	event := []byte(`{"id":"2"}`)
+	wg.Add(1)
+	assert.Nil(t, client.PublishEvent(ctx, "eyewacatalog", 0, &event, wg))
+
+	assert.Equal(t, []error{nil}, client.PublishBatch(ctx, "eyewacatalog", 0, []*base.EyewaEvent{{ID: "3"}}))
+
+	// each message carries the span it was published in - a child of the caller's
+	messages := server.Messages("eyewacatalog")
+	assert.Len(t, messages, 3)
+	for _, msg := range messages {
+		traceparent, _ := msg.Headers["traceparent"].(string)
+		parts := strings.Split(traceparent, "-")
+		if assert.Len(t, parts, 4) {
+			assert.Equal(t, span.SpanContext().TraceID().String(), parts[1])
+			assert.NotEqual(t, span.SpanContext().SpanID().String(), parts[2])
+		}
+	}
+}
+
+func TestRecoverConnection(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_AUTO_RECOVERY": "true",
+	})
+
+	var mutex sync.Mutex
+	var states []ConnectionState
+	client.OnStateChange(func(state ConnectionState, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		states = append(states, state)
+	})
+
+	consumed := make(chan string, 2)
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		consumed <- event.ID
+		return nil
+	})
+	assert.Eventually(t, func() bool { return server.Consumers("eyewacatalog") == 1 }, time.Second, 10*time.Millisecond)
+
+	server.DropConnections()
+
+	// consuming resumes once recovered
+	assert.Eventually(t, func() bool {
+		return client.State() == StateConnected && server.Consumers("eyewacatalog") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mutex.Lock()
+	assert.Equal(t, []ConnectionState{StateDisconnected, StateRecovering, StateConnected}, states)
+	mutex.Unlock()
+
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, &base.EyewaEvent{ID: "1"}))
+
+	select {
+	case id := <-consumed:
+		assert.Equal(t, "1", id)
+	case <-time.After(time.Second):
+		assert.Fail(t, "event not consumed after recovering")
+	}
+}
+
+func TestRecoverConnectionWhileInUse(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_AUTO_RECOVERY": "true",
+	})
+
+	server.DropConnections()
+
+	// the connection is replaced while being used
+	assert.Eventually(t, func() bool {
+		client.PublishBatch(context.Background(), "eyewacatalog", 0, []*base.EyewaEvent{{ID: "1"}})
+		return client.State() == StateConnected && client.IsConnectionOpen()
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestConnectionLost(t *testing.T) {
+	server, client := connectToServer(t, nil)
+
+	stopped := make(chan error, 1)
+	go client.Consume("eyewacatalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err != nil {
+			stopped <- err
+		}
+		return err
+	})
+	assert.Eventually(t, func() bool { return server.Consumers("eyewacatalog") == 1 }, time.Second, 10*time.Millisecond)
+
+	server.DropConnections()
+
+	select {
+	case err := <-stopped:
+		assert.Equal(t, libErrs.ErrorLostConnectionToMessageBroker, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "consumer not notified of the lost connection")
+	}
+
+	assert.False(t, client.IsConnectionOpen())
+}
+
+func TestCloudEvents(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"PUBLISHER_QUEUE_NAME":            "eyewacatalog",
+		"RABBITMQ_PUBLISHER_CLOUD_EVENTS": "binary",
+		"SERVICE_NAME":                    "catalogconsumer",
+	})
+
+	event := &base.EyewaEvent{ID: "1", Name: "product.updated", StoreCode: "ae-en", Payload: json.RawMessage(`{"sku":"123"}`)}
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, event))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+	published := server.Messages("eyewacatalog")[0]
+	assert.Equal(t, "application/json", published.ContentType)
+	assert.Equal(t, "product.updated", published.Headers["cloudEvents:type"])
+	assert.Equal(t, "catalogconsumer", published.Headers["cloudEvents:source"])
+	assert.Equal(t, []byte(`{"sku":"123"}`), published.Body)
+
+	// a structured cloud event published by another team's tooling
+	conn, err := amqp.Dial(server.URL())
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	assert.Nil(t, err)
+	assert.Nil(t, ch.Publish("", "eyewacatalog", false, false, amqp.Publishing{
+		ContentType: "application/cloudevents+json",
+		Body:        []byte(`{"specversion":"1.0","id":"2","source":"/erp","type":"stock.updated","storecode":"sa-ar","datacontenttype":"application/json","data":{"qty":1}}`),
+	}))
+	assert.Eventually(t, func() bool { return len(server.Messages("eyewacatalog")) == 2 }, time.Second, 10*time.Millisecond)
+
+	consumed := make(chan *base.EyewaEvent, 2)
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		consumed <- event
+		return errors.New("failed")
+	})
+
+	for _, expected := range []*base.EyewaEvent{
+		{ID: "1", Name: "product.updated", StoreCode: "ae-en", Payload: json.RawMessage(`{"sku":"123"}`)},
+		{ID: "2", Name: "stock.updated", StoreCode: "sa-ar", Payload: json.RawMessage(`{"qty":1}`)},
+	} {
+		select {
+		case event := <-consumed:
+			assert.Equal(t, expected, event)
+		case <-time.After(time.Second):
+			assert.Fail(t, "cloud event not consumed")
+		}
+	}
+
+	// deadlettered as cloud events in the mode they were delivered in
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 2 }, time.Second, 10*time.Millisecond)
+	deadlettered := server.Messages("deadletter-eyewacatalog")
+	assert.Contains(t, deadlettered[0].Headers["cloudEvents:errors"], "failed")
+	assert.Equal(t, "application/cloudevents+json", deadlettered[1].ContentType)
+
+	events, err := client.ListDeadletterEvents(context.Background(), "eyewacatalog", ReplayFilter{ErrorText: "failed"}, 0)
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, []string{"product.updated", "stock.updated"}, []string{events[0].Name, events[1].Name})
+}
+
+func TestContentTypes(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"PUBLISHER_QUEUE_NAME":            "eyewacatalog",
+		"RABBITMQ_PUBLISHER_CONTENT_TYPE": "application/msgpack",
+	})
+
+	event := &base.EyewaEvent{ID: "1", Name: "product.updated", StoreCode: "ae-en", Payload: json.RawMessage(`{"sku":"123"}`)}
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, event))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+	published := server.Messages("eyewacatalog")[0]
+	assert.Equal(t, "application/msgpack", published.ContentType)
+
+	var decoded base.EyewaEvent
+	assert.Nil(t, codec.MsgPack{}.Unmarshal(published.Body, &decoded))
+	assert.Equal(t, *event, decoded)
+
+	consumed := make(chan *base.EyewaEvent, 1)
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		consumed <- event
+		return errors.New("failed")
+	})
+
+	select {
+	case consumed := <-consumed:
+		assert.Equal(t, event, consumed)
+	case <-time.After(time.Second):
+		assert.Fail(t, "event not consumed")
+	}
+
+	// deadlettered in the content type delivered in
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "application/msgpack", server.Messages("deadletter-eyewacatalog")[0].ContentType)
+
+	events, err := client.ListDeadletterEvents(context.Background(), "eyewacatalog", ReplayFilter{ErrorText: "failed"}, 0)
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "product.updated", events[0].Name)
+}
+
+func TestCompression(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"PUBLISHER_QUEUE_NAME":           "eyewacatalog",
+		"RABBITMQ_PUBLISHER_COMPRESSION": "gzip",
+		"RABBITMQ_COMPRESSION_THRESHOLD": "64",
+	})
+
+	small := &base.EyewaEvent{ID: "1", Name: "product.updated", Payload: json.RawMessage(`{"sku":"123"}`)}
+	large := &base.EyewaEvent{ID: "2", Name: "product.updated", Payload: json.RawMessage(`{"sku":"123","variants":["` + strings.Repeat("ray-ban", 100) + `"]}`)}
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, small))
+	assert.Nil(t, publishEvent(client, "eyewacatalog", 0, large))
+
+	body, err := json.Marshal(large)
+	assert.Nil(t, err)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	assert.Nil(t, client.PublishEvent(context.Background(), "eyewacatalog", 0, &body, wg))
+
+	assert.Eventually(t, func() bool { return len(server.Messages("eyewacatalog")) == 3 }, time.Second, 10*time.Millisecond)
+	published := server.Messages("eyewacatalog")
+	assert.Equal(t, "", published[0].ContentEncoding)
+	assert.Equal(t, "gzip", published[1].ContentEncoding)
+	assert.Less(t, len(published[1].Body), len(large.Payload))
+	assert.Equal(t, "gzip", published[2].ContentEncoding)
+
+	consumed := make(chan *base.EyewaEvent, 3)
+	consumeEvents(t, client, "eyewacatalog", func(event *base.EyewaEvent) error {
+		consumed <- event
+		return errors.New("failed")
+	})
+
+	for _, expected := range []*base.EyewaEvent{small, large, large} {
+		select {
+		case event := <-consumed:
+			assert.Equal(t, expected, event)
+		case <-time.After(time.Second):
+			assert.Fail(t, "event not consumed")
+		}
+	}
+
+	// deadlettered decompressed
+	assert.Eventually(t, func() bool { return len(server.Messages("deadletter-eyewacatalog")) == 3 }, time.Second, 10*time.Millisecond)
+	deadlettered := server.Messages("deadletter-eyewacatalog")[1]
+	assert.Equal(t, "", deadlettered.ContentEncoding)
+	assert.Contains(t, string(deadlettered.Body), "failed")
+}
+
+func TestConsumeQuorumQueue(t *testing.T) {
+	server, client := connectToServer(t, map[string]string{
+		"RABBITMQ_QUEUES": `[{"name": "orders", "consume": true, "publish": true, "exchange_type": "direct", "type": "quorum"}]`,
+	})
+
+	event := &base.EyewaEvent{ID: "1", Name: "order.created", Payload: json.RawMessage(`{"id":"1"}`)}
+	assert.Nil(t, publishEvent(client, "orders", 0, event))
+
+	// consumers are refused by quorum queues on channels with a global prefetch
+	consumed := make(chan *base.EyewaEvent, 1)
+	consumeEvents(t, client, "orders", func(event *base.EyewaEvent) error {
+		consumed <- event
+		return nil
+	})
+
+	select {
+	case consumed := <-consumed:
+		assert.Equal(t, event, consumed)
+	case <-time.After(time.Second):
+		assert.Fail(t, "event not consumed")
+	}
+	assert.Equal(t, 1, server.Consumers("orders"))
+}
@@ -20,8 +20,23 @@ type RabbitMQMetrics struct {
 	MarshalEventFailureCounter      *metrics.Counter
 	NackFailureCounter              *metrics.Counter
 	DeadletterPublishFailureCounter *metrics.Counter
+	ConfirmedEventCounter           *metrics.Counter
+	NackedEventCounter              *metrics.Counter
+	ReturnedEventCounter            *metrics.Counter
+	ConfirmTimeoutCounter           *metrics.Counter
+	ConnectionLostCounter           *metrics.Counter
+	RecoveredConnectionCounter      *metrics.Counter
+	RecoveryFailureCounter          *metrics.Counter
+	ConsumerResumedCounter          *metrics.Counter
+	RetriedEventCounter             *metrics.Counter
+	RetryPublishFailureCounter      *metrics.Counter
+	ReplayedEventCounter            *metrics.Counter
+	ShutdownTimeoutCounter          *metrics.Counter
+	CallbackPanicCounter            *metrics.Counter
+	MessageTimeoutCounter           *metrics.Counter
 	ActiveConsumingEventCounter     *metrics.UpDownCounter
 	ConsumedEventLatencyRecorder    *metrics.ValueRecorder
+	CompressionRatioRecorder        *metrics.ValueRecorder
 }
 
 // NewRabbitMQMetrics creates a instance of RabbitMQMetrics
@@ -70,6 +85,90 @@ func NewRabbitMQMetrics() *RabbitMQMetrics {
 		log.Error(errors.ErrorFailedToCreateInstrument.Error())
 	}
 
+	confirmedEventCounter, err := meter.NewCounter("rabbitmq.confirmed.event.counter",
+		metric.WithDescription("Counts published events confirmed by the broker"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	nackedEventCounter, err := meter.NewCounter("rabbitmq.nacked.event.counter",
+		metric.WithDescription("Counts published events nacked by the broker"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	returnedEventCounter, err := meter.NewCounter("rabbitmq.returned.event.counter",
+		metric.WithDescription("Counts published events returned by the broker as unroutable"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	confirmTimeoutCounter, err := meter.NewCounter("rabbitmq.confirm.timeout.counter",
+		metric.WithDescription("Counts published events not confirmed by the broker in time"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	connectionLostCounter, err := meter.NewCounter("rabbitmq.connection.lost.counter",
+		metric.WithDescription("Counts connections to the broker that were lost"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	recoveredConnectionCounter, err := meter.NewCounter("rabbitmq.connection.recovered.counter",
+		metric.WithDescription("Counts lost connections that were recovered"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	recoveryFailureCounter, err := meter.NewCounter("rabbitmq.connection.recovery.failure.counter",
+		metric.WithDescription("Counts lost connections that could not be recovered"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	consumerResumedCounter, err := meter.NewCounter("rabbitmq.consumer.resumed.counter",
+		metric.WithDescription("Counts consumers resumed after a recovery"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	retriedEventCounter, err := meter.NewCounter("rabbitmq.retried.event.counter",
+		metric.WithDescription("Counts failed events scheduled for another attempt"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	retryPublishFailureCounter, err := meter.NewCounter("rabbitmq.retry.publish.failure.counter",
+		metric.WithDescription("Counts failed events that could not be published to a retry queue"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	replayedEventCounter, err := meter.NewCounter("rabbitmq.replayed.event.counter",
+		metric.WithDescription("Counts deadlettered events replayed to their queue"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	shutdownTimeoutCounter, err := meter.NewCounter("rabbitmq.consumer.shutdown.timeout.counter",
+		metric.WithDescription("Counts stopped consumers that timed out waiting for messages in flight"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	callbackPanicCounter, err := meter.NewCounter("rabbitmq.callback.panic.counter",
+		metric.WithDescription("Counts panics recovered from in consumer callbacks"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	messageTimeoutCounter, err := meter.NewCounter("rabbitmq.message.timeout.counter",
+		metric.WithDescription("Counts messages that timed out being processed by consumer callbacks"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
 	activeConsumingEventCounter, err := meter.NewUpDownCounter("rabbitmq.active.consuming.event.counter",
 		metric.WithDescription("Counts active consuming events"))
 	if err != nil {
@@ -83,6 +182,12 @@ func NewRabbitMQMetrics() *RabbitMQMetrics {
 		log.Error(errors.ErrorFailedToCreateInstrument.Error())
 	}
 
+	compressionRatioRecorder, err := meter.NewValueRecorder("rabbitmq.compression.ratio.recorder",
+		metric.WithDescription("Records the ratio of compressed to uncompressed size of published events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
 	return &RabbitMQMetrics{
 		PublishedEventCounter:           publishedEventCounter,
 		PublishEventFailureCounter:      publishEventFailureCounter,
@@ -91,7 +196,22 @@ func NewRabbitMQMetrics() *RabbitMQMetrics {
 		UnmarshalEventFailureCounter:    unmarshalEventFailureCounter,
 		NackFailureCounter:              nackFailureCounter,
 		DeadletterPublishFailureCounter: deadletterPublishFailureCounter,
+		ConfirmedEventCounter:           confirmedEventCounter,
+		NackedEventCounter:              nackedEventCounter,
+		ReturnedEventCounter:            returnedEventCounter,
+		ConfirmTimeoutCounter:           confirmTimeoutCounter,
+		ConnectionLostCounter:           connectionLostCounter,
+		RecoveredConnectionCounter:      recoveredConnectionCounter,
+		RecoveryFailureCounter:          recoveryFailureCounter,
+		ConsumerResumedCounter:          consumerResumedCounter,
+		RetriedEventCounter:             retriedEventCounter,
+		RetryPublishFailureCounter:      retryPublishFailureCounter,
+		ReplayedEventCounter:            replayedEventCounter,
+		ShutdownTimeoutCounter:          shutdownTimeoutCounter,
+		CallbackPanicCounter:            callbackPanicCounter,
+		MessageTimeoutCounter:           messageTimeoutCounter,
 		ActiveConsumingEventCounter:     activeConsumingEventCounter,
 		ConsumedEventLatencyRecorder:    consumedEventLatencyRecorder,
+		CompressionRatioRecorder:        compressionRatioRecorder,
 	}
 }
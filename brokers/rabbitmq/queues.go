@@ -0,0 +1,395 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/template"
+
+	"github.com/eyewa/eyewa-go-lib/cloudevents"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/streadway/amqp"
+)
+
+// QueueConfig declares a queue the client consumes from and/or publishes to.
+type QueueConfig struct {
+	Name string `json:"name"`
+
+	// Channels for queues to consume from/publish to are created on Connect
+	Consume bool `json:"consume"`
+	Publish bool `json:"publish"`
+
+	// direct, fanout, topic, headers or bind - to bind the queue to Exchange.
+	// Exchanges other than bind are named <queue>.<type>
+	ExchangeType string `json:"exchange_type"`
+	Exchange     string `json:"exchange"`
+
+	// Keys the queue is bound to its exchange with - defaults to the queue name.
+	// For topic exchanges these are patterns e.g product.*.ae-en
+	RoutingKeys []string `json:"routing_keys"`
+
+	// Headers the queue is bound to a headers exchange with + whether all (default)
+	// or any of them have to match
+	BindHeaders map[string]interface{} `json:"bind_headers"`
+	Match       string                 `json:"match"`
+
+	// Templates of the routing key + headers events are published to the exchange
+	// of the queue with - rendered from the event's fields e.g
+	// {{.EventType}}.{{.Name}}.{{.StoreCode}}. Only for topic and headers exchanges.
+	RoutingKeyTemplate string            `json:"routing_key_template"`
+	RoutingHeaders     map[string]string `json:"routing_headers"`
+
+	// No. of messsages RMQ should send to a consumer - defaults to QUEUE_PREFETCH_COUNT
+	Prefetch int `json:"prefetch"`
+
+	// Max priority of messages in the queue - defaults to 5. Classic queues only
+	MaxPriority int `json:"max_priority"`
+
+	// classic (default), quorum or stream
+	Type string `json:"type"`
+
+	// Quorum queues only - no. of times a message is delivered before it is
+	// deadlettered as a poison message e.g one crashing its consumers
+	DeliveryLimit int `json:"delivery_limit"`
+
+	// Streams only - how long messages are kept e.g 7D and where consumers start
+	// consuming from - see ParseStreamOffset. defaults to next
+	MaxAge       string `json:"max_age"`
+	StreamOffset string `json:"stream_offset"`
+
+	// Queue failed messages are deadlettered to - defaults to deadletter-<queue>
+	DeadletterQueue string `json:"deadletter_queue"`
+
+	// Publish events to the queue as CloudEvents - structured or binary. Consumers
+	// accept cloud events in either mode regardless.
+	CloudEvents string `json:"cloud_events"`
+
+	// Content type events are published to the queue as e.g application/msgpack -
+	// defaults to that of the codec publishing them. Consumers decode events by
+	// the content type they are delivered with regardless. See codec.Lookup.
+	ContentType string `json:"content_type"`
+
+	// Compress events published to the queue - gzip or zstd - once encoded
+	// larger than CompressionThreshold bytes, defaulting to RABBITMQ_COMPRESSION_THRESHOLD.
+	// Consumers decompress events by the content encoding they are delivered with regardless.
+	Compression          string `json:"compression"`
+	CompressionThreshold int    `json:"compression_threshold"`
+
+	keyTemplate     *template.Template
+	headerTemplates map[string]*template.Template
+}
+
+// routing where a message is published to.
+type routing struct {
+	exchange string
+	key      string
+	headers  amqp.Table
+}
+
+// parseQueues parses the queues declared in RABBITMQ_QUEUES - a JSON list of QueueConfig.
+func parseQueues(queues string) ([]QueueConfig, error) {
+	if queues == "" {
+		return nil, nil
+	}
+
+	var configs []QueueConfig
+	if err := json.Unmarshal([]byte(queues), &configs); err != nil {
+		return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), err)
+	}
+
+	for i, q := range configs {
+		if q.Name == "" {
+			return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), libErrs.ErrorQueueNotSpecified)
+		}
+
+		switch q.Type {
+		case "", queueTypeClassic, queueTypeQuorum, queueTypeStream:
+		default:
+			return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), fmt.Sprintf("unknown queue type %s", q.Type))
+		}
+
+		if _, err := ParseStreamOffset(q.StreamOffset); err != nil {
+			return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), err)
+		}
+
+		if q.CloudEvents != "" {
+			if _, err := cloudevents.ParseMode(q.CloudEvents); err != nil {
+				return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), err)
+			}
+		}
+
+		if q.ContentType != "" {
+			if _, err := codec.Lookup(q.ContentType); err != nil {
+				return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), err)
+			}
+		}
+
+		if q.Compression != "" {
+			if err := compression.Validate(q.Compression); err != nil {
+				return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), err)
+			}
+		}
+
+		if err := configs[i].parseTemplates(); err != nil {
+			return nil, fmt.Errorf(libErrs.ErrorInvalidQueueConfig.Error(), err)
+		}
+	}
+
+	return configs, nil
+}
+
+// parseTemplates parses the routing key + header templates of the queue.
+func (q *QueueConfig) parseTemplates() error {
+	var err error
+
+	if q.RoutingKeyTemplate != "" {
+		if q.keyTemplate, err = template.New(q.Name).Option("missingkey=error").Parse(q.RoutingKeyTemplate); err != nil {
+			return err
+		}
+	}
+
+	if len(q.RoutingHeaders) > 0 {
+		q.headerTemplates = make(map[string]*template.Template, len(q.RoutingHeaders))
+		for header, text := range q.RoutingHeaders {
+			if q.headerTemplates[header], err = template.New(header).Option("missingkey=error").Parse(text); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// render renders a routing template with the fields of event.
+func render(tmpl *template.Template, event interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// queueConfigs all queues declared - those in RABBITMQ_QUEUES along with
+// the CONSUMER_QUEUE_NAME and PUBLISHER_QUEUE_NAME queues.
+func queueConfigs() []QueueConfig {
+	queues := make([]QueueConfig, 0, len(config.queues)+2)
+	declared := make(map[string]int, len(config.queues)+2)
+
+	add := func(q QueueConfig) {
+		if i, exists := declared[q.Name]; exists {
+			queues[i].Consume = queues[i].Consume || q.Consume
+			queues[i].Publish = queues[i].Publish || q.Publish
+			if queues[i].CloudEvents == "" {
+				queues[i].CloudEvents = q.CloudEvents
+			}
+			if queues[i].ContentType == "" {
+				queues[i].ContentType = q.ContentType
+			}
+			if queues[i].Compression == "" {
+				queues[i].Compression = q.Compression
+			}
+			return
+		}
+
+		declared[q.Name] = len(queues)
+		queues = append(queues, q)
+	}
+
+	for _, q := range config.queues {
+		add(q)
+	}
+
+	if config.ConsumerQueueName != "" {
+		add(QueueConfig{
+			Name:         config.ConsumerQueueName,
+			Consume:      true,
+			ExchangeType: config.ConsumerExchangeType,
+			Exchange:     config.ConsumerExchange,
+		})
+	}
+
+	if config.PublisherQueueName != "" {
+		add(QueueConfig{
+			Name:         config.PublisherQueueName,
+			Publish:      true,
+			ExchangeType: config.PublisherExchangeType,
+			Exchange:     config.ConsumerExchange,
+			CloudEvents:  config.PublisherCloudEvents,
+			ContentType:  config.PublisherContentType,
+			Compression:  config.PublisherCompression,
+		})
+	}
+
+	return queues
+}
+
+// queueConfig gets the config of a queue. Queues not declared are bound to a direct exchange.
+func queueConfig(queue string) QueueConfig {
+	for _, q := range queueConfigs() {
+		if q.Name == queue {
+			return q
+		}
+	}
+
+	return QueueConfig{Name: queue, ExchangeType: amqp.ExchangeDirect}
+}
+
+// deadletterQueueConfig the config of a deadletter queue.
+func deadletterQueueConfig(deadletterQ string) QueueConfig {
+	return QueueConfig{Name: deadletterQ, ExchangeType: amqp.ExchangeDirect}
+}
+
+// exchangeType the type of exchange the queue is bound to - empty if none.
+func (q QueueConfig) exchangeType() string {
+	return exchangeTypes[q.ExchangeType]
+}
+
+// exchange the name of the exchange the queue is bound to.
+func (q QueueConfig) exchange() string {
+	switch exchType := q.exchangeType(); exchType {
+	case exchangeBind:
+		return q.Exchange
+	case "":
+		return ""
+	default:
+		return fmt.Sprintf("%s.%s", q.Name, exchType)
+	}
+}
+
+// routingKeys the keys the queue is bound to its exchange with.
+func (q QueueConfig) routingKeys() []string {
+	if len(q.RoutingKeys) == 0 {
+		return []string{q.Name}
+	}
+
+	return q.RoutingKeys
+}
+
+// prefetch no. of messages RMQ should send to a consumer of the queue.
+func (q QueueConfig) prefetch() int {
+	if q.Prefetch > 0 {
+		return q.Prefetch
+	}
+
+	prefetchCount, _ := strconv.Atoi(config.QueuePrefetchCount)
+	if prefetchCount <= 0 {
+		return defaultPrefetchCount
+	}
+
+	return prefetchCount
+}
+
+// globalQos if the prefetch of the queue's channel is shared by all its consumers.
+// Quorum queues and streams only support a prefetch per consumer.
+func (q QueueConfig) globalQos() bool {
+	switch q.Type {
+	case queueTypeQuorum, queueTypeStream:
+		return false
+	default:
+		return true
+	}
+}
+
+// maxPriority max priority of messages in the queue.
+func (q QueueConfig) maxPriority() int {
+	if q.MaxPriority > 0 {
+		return q.MaxPriority
+	}
+
+	return defaultMaxPriority
+}
+
+// arguments the arguments to declare the queue with.
+func (q QueueConfig) arguments() amqp.Table {
+	switch q.Type {
+	case queueTypeQuorum:
+		args := amqp.Table{"x-queue-type": queueTypeQuorum}
+		if q.DeliveryLimit > 0 {
+			// poison messages go to the deadletter queue
+			args["x-delivery-limit"] = int64(q.DeliveryLimit)
+			args["x-dead-letter-exchange"] = ""
+			args["x-dead-letter-routing-key"] = q.deadletterQueue()
+		}
+		return args
+	case queueTypeStream:
+		args := amqp.Table{"x-queue-type": queueTypeStream}
+		if q.MaxAge != "" {
+			args["x-max-age"] = q.MaxAge
+		}
+		return args
+	default:
+		return amqp.Table{"x-max-priority": q.maxPriority()}
+	}
+}
+
+// bindArguments the arguments to bind the queue to its exchange with.
+func (q QueueConfig) bindArguments() amqp.Table {
+	if len(q.BindHeaders) == 0 {
+		return nil
+	}
+
+	args := make(amqp.Table, len(q.BindHeaders)+1)
+	for k, v := range q.BindHeaders {
+		args[k] = tableValue(v)
+	}
+
+	args["x-match"] = "all"
+	if q.Match != "" {
+		args["x-match"] = q.Match
+	}
+
+	return args
+}
+
+// route where to publish an event for the queue to. Events for queues bound to
+// a fanout exchange are published to the exchange, for topic and headers exchanges
+// to the exchange with the routing key + headers rendered from the event, otherwise
+// directly to the queue.
+func (q QueueConfig) route(event interface{}) (routing, error) {
+	var r routing
+
+	for header, tmpl := range q.headerTemplates {
+		value, err := render(tmpl, event)
+		if err != nil {
+			return r, fmt.Errorf(libErrs.ErrorRoutingFailure.Error(), q.Name, err)
+		}
+
+		if r.headers == nil {
+			r.headers = make(amqp.Table, len(q.headerTemplates))
+		}
+		r.headers[header] = value
+	}
+
+	switch q.exchangeType() {
+	case amqp.ExchangeFanout:
+		r.exchange = q.exchange()
+	case amqp.ExchangeTopic, amqp.ExchangeHeaders:
+		r.exchange = q.exchange()
+		r.key = q.Name
+
+		if q.keyTemplate != nil {
+			key, err := render(q.keyTemplate, event)
+			if err != nil {
+				return r, fmt.Errorf(libErrs.ErrorRoutingFailure.Error(), q.Name, err)
+			}
+			r.key = key
+		}
+	default:
+		r.key = q.Name
+	}
+
+	return r, nil
+}
+
+// deadletterQueue the queue failed messages of the queue are deadlettered to.
+func (q QueueConfig) deadletterQueue() string {
+	if q.DeadletterQueue != "" {
+		return q.DeadletterQueue
+	}
+
+	return fmt.Sprintf("%s-%s", "deadletter", q.Name)
+}
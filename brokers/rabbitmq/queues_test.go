@@ -0,0 +1,159 @@
+package rabbitmq
+
+import (
+	"os"
+	"testing"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueuesConfig(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("CONSUMER_QUEUE_NAME", "eyewacatalog")
+	os.Setenv("RABBITMQ_CONSUMER_EXCHANGE_TYPE", "direct")
+	os.Setenv("RABBITMQ_QUEUES", `[
+		{"name": "orders", "consume": true, "exchange_type": "topic", "routing_keys": ["order.*"], "prefetch": 20},
+		{"name": "stock", "publish": true, "exchange_type": "fanout", "deadletter_queue": "stock-failed"}
+	]`)
+	defer func() {
+		os.Clearenv()
+		config = Config{}
+	}()
+
+	_, _, err := initConfig()
+	assert.Nil(t, err)
+
+	queues := queueConfigs()
+	assert.Len(t, queues, 3)
+	assert.Equal(t, []string{"orders", "stock", "eyewacatalog"}, []string{queues[0].Name, queues[1].Name, queues[2].Name})
+
+	orders := queueConfig("orders")
+	assert.True(t, orders.Consume)
+	assert.Equal(t, "orders.topic", orders.exchange())
+	assert.Equal(t, []string{"order.*"}, orders.routingKeys())
+	assert.Equal(t, 20, orders.prefetch())
+	assert.Equal(t, "deadletter-orders", deadletterQueueName("orders"))
+
+	stock := queueConfig("stock")
+	r, err := stock.route(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, routing{exchange: "stock.fanout"}, r)
+	assert.Equal(t, "stock-failed", deadletterQueueName("stock"))
+
+	catalog := queueConfig("eyewacatalog")
+	assert.True(t, catalog.Consume)
+	assert.Equal(t, []string{"eyewacatalog"}, catalog.routingKeys())
+	assert.Equal(t, defaultPrefetchCount, catalog.prefetch())
+	assert.Equal(t, defaultMaxPriority, catalog.maxPriority())
+	r, err = catalog.route(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, routing{key: "eyewacatalog"}, r)
+}
+
+func TestQueueGlobalQos(t *testing.T) {
+	tests := []struct {
+		queueType string
+		global    bool
+	}{
+		{"", true},
+		{queueTypeClassic, true},
+		{queueTypeQuorum, false},
+		{queueTypeStream, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.global, QueueConfig{Type: tt.queueType}.globalQos(), tt.queueType)
+	}
+}
+
+func TestQueueConfigUndeclared(t *testing.T) {
+	config = Config{}
+
+	q := queueConfig("anything")
+	assert.Equal(t, "anything", q.Name)
+	assert.Equal(t, amqp.ExchangeDirect, q.ExchangeType)
+	assert.Equal(t, "anything.direct", q.exchange())
+}
+
+func TestParseQueues(t *testing.T) {
+	tests := []struct {
+		name    string
+		queues  string
+		count   int
+		invalid bool
+	}{
+		{"none", "", 0, false},
+		{"valid", `[{"name": "orders"}, {"name": "stock"}]`, 2, false},
+		{"not JSON", `orders,stock`, 0, true},
+		{"invalid template", `[{"name": "orders", "routing_key_template": "{{.Name"}]`, 0, true},
+		{"missing name", `[{"consume": true}]`, 0, true},
+		{"cloud events", `[{"name": "orders", "cloud_events": "binary"}]`, 1, false},
+		{"unknown cloud events mode", `[{"name": "orders", "cloud_events": "batched"}]`, 0, true},
+		{"content type", `[{"name": "orders", "content_type": "application/msgpack"}]`, 1, false},
+		{"unknown content type", `[{"name": "orders", "content_type": "text/csv"}]`, 0, true},
+		{"compression", `[{"name": "orders", "compression": "zstd", "compression_threshold": 4096}]`, 1, false},
+		{"unknown compression", `[{"name": "orders", "compression": "br"}]`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queues, err := parseQueues(tt.queues)
+			if tt.invalid {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Len(t, queues, tt.count)
+		})
+	}
+}
+
+func TestQueueRoute(t *testing.T) {
+	queues, err := parseQueues(`[
+		{"name": "catalog", "exchange_type": "topic", "routing_key_template": "{{.EventType}}.{{.Name}}.{{.StoreCode}}"},
+		{"name": "stores", "exchange_type": "headers", "routing_headers": {"store_code": "{{.StoreCode}}", "event": "{{.Name}}"}},
+		{"name": "untemplated", "exchange_type": "topic"},
+		{"name": "broken", "exchange_type": "topic", "routing_key_template": "{{.Missing}}"}
+	]`)
+	assert.Nil(t, err)
+
+	event := &base.EyewaEvent{Name: "product.created", EventType: "Product", StoreCode: "ae-en"}
+
+	tests := []struct {
+		queue    QueueConfig
+		expected routing
+		invalid  bool
+	}{
+		{queues[0], routing{exchange: "catalog.topic", key: "Product.product.created.ae-en"}, false},
+		{queues[1], routing{
+			exchange: "stores.headers",
+			key:      "stores",
+			headers:  amqp.Table{"store_code": "ae-en", "event": "product.created"},
+		}, false},
+		{queues[2], routing{exchange: "untemplated.topic", key: "untemplated"}, false},
+		{queues[3], routing{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.queue.Name, func(t *testing.T) {
+			r, err := tt.queue.route(event)
+			if tt.invalid {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, r)
+		})
+	}
+}
+
+func TestQueueBindArguments(t *testing.T) {
+	assert.Nil(t, QueueConfig{Name: "orders"}.bindArguments())
+
+	q := QueueConfig{Name: "stores", BindHeaders: map[string]interface{}{"store_code": "ae-en"}, Match: "any"}
+	assert.Equal(t, amqp.Table{"store_code": "ae-en", "x-match": "any"}, q.bindArguments())
+}
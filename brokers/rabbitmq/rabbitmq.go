@@ -2,7 +2,6 @@ package rabbitmq
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -11,18 +10,15 @@ import (
 	"sync"
 	"time"
 
-	"github.com/eyewa/eyewa-go-lib/utils"
-
-	"go.opentelemetry.io/otel/codes"
-
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/semconv"
 
 	"github.com/cenkalti/backoff"
 	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/cloudevents"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
 	libErrs "github.com/eyewa/eyewa-go-lib/errors"
 	"github.com/eyewa/eyewa-go-lib/log"
-	amqptracing "github.com/eyewa/eyewa-go-lib/tracing/amqp"
 	"github.com/ory/viper"
 	"github.com/streadway/amqp"
 	"go.opentelemetry.io/otel"
@@ -33,6 +29,7 @@ import (
 var (
 	config          Config
 	standardMetrics *RabbitMQMetrics
+	metricsOnce     sync.Once
 	exchangeBind    = "bind"
 	exchangeTypes   = map[string]string{
 		amqp.ExchangeDirect:  amqp.ExchangeDirect,
@@ -41,9 +38,13 @@ var (
 		amqp.ExchangeTopic:   amqp.ExchangeTopic,
 		exchangeBind:         exchangeBind,
 	}
-	defaultPrefetchCount        = 5
-	tracerName                  = "github.com/eyewa/eyewa-go-lib/brokers/rabbitmq"
-	maxConnectionRetries uint64 = 100
+	defaultPrefetchCount          = 5
+	defaultMaxPriority            = 5
+	defaultConfirmTimeout         = 5 * time.Second
+	defaultShutdownTimeout        = 30 * time.Second
+	confirmBufferSize             = 64
+	tracerName                    = "github.com/eyewa/eyewa-go-lib/brokers/rabbitmq"
+	maxConnectionRetries   uint64 = 100
 )
 
 func initConfig() (Config, string, error) {
@@ -61,9 +62,28 @@ func initConfig() (Config, string, error) {
 		"PUBLISHER_QUEUE_NAME",
 		"CONSUMER_QUEUE_NAME",
 		"QUEUE_PREFETCH_COUNT",
+		"CONSUMER_WORKER_COUNT",
+		"CONSUMER_ORDERING_KEY",
+		"CONSUMER_SHUTDOWN_TIMEOUT",
+		"CONSUMER_MESSAGE_TIMEOUT",
 		"RABBITMQ_CONSUMER_EXCHANGE",
+		"RABBITMQ_QUEUES",
+		"RABBITMQ_TOPOLOGY_FILE",
+		"RABBITMQ_TOPOLOGY_VERIFY",
 		"RABBITMQ_PUBLISHER_EXCHANGE_TYPE",
 		"RABBITMQ_CONSUMER_EXCHANGE_TYPE",
+		"RABBITMQ_PUBLISHER_CONFIRMS",
+		"RABBITMQ_CONFIRM_TIMEOUT",
+		"RABBITMQ_AUTO_RECOVERY",
+		"RABBITMQ_RETRY_MAX_ATTEMPTS",
+		"RABBITMQ_RETRY_INITIAL_DELAY",
+		"RABBITMQ_RETRY_MULTIPLIER",
+		"RABBITMQ_RETRY_MAX_DELAY",
+		"RABBITMQ_PUBLISHER_CLOUD_EVENTS",
+		"RABBITMQ_CLOUD_EVENTS_SOURCE",
+		"RABBITMQ_PUBLISHER_CONTENT_TYPE",
+		"RABBITMQ_PUBLISHER_COMPRESSION",
+		"RABBITMQ_COMPRESSION_THRESHOLD",
 		"MESSAGE_BROKER",
 	}
 
@@ -78,6 +98,30 @@ func initConfig() (Config, string, error) {
 		return config, "", err
 	}
 
+	queues, err := parseQueues(config.Queues)
+	if err != nil {
+		return config, "", err
+	}
+	config.queues = queues
+
+	if config.PublisherCloudEvents != "" {
+		if _, err := cloudevents.ParseMode(config.PublisherCloudEvents); err != nil {
+			return config, "", err
+		}
+	}
+
+	if config.PublisherContentType != "" {
+		if _, err := codec.Lookup(config.PublisherContentType); err != nil {
+			return config, "", err
+		}
+	}
+
+	if config.PublisherCompression != "" {
+		if err := compression.Validate(config.PublisherCompression); err != nil {
+			return config, "", err
+		}
+	}
+
 	connStr := fmt.Sprintf("amqp://%s:%s@%s:%s/", config.Username, config.Password,
 		config.Server, config.AmqpPort)
 
@@ -96,13 +140,29 @@ func NewRMQClient() *RMQClient {
 		mutex:      new(sync.RWMutex),
 		connection: nil,
 		channels:   make(map[string]*amqp.Channel),
+		confirmers: make(map[string]*confirmer),
 	}
 }
 
 // Connect establishes connnection to the message broker of choice
 func (rmq *RMQClient) Connect() error {
+	return rmq.connect(true)
+}
+
+// ConnectWithoutQueues connects to RMQ without any queues having to be declared,
+// for working with queues given explicitly e.g replaying deadlettered events.
+func (rmq *RMQClient) ConnectWithoutQueues() error {
+	return rmq.connect(false)
+}
+
+// connect connects to RMQ - failing if no queues are declared and they are required.
+func (rmq *RMQClient) connect(requireQueues bool) error {
+	if rmq.mutex == nil {
+		rmq.mutex = new(sync.RWMutex)
+	}
+
 	// if a connection already exists, back off.
-	if rmq.connection != nil {
+	if rmq.currentConnection() != nil {
 		return nil
 	}
 
@@ -112,11 +172,11 @@ func (rmq *RMQClient) Connect() error {
 		return err
 	}
 
-	// init metrics
-	standardMetrics = NewRabbitMQMetrics()
+	// init metrics - once, as they are shared by all clients
+	metricsOnce.Do(func() { standardMetrics = NewRabbitMQMetrics() })
 
 	// if no queues are specified, back off.
-	if config.ConsumerQueueName == "" && config.PublisherQueueName == "" {
+	if requireQueues && len(queueConfigs()) == 0 && config.TopologyFile == "" {
 		return libErrs.ErrorNoQueuesSpecified
 	}
 
@@ -126,535 +186,66 @@ func (rmq *RMQClient) Connect() error {
 		return err
 	}
 
+	rmq.mutex.Lock()
 	rmq.connection = conn
-	rmq.mutex = new(sync.RWMutex)
+	rmq.connStr = connStr
 	rmq.channels = make(map[string]*amqp.Channel)
+	rmq.confirmers = make(map[string]*confirmer)
+	rmq.mutex.Unlock()
 
-	// create channel for consuming (if any)
-	if config.ConsumerQueueName != "" {
-		if err := rmq.createConsumerChannel(); err != nil {
-			return err
-		}
+	// set up exchanges, queues + bindings from topology (if any)
+	if err := rmq.setupTopology(); err != nil {
+		return err
 	}
 
-	// create channel for publish (if any)
-	if config.PublisherQueueName != "" {
-		if err := rmq.createPublisherChannel(); err != nil {
-			return err
-		}
+	// create channels for consuming + publishing (if any)
+	if err := rmq.createQueueChannels(); err != nil {
+		return err
 	}
 
 	// connection listener
 	rmq.ConnectionListener()
+	rmq.setState(StateConnected, nil)
 
 	return nil
 }
 
 // Consume consumes messages from a queue
 func (rmq *RMQClient) Consume(queue string, callback base.MessageBrokerCallbackFunc) {
-	ctx := context.Background()
-	defer func() {
-		// reaching here means the connection meant to be long lived has died.
-		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
-	}()
-
-	rmq.mutex.RLock()
-	channel, exists := rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	// check if channel exists for queue
-	// if not create/re-recreate it
-	if !exists && channel == nil {
-		log.Debug(fmt.Sprintf("%s channel doesn't exist. Recreating...", queue))
-		channel, err := rmq.CreateNewChannel(config.ConsumerQueueName)
-		if err != nil {
-			_ = callback(ctx, nil, err)
-			return
-		}
-
-		errQ := rmq.declareQueue(channel, config.ConsumerQueueName, config.ConsumerExchangeType, config.ConsumerExchange)
-		if errQ != nil {
-			_ = callback(ctx, nil, errQ)
-			return
-		}
-	}
-
-	rmq.mutex.RLock()
-	channel, exists = rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	if !exists {
-		_ = callback(ctx, nil, libErrs.ErrorChannelDoesNotExist)
-		return
-	}
-
-	if channel != nil {
-		log.Info(fmt.Sprintf("Listening to %s for new messages...", queue))
-
-		// attempt to consume events from broker
-		msgs, err := channel.Consume(queue, getNameForChannel(queue), false, false, false, false, nil)
-		if err != nil {
-			_ = callback(ctx, nil, fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), queue, err))
-			return
-		}
-
-		var event *base.EyewaEvent
-
-		// handle incoming messages
-		for msg := range msgs {
-			started := time.Now()
-
-			// set amqp message span attributes.
-			spanOpts := []trace.SpanOption{
-				trace.WithAttributes(
-					semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
-					semconv.MessagingDestinationKindKeyQueue,
-					semconv.MessagingOperationReceive,
-					semconv.MessagingRabbitMQRoutingKeyKey.String(msg.RoutingKey)),
-				trace.WithSpanKind(trace.SpanKindConsumer),
-			}
-
-			// extract context from headers, if none, the
-			// context will use the background context.
-			carrier := amqptracing.NewHeaderCarrier(msg.Headers)
-			log.Debug(fmt.Sprintf("carrier before extract: %v", carrier.Keys()))
-
-			ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
-			log.Debug(fmt.Sprintf("carrier after extract: %v", carrier.Keys()))
-
-			// start the span and and receive a new ctx containing the parent
-			ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.Consume", spanOpts...)
-
-			go standardMetrics.ActiveConsumingEventCounter.Add(1)
-
-			// attempt to unmarshal event
-			err := json.Unmarshal(msg.Body, &event)
-			if err != nil {
-				unErrEvent := unmarshalledEyewaEvent{
-					unmarshalledCommon{
-						queue:   queue,
-						msg:     msg,
-						span:    span,
-						started: started,
-						err:     err,
-					},
-					event,
-					callback,
-				}
-
-				rmq.handleUnmarshalledEyewaEventErr(ctx, unErrEvent)
-
-				// continue to the next message
-				continue
-			}
-
-			// nack if callback/service yields an error for whatever reason
-			if err := callback(ctx, event, nil); err != nil {
-				span.RecordError(err)
-
-				// nack message and remove from queue
-				if errNack := msg.Nack(false, false); errNack != nil {
-					go standardMetrics.NackFailureCounter.Add(1)
-					span.RecordError(errNack)
-					log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errNack.Error())
-				}
-
-				// publish message to DL
-				if errDL := rmq.SendToDeadletterQueue(msg, err); errDL != nil {
-					go standardMetrics.DeadletterPublishFailureCounter.Add(1)
-					span.RecordError(errDL)
-					log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errDL.Error())
-				}
-
-				go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
-				go standardMetrics.ActiveConsumingEventCounter.Add(-1)
-
-				// continue to the next message
-				span.End()
-				continue
-			}
-
-			// ack message
-			if err := msg.Ack(false); err != nil {
-				span.RecordError(err)
-				log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
-					err.Error(),
-					zap.String("queue", queue),
-					zap.String("event", string(msg.Body)))
-
-				// nack message and return to queue
-				if err := msg.Nack(false, true); err != nil {
-					go standardMetrics.NackFailureCounter.Add(1)
-					span.RecordError(err)
-					log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
-						err.Error(),
-						zap.String("queue", queue),
-						zap.String("event", string(msg.Body)))
-				}
-
-				// continue to the next message
-				span.End()
-				continue
-			}
-
-			log.Debug("Consumed successfully.", zap.Any("event", event))
+	rmq.ConsumeWithContext(context.Background(), queue, callback)
+}
 
-			go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
-			go standardMetrics.ConsumedEventCounter.Add(1, attribute.Any("event_name", event.Name))
-			go standardMetrics.ActiveConsumingEventCounter.Add(-1)
-			span.End()
-		}
-	}
+// ConsumeWithContext consumes messages from a queue until ctx is done. See
+// process for how consuming is stopped.
+func (rmq *RMQClient) ConsumeWithContext(ctx context.Context, queue string, callback base.MessageBrokerCallbackFunc) {
+	consume(ctx, rmq, queue, "RabbitMQ.Consume", codec.JSON{}, Callback[base.EyewaEvent](callback))
 }
 
-// Consume consumes messages from a queue
+// ConsumeMagentoProductEvents consumes magento product events from a queue
 func (rmq *RMQClient) ConsumeMagentoProductEvents(queue string, callback base.MessageBrokerMagentoProductCallbackFunc) {
-	ctx := context.Background()
-	defer func() {
-		// reaching here means the connection meant to be long lived has died.
-		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
-	}()
-
-	rmq.mutex.RLock()
-	channel, exists := rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	// check if channel exists for queue
-	// if not create/re-recreate it
-	if !exists && channel == nil {
-		log.Debug(fmt.Sprintf("%s channel doesn't exist. Recreating...", queue))
-		channel, err := rmq.CreateNewChannel(config.ConsumerQueueName)
-		if err != nil {
-			_ = callback(ctx, nil, err)
-			return
-		}
-
-		errQ := rmq.declareQueue(channel, config.ConsumerQueueName, config.ConsumerExchangeType, config.ConsumerExchange)
-		if errQ != nil {
-			_ = callback(ctx, nil, errQ)
-			return
-		}
-	}
-
-	rmq.mutex.RLock()
-	channel, exists = rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	if !exists {
-		_ = callback(ctx, nil, libErrs.ErrorChannelDoesNotExist)
-		return
-	}
-
-	if channel != nil {
-		log.Info(fmt.Sprintf("Listening to %s for new messages...", queue))
-
-		// attempt to consume events from broker
-		msgs, err := channel.Consume(queue, getNameForChannel(queue), false, false, false, false, nil)
-		if err != nil {
-			_ = callback(ctx, nil, fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), queue, err))
-			return
-		}
-
-		var event *base.MagentoProductEvent
-
-		// handle incoming messages
-		for msg := range msgs {
-			started := time.Now()
-
-			// set amqp message span attributes.
-			spanOpts := []trace.SpanOption{
-				trace.WithAttributes(
-					semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
-					semconv.MessagingDestinationKindKeyQueue,
-					semconv.MessagingOperationReceive,
-					semconv.MessagingRabbitMQRoutingKeyKey.String(msg.RoutingKey)),
-				trace.WithSpanKind(trace.SpanKindConsumer),
-			}
-
-			// extract context from headers, if none, the
-			// context will use the background context.
-			carrier := amqptracing.NewHeaderCarrier(msg.Headers)
-			log.Debug(fmt.Sprintf("carrier before extract: %v", carrier.Keys()))
-
-			ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
-			log.Debug(fmt.Sprintf("carrier after extract: %v", carrier.Keys()))
-
-			// start the span and and receive a new ctx containing the parent
-			ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.ConsumeMagentoProductEvents", spanOpts...)
-
-			go standardMetrics.ActiveConsumingEventCounter.Add(1)
-
-			// attempt to unmarshal event
-			if err := json.Unmarshal(msg.Body, &event); err != nil {
-				unErrEvent := unmarshalledMagentoEvent{
-					unmarshalledCommon{
-						queue:   queue,
-						msg:     msg,
-						span:    span,
-						started: started,
-						err:     err,
-					},
-					event,
-					callback,
-				}
-
-				rmq.handleUnmarshalledMagentoEventErr(ctx, unErrEvent)
-
-				// continue to the next message
-				continue
-			}
-
-			// nack if callback/service yields an error for whatever reason
-			if err := callback(ctx, event, nil); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				// nack message and remove from queue
-				if errNack := msg.Nack(false, false); errNack != nil {
-					go standardMetrics.NackFailureCounter.Add(1)
-					span.RecordError(errNack)
-					span.SetStatus(codes.Error, errNack.Error())
-					log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errNack.Error())
-				}
-
-				// set this header to be sure that base.MagentoProductEvent
-				// will be sent to the dead letter queue instead of base.EyewaEvent
-				if msg.Headers == nil {
-					msg.Headers = make(amqp.Table)
-				}
-				msg.Headers["x-type-of-event"] = "magento"
-
-				// publish message to DL
-				if errDL := rmq.SendToDeadletterQueue(msg, err); errDL != nil {
-					go standardMetrics.DeadletterPublishFailureCounter.Add(1)
-					span.RecordError(errDL)
-					span.SetStatus(codes.Error, errDL.Error())
-					log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errDL.Error())
-				}
-
-				go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
-				go standardMetrics.ActiveConsumingEventCounter.Add(-1)
-
-				// continue to the next message
-				span.End()
-				continue
-			}
-
-			// ack message
-			if err := msg.Ack(false); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
-					err.Error(),
-					zap.String("queue", queue),
-					zap.String("event", string(msg.Body)))
-
-				// nack message and return to queue
-				if err := msg.Nack(false, true); err != nil {
-					go standardMetrics.NackFailureCounter.Add(1)
-					span.RecordError(err)
-					span.SetStatus(codes.Error, err.Error())
-					log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
-						err.Error(),
-						zap.String("queue", queue),
-						zap.String("event", string(msg.Body)))
-				}
-
-				// continue to the next message
-				span.End()
-				continue
-			}
+	rmq.ConsumeMagentoProductEventsWithContext(context.Background(), queue, callback)
+}
 
-			go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
-			go standardMetrics.ConsumedEventCounter.Add(1, attribute.Any("event_name", event.Name))
-			go standardMetrics.ActiveConsumingEventCounter.Add(-1)
-			span.End()
-		}
-	}
+// ConsumeMagentoProductEventsWithContext consumes magento product events from a
+// queue until ctx is done. See process for how consuming is stopped.
+func (rmq *RMQClient) ConsumeMagentoProductEventsWithContext(ctx context.Context, queue string, callback base.MessageBrokerMagentoProductCallbackFunc) {
+	consume(ctx, rmq, queue, "RabbitMQ.ConsumeMagentoProductEvents", codec.JSON{}, Callback[base.MagentoProductEvent](callback))
 }
 
 // Publish publishes a message to a queue
 func (rmq *RMQClient) Publish(ctx context.Context, queue string, priority int, event *base.EyewaEvent, callback base.MessageBrokerCallbackFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	rmq.mutex.RLock()
-	channel, exists := rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	// determine if channel exists for queue
-	if !exists && channel == nil {
-		channel, err := rmq.CreateNewChannel(config.PublisherQueueName)
-		if err != nil {
-			_ = callback(ctx, event, err)
-			return
-		}
-
-		errQ := rmq.declareQueue(channel, config.PublisherQueueName, config.PublisherExchangeType, config.ConsumerExchange)
-		if errQ != nil {
-			_ = callback(ctx, event, errQ)
-			return
-		}
-	}
-
-	rmq.mutex.RLock()
-	channel, exists = rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	if exists && channel != nil {
-		msg := &amqp.Publishing{
-			ContentType:  "application/json",
-			DeliveryMode: amqp.Persistent,
-			Priority:     uint8(priority),
-		}
-
-		// set amqp message span attributes.
-		spanOpts := []trace.SpanOption{
-			trace.WithAttributes(
-				semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
-				semconv.MessagingDestinationKindKeyQueue,
-				semconv.MessagingRabbitMQRoutingKeyKey.String(config.PublisherQueueName)),
-			trace.WithSpanKind(trace.SpanKindProducer),
-		}
-
-		// inject context into headers, if none, the
-		// context will use the Background context.
-		carrier := amqptracing.NewHeaderCarrier(msg.Headers)
-
-		log.Debug("Injecting trace context into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
-		otel.GetTextMapPropagator().Inject(ctx, carrier)
-		log.Debug("Trace context injected into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
-
-		// start the span and and receive a new ctx containing the parent
-		ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.Publish", spanOpts...)
-		defer span.End()
-
-		// attempt to marshal event for publishing
-		eventJSON, err := json.Marshal(&event)
-		if err != nil {
-			go standardMetrics.MarshalEventFailureCounter.Add(1)
-			span.RecordError(err)
-			_ = callback(ctx, event, err)
-			return
-		}
-
-		msg.Body = eventJSON
-
-		// attempt to publish event, if publisher exchange type is fanout
-		// push it to fanout instead of queue. Otherwise push it to queue.
-		var exchange, key string
-		if config.PublisherExchangeType != amqp.ExchangeFanout {
-			key = config.PublisherQueueName
-		} else {
-			exchange = fmt.Sprintf("%s.%s", config.PublisherQueueName, amqp.ExchangeFanout)
-		}
-
-		err = channel.Publish(exchange, key, false, false, *msg)
-		if err != nil {
-			go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", event.Name))
-			span.RecordError(err)
-			err = callback(ctx, event, libErrs.ErrorFailedToPublishEvent)
-			if err != nil {
-				span.RecordError(err)
-			}
-			return
-		}
-
-		go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", event.Name))
-
-		// record the callback failing
-		err = callback(ctx, event, nil)
-		if err != nil {
-			span.RecordError(err)
-		}
-	}
+	Publish(ctx, rmq, queue, priority, event, codec.JSON{}, Callback[base.EyewaEvent](callback))
 }
 
 // PublishMagentoEvent publishes a message to a queue
 func (rmq *RMQClient) PublishMagentoProductEvent(ctx context.Context, queue string, priority int, event *base.MagentoProductEvent, callback base.MessageBrokerMagentoProductCallbackFunc, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	rmq.mutex.RLock()
-	channel, exists := rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	// determine if channel exists for queue
-	if !exists && channel == nil {
-		channel, err := rmq.CreateNewChannel(config.PublisherQueueName)
-		if err != nil {
-			_ = callback(ctx, event, err)
-			return
-		}
-
-		errQ := rmq.declareQueue(channel, config.PublisherQueueName, config.PublisherExchangeType, config.ConsumerExchange)
-		if errQ != nil {
-			_ = callback(ctx, event, errQ)
-			return
-		}
-	}
-
-	rmq.mutex.RLock()
-	channel, exists = rmq.channels[queue]
-	rmq.mutex.RUnlock()
-
-	if exists && channel != nil {
-
-		msg := &amqp.Publishing{
-			ContentType:  "application/json",
-			DeliveryMode: amqp.Persistent,
-			Priority:     uint8(priority),
-		}
-
-		// set amqp message span attributes.
-		spanOpts := []trace.SpanOption{
-			trace.WithAttributes(
-				semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
-				semconv.MessagingDestinationKindKeyQueue,
-				semconv.MessagingRabbitMQRoutingKeyKey.String(config.PublisherQueueName)),
-			trace.WithSpanKind(trace.SpanKindProducer),
-		}
-
-		// inject context into headers, if none, the
-		// context will use the Background context.
-		carrier := amqptracing.NewHeaderCarrier(msg.Headers)
-
-		log.Debug("Injecting trace context into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
-		otel.GetTextMapPropagator().Inject(ctx, carrier)
-		log.Debug("Trace context injected into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
-
-		// start the span and and receive a new ctx containing the parent
-		ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.Publish", spanOpts...)
-		defer span.End()
-
-		// attempt to marshal event for publishing
-		eventJSON, err := json.Marshal(&event)
-		if err != nil {
-			go standardMetrics.MarshalEventFailureCounter.Add(1)
-			span.RecordError(err)
-			_ = callback(ctx, event, err)
-			return
-		}
-
-		msg.Body = eventJSON
-
-		// attempt to publish event
-		err = channel.Publish("", config.PublisherQueueName, false, false, *msg)
-		if err != nil {
-			go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", event.Name))
-			span.RecordError(err)
-			err = callback(ctx, event, libErrs.ErrorFailedToPublishEvent)
-			if err != nil {
-				span.RecordError(err)
-			}
-			return
-		}
-
-		go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", event.Name))
-
-		// record the callback failing
-		err = callback(ctx, event, nil)
-		if err != nil {
-			span.RecordError(err)
-		}
-	}
+	publish(ctx, rmq, queue, priority, event, codec.JSON{}, Callback[base.MagentoProductEvent](callback), func(*base.MagentoProductEvent) (routing, error) {
+		return routing{key: queue}, nil
+	})
 }
 
 // PublishEvent publishes a message to a queue base on priority
@@ -674,15 +265,19 @@ func (rmq *RMQClient) PublishEvent(ctx context.Context, queue string, priority i
 
 	// determine if channel exists for queue
 	if !exists && channel == nil {
-		channel, err := rmq.CreateNewChannel(config.PublisherQueueName)
+		channel, err := rmq.CreateNewChannel(queue)
 		if err != nil {
 			return err
 		}
 
-		errQ := rmq.declareQueue(channel, queue, "direct", queue)
-		if errQ != nil {
+		if err := rmq.enableConfirms(queue, channel); err != nil {
 			return err
 		}
+
+		errQ := rmq.declareQueue(channel, queueConfig(queue))
+		if errQ != nil {
+			return errQ
+		}
 	}
 
 	rmq.mutex.RLock()
@@ -705,21 +300,22 @@ func (rmq *RMQClient) PublishEvent(ctx context.Context, queue string, priority i
 			trace.WithSpanKind(trace.SpanKindProducer),
 		}
 
-		// inject context into headers, if none, the
-		// context will use the Background context.
-		carrier := amqptracing.NewHeaderCarrier(msg.Headers)
-
-		log.Debug("Injecting trace context into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
-		otel.GetTextMapPropagator().Inject(ctx, carrier)
-
 		// start the span and and receive a new ctx containing the parent
-		_, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.PublishEvent", spanOpts...)
+		ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.PublishEvent", spanOpts...)
 		defer span.End()
 
+		msg.Headers = withTraceContext(ctx, msg.Headers)
 		msg.Body = *event
 
+		// compress event if the queue compresses events
+		compressed, err := queueConfig(queue).compress(*msg)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
 		// attempt to publish event
-		err := channel.Publish("", queue, false, false, *msg)
+		err = rmq.publish(queue, channel, "", queue, compressed)
 		if err != nil {
 			span.RecordError(err)
 			return err
@@ -732,8 +328,10 @@ func (rmq *RMQClient) PublishEvent(ctx context.Context, queue string, priority i
 // CloseConnection closes a connection as well as any
 // underlying channels associated to it.
 func (rmq *RMQClient) CloseConnection() error {
-	if rmq.connection != nil {
-		return rmq.connection.Close()
+	rmq.setState(StateClosed, nil)
+
+	if conn := rmq.currentConnection(); conn != nil {
+		return conn.Close()
 	}
 
 	rmq.mutex.Lock()
@@ -743,115 +341,108 @@ func (rmq *RMQClient) CloseConnection() error {
 	return nil
 }
 
-func (rmq *RMQClient) declareQueue(channel *amqp.Channel, queue, exchangeType, exchangeName string) error {
+func (rmq *RMQClient) declareQueue(channel *amqp.Channel, q QueueConfig) error {
 	var err error
 
-	if queue == "" {
+	if q.Name == "" {
 		return libErrs.ErrorQueueNotSpecified
 	}
 
 	if channel == nil {
-		if channel, err = rmq.CreateNewChannel(queue); err != nil {
+		if channel, err = rmq.CreateNewChannel(q.Name); err != nil {
 			return err
 		}
 	}
 
-	exchType := exchangeTypes[exchangeType]
-	var exchName string
-	if exchType == exchangeBind {
-		exchName = exchangeName
-	} else if exchType != "" {
-		exchName = fmt.Sprintf("%s.%s", queue, exchType)
+	// queue is set up by the topology - or in verify only mode, nothing is declared
+	if rmq.topology.declares(q.Name) || topologyVerifyOnly() {
+		return nil
 	}
 
+	exchType := q.exchangeType()
+	exchName := q.exchange()
+
 	// declare queue if exhange type is not fanout
 	if exchType != amqp.ExchangeFanout {
-		q, err := channel.QueueDeclare(queue, true, false, false, false, amqp.Table{"x-max-priority": 5})
+		_, err := channel.QueueDeclare(q.Name, true, false, false, false, q.arguments())
 		if err != nil {
 			return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), q.Name, err)
 		}
+
+		// poison messages are deadlettered by RMQ, so the deadletter queue has to exist
+		if q.Type == queueTypeQuorum && q.DeliveryLimit > 0 {
+			if err := rmq.declareQueue(channel, deadletterQueueConfig(q.deadletterQueue())); err != nil {
+				return err
+			}
+		}
 	}
 
 	// declare exchange if there is no binding in exchangeType
 	if exchType != exchangeBind {
 		err := channel.ExchangeDeclare(exchName, exchType, true, false, false, false, nil)
 		if err != nil {
-			return fmt.Errorf(libErrs.ErrorExchangeDeclareFailure.Error(), queue, err)
+			return fmt.Errorf(libErrs.ErrorExchangeDeclareFailure.Error(), q.Name, err)
 		}
 	}
 
 	// bind them together if exhange type is not fanout
 	if exchType != amqp.ExchangeFanout {
-		err := rmq.tryToBindQueueToExchange(channel, queue, exchName)
-		if err != nil {
-			return fmt.Errorf(libErrs.ErrorExchangeBindFailure.Error(), queue, err)
+		for _, key := range q.routingKeys() {
+			err := rmq.tryToBindQueueToExchange(channel, q.Name, key, exchName, q.bindArguments())
+			if err != nil {
+				return fmt.Errorf(libErrs.ErrorExchangeBindFailure.Error(), q.Name, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-func (rmq *RMQClient) createConsumerChannel() error {
-	if config.ConsumerQueueName == "" {
-		return libErrs.ErrorNoConsumerQueueSpecified
-	}
-
-	rmq.mutex.Lock()
-	defer rmq.mutex.Unlock()
-
-	if _, exists := rmq.channels[config.ConsumerQueueName]; !exists {
-		conCh, err := rmq.connection.Channel()
-		if err != nil {
-			return err
-		}
-
-		prefetchCount, _ := strconv.Atoi(config.QueuePrefetchCount)
-		if prefetchCount == 0 {
-			prefetchCount = defaultPrefetchCount
-		}
-
-		if err := conCh.Qos(prefetchCount, 0, true); err != nil {
-			return err
+// createQueueChannels creates channels for all queues declared to consume from or publish to.
+func (rmq *RMQClient) createQueueChannels() error {
+	for _, q := range queueConfigs() {
+		if !q.Consume && !q.Publish {
+			continue
 		}
 
-		if err := rmq.declareQueue(conCh, config.ConsumerQueueName, config.ConsumerExchangeType, config.ConsumerExchange); err != nil {
+		if err := rmq.createQueueChannel(q); err != nil {
 			return err
 		}
-
-		rmq.channels[config.ConsumerQueueName] = conCh
 	}
 
 	return nil
 }
 
-func (rmq *RMQClient) createPublisherChannel() error {
-	if config.PublisherQueueName == "" {
-		return libErrs.ErrorNoPublisherQueueSpecified
-	}
-
+// createQueueChannel creates the channel of a queue - declaring it and putting the
+// channel in confirm mode if it is published to. Consumers have channels of their
+// own - see newConsumerChannel.
+func (rmq *RMQClient) createQueueChannel(q QueueConfig) error {
 	rmq.mutex.Lock()
 	defer rmq.mutex.Unlock()
 
-	if _, exists := rmq.channels[config.PublisherQueueName]; !exists {
-		pubCh, err := rmq.connection.Channel()
+	if rmq.connection == nil {
+		return libErrs.ErrorNoRMQConnection
+	}
+
+	if _, exists := rmq.channels[q.Name]; !exists {
+		ch, err := rmq.connection.Channel()
 		if err != nil {
 			return err
 		}
 
-		prefetchCount, _ := strconv.Atoi(config.QueuePrefetchCount)
-		if prefetchCount == 0 {
-			prefetchCount = defaultPrefetchCount
-		}
-
-		if err := pubCh.Qos(prefetchCount, 0, true); err != nil {
+		if err := rmq.declareQueue(ch, q); err != nil {
 			return err
 		}
 
-		if err := rmq.declareQueue(pubCh, config.PublisherQueueName, config.PublisherExchangeType, config.ConsumerExchange); err != nil {
-			return err
+		if q.Publish && publisherConfirmsEnabled() {
+			c, err := newConfirmer(ch)
+			if err != nil {
+				return err
+			}
+			rmq.confirmers[q.Name] = c
 		}
 
-		rmq.channels[config.PublisherQueueName] = pubCh
+		rmq.channels[q.Name] = ch
 	}
 
 	return nil
@@ -859,8 +450,8 @@ func (rmq *RMQClient) createPublisherChannel() error {
 
 // CreateNewChannel creates a new channel for specified queue
 func (rmq *RMQClient) CreateNewChannel(queue string) (*amqp.Channel, error) {
-	if rmq.connection != nil {
-		channel, err := rmq.connection.Channel()
+	if conn := rmq.currentConnection(); conn != nil {
+		channel, err := conn.Channel()
 		if err != nil {
 			return nil, fmt.Errorf(libErrs.ErrorChannelCreateFailure.Error(), queue, err)
 		}
@@ -899,8 +490,22 @@ func (rmq *RMQClient) QueueInspect(queue string) (map[string]int, error) {
 	return nil, fmt.Errorf(libErrs.ErrorQueueInspectMissingQueueFailure.Error(), queue)
 }
 
-func (rmq *RMQClient) SendToDeadletterQueue(msg amqp.Delivery, eventErr error) error {
-	deadletterQ := fmt.Sprintf("%s-%s", "deadletter", config.ConsumerQueueName)
+// SendToDeadletterQueue publishes a failed delivery to the deadletter queue
+// of the queue it was consumed from, recording eventErr as the reason it failed.
+func (rmq *RMQClient) SendToDeadletterQueue(msg base.Delivery, eventErr error) error {
+	if msg.Header(eventTypeHeader) == eventTypeMagento {
+		return sendToDeadletter[base.MagentoProductEvent](rmq, codec.JSON{}, msg, eventErr)
+	}
+
+	return sendToDeadletter[base.EyewaEvent](rmq, codec.JSON{}, msg, eventErr)
+}
+
+// publishToDeadletter publishes a message to the deadletter queue of a queue.
+func (rmq *RMQClient) publishToDeadletter(queue string, msg amqp.Publishing) error {
+	if queue == "" {
+		queue = config.ConsumerQueueName
+	}
+	deadletterQ := deadletterQueueName(queue)
 
 	rmq.mutex.RLock()
 	channel, exists := rmq.channels[deadletterQ]
@@ -913,69 +518,24 @@ func (rmq *RMQClient) SendToDeadletterQueue(msg amqp.Delivery, eventErr error) e
 			return err
 		}
 
-		errQ := rmq.declareQueue(channel, deadletterQ, amqp.ExchangeDirect, config.ConsumerExchange)
+		errQ := rmq.declareQueue(channel, deadletterQueueConfig(deadletterQ))
 		if errQ != nil {
 			return errQ
 		}
 	}
 
-	var (
-		eventData []byte
-		err       error
-	)
-
-	if v, ok := msg.Headers["x-type-of-event"]; ok && v == "magento" {
-		var mgntEvent *base.MagentoProductEvent
-		err = json.Unmarshal(msg.Body, &mgntEvent)
-		if err != nil {
-			return err
-		}
-
-		mgntEvent.Errors = []base.Error{
-			{
-				ErrorMessage: eventErr.Error(),
-				CreatedAt:    utils.NowRFC3339(),
-			},
-		}
-		eventData, err = json.Marshal(mgntEvent)
-		if err != nil {
-			return err
-		}
-	} else {
-		var event *base.EyewaEvent
-		err = json.Unmarshal(msg.Body, &event)
-		if err != nil {
-			return err
-		}
-
-		event.Errors = []base.Error{
-			{
-				ErrorMessage: eventErr.Error(),
-				CreatedAt:    utils.NowRFC3339(),
-			},
-		}
-
-		eventData, err = json.Marshal(event)
-		if err != nil {
-			return err
-		}
-	}
-
 	rmq.mutex.RLock()
 	channel, exists = rmq.channels[deadletterQ]
 	rmq.mutex.RUnlock()
 
 	// publish event error to DL exchange
 	if exists && channel != nil {
-		err = channel.Publish("", deadletterQ, false, false,
-			amqp.Publishing{
-				ContentType:  "application/json",
-				Body:         eventData,
-				DeliveryMode: amqp.Persistent,
-			})
+		msg.DeliveryMode = amqp.Persistent
+
+		err := channel.Publish("", deadletterQ, false, false, msg)
 		if err != nil {
 			log.Error(libErrs.ErrorFailedToPublishToDeadletter.Error(),
-				zap.String("event", string(eventData)),
+				zap.String("event", string(msg.Body)),
 				zap.String("deadletter_queue", deadletterQ), zap.Error(err))
 
 			return err
@@ -997,14 +557,26 @@ func getNameForChannel(queue string) string {
 	return fmt.Sprintf("%s-%d", config.ServiceName, rand.Uint64())
 }
 
-// ConnectionListener simply listens for a closed connection and
-// simply logs it for visibility. The `Consume` func is responsible
-// for notifying a consumer via a callback on lost connetion.
+// ConnectionListener listens for a closed connection and logs it for
+// visibility. If auto recovery is enabled, a lost connection is recovered,
+// otherwise the `Consume` func is responsible for notifying a consumer
+// via a callback on lost connetion.
 func (rmq *RMQClient) ConnectionListener() {
+	conn := rmq.currentConnection()
+
+	// as configured when the connection was made
+	metrics, autoRecovery := standardMetrics, autoRecoveryEnabled()
+
 	go func() {
-		notify := rmq.connection.NotifyClose(make(chan *amqp.Error))
-		for range notify {
-			log.Warn("RMQ connection has closed!")
+		notify := conn.NotifyClose(make(chan *amqp.Error))
+		for err := range notify {
+			log.Warn("RMQ connection has closed!", zap.Error(err))
+			go metrics.ConnectionLostCounter.Add(1)
+
+			if autoRecovery && rmq.State() != StateClosed {
+				rmq.setState(StateDisconnected, err)
+				rmq.recover()
+			}
 			return
 		}
 	}()
@@ -1012,64 +584,21 @@ func (rmq *RMQClient) ConnectionListener() {
 
 // IsConnectionOpen gets the connection status to RMQ
 func (rmq *RMQClient) IsConnectionOpen() bool {
-	return !rmq.connection.IsClosed()
-}
-
-func (rmq *RMQClient) handleUnmarshalledMagentoEventErr(ctx context.Context, errEvent unmarshalledMagentoEvent) {
-	errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), errEvent.queue, errEvent.err)
-
-	go standardMetrics.UnmarshalEventFailureCounter.Add(1)
-	errEvent.span.RecordError(errEvent.err)
-	_ = errEvent.callback(ctx, nil, errMsg)
-
-	// nack message and remove from queue
-	if err := errEvent.msg.Nack(false, false); err != nil {
-		go standardMetrics.NackFailureCounter.Add(1)
-		errEvent.span.RecordError(err)
-		_ = errEvent.callback(ctx, nil, err)
-	}
-
-	// publish message to DL
-	if err := rmq.SendToDeadletterQueue(errEvent.msg, errMsg); err != nil {
-		go standardMetrics.DeadletterPublishFailureCounter.Add(1)
-		errEvent.span.RecordError(err)
-		_ = errEvent.callback(ctx, nil, err)
-	}
-
-	go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(errEvent.started).Milliseconds()))
-	go standardMetrics.ActiveConsumingEventCounter.Add(-1)
-	errEvent.span.End()
+	return !rmq.currentConnection().IsClosed()
 }
 
-func (rmq *RMQClient) handleUnmarshalledEyewaEventErr(ctx context.Context, errEvent unmarshalledEyewaEvent) {
-	errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), errEvent.queue, errEvent.err)
-
-	go standardMetrics.UnmarshalEventFailureCounter.Add(1)
-	errEvent.span.RecordError(errEvent.err)
-	_ = errEvent.callback(ctx, nil, errMsg)
-
-	// nack message and remove from queue
-	if err := errEvent.msg.Nack(false, false); err != nil {
-		go standardMetrics.NackFailureCounter.Add(1)
-		errEvent.span.RecordError(err)
-		_ = errEvent.callback(ctx, nil, err)
-	}
-
-	// publish message to DL
-	if err := rmq.SendToDeadletterQueue(errEvent.msg, errMsg); err != nil {
-		go standardMetrics.DeadletterPublishFailureCounter.Add(1)
-		errEvent.span.RecordError(err)
-		_ = errEvent.callback(ctx, nil, err)
-	}
+// currentConnection the connection to RMQ - replaced whenever it is recovered,
+// so it is only ever read under lock. nil if not connected.
+func (rmq *RMQClient) currentConnection() *amqp.Connection {
+	rmq.mutex.RLock()
+	defer rmq.mutex.RUnlock()
 
-	go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(errEvent.started).Milliseconds()))
-	go standardMetrics.ActiveConsumingEventCounter.Add(-1)
-	errEvent.span.End()
+	return rmq.connection
 }
 
-func (rmq *RMQClient) tryToBindQueueToExchange(channel *amqp.Channel, queue, exchName string) error {
+func (rmq *RMQClient) tryToBindQueueToExchange(channel *amqp.Channel, queue, key, exchName string, args amqp.Table) error {
 	bind := func() error {
-		return channel.QueueBind(queue, queue, exchName, false, nil)
+		return channel.QueueBind(queue, key, exchName, false, args)
 	}
 
 	bkoff := backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxConnectionRetries)
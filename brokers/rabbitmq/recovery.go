@@ -0,0 +1,164 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// ConnectionState the state of the connection to RMQ.
+type ConnectionState string
+
+const (
+	// StateConnected connection is open and channels are usable.
+	StateConnected ConnectionState = "connected"
+	// StateDisconnected connection was lost unexpectedly.
+	StateDisconnected ConnectionState = "disconnected"
+	// StateRecovering connection is being re-established.
+	StateRecovering ConnectionState = "recovering"
+	// StateClosed connection was closed by the client or could not be recovered.
+	StateClosed ConnectionState = "closed"
+)
+
+// StateHook is invoked on every connection state transition. err is
+// the reason for the transition, if any.
+type StateHook func(state ConnectionState, err error)
+
+// autoRecoveryEnabled reports if a lost connection should be recovered by the client.
+func autoRecoveryEnabled() bool {
+	enabled, _ := strconv.ParseBool(config.AutoRecovery)
+	return enabled
+}
+
+// OnStateChange registers a hook invoked on every connection state transition.
+func (rmq *RMQClient) OnStateChange(hook StateHook) {
+	rmq.stateMutex.Lock()
+	defer rmq.stateMutex.Unlock()
+
+	rmq.stateHook = hook
+}
+
+// State gets the current state of the connection to RMQ.
+func (rmq *RMQClient) State() ConnectionState {
+	rmq.stateMutex.Lock()
+	defer rmq.stateMutex.Unlock()
+
+	return rmq.state
+}
+
+func (rmq *RMQClient) setState(state ConnectionState, err error) {
+	rmq.stateMutex.Lock()
+	rmq.state = state
+	hook := rmq.stateHook
+
+	// wake up anyone waiting on a state change
+	if rmq.stateChanged != nil {
+		close(rmq.stateChanged)
+	}
+	rmq.stateChanged = make(chan struct{})
+	rmq.stateMutex.Unlock()
+
+	log.Debug(fmt.Sprintf("RMQ connection is %s", state), zap.Error(err))
+
+	if hook != nil {
+		hook(state, err)
+	}
+}
+
+// awaitConnection blocks until the connection is usable again. It
+// returns false if the connection was closed for good or ctx is done.
+func (rmq *RMQClient) awaitConnection(ctx context.Context) bool {
+	for {
+		rmq.stateMutex.Lock()
+		if rmq.stateChanged == nil {
+			rmq.stateChanged = make(chan struct{})
+		}
+		state, changed := rmq.state, rmq.stateChanged
+		rmq.stateMutex.Unlock()
+
+		switch state {
+		case StateClosed:
+			return false
+		case StateConnected:
+			conn := rmq.currentConnection()
+
+			// the loss of the connection might not have been noticed yet
+			if conn != nil && !conn.IsClosed() {
+				return true
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// recover re-dials RMQ with an exponential backoff and restores the
+// consumer and publisher channels. Consumers resume on their own once
+// the connection is recovered.
+func (rmq *RMQClient) recover() {
+	rmq.setState(StateRecovering, nil)
+
+	reconnect := func() error {
+		if rmq.State() == StateClosed {
+			return backoff.Permanent(libErrs.ErrorLostConnectionToMessageBroker)
+		}
+
+		return rmq.reconnect()
+	}
+
+	bkoff := backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxConnectionRetries)
+	err := backoff.RetryNotify(reconnect, bkoff, func(err error, duration time.Duration) {
+		if err != nil {
+			log.Error(libErrs.ErrorConnectionRecoveryFailure.Error(), zap.Error(err), zap.Duration("retry_in", duration))
+		}
+	})
+	if err != nil {
+		go standardMetrics.RecoveryFailureCounter.Add(1)
+		rmq.setState(StateClosed, err)
+		return
+	}
+
+	log.Info("RMQ connection recovered.")
+	go standardMetrics.RecoveredConnectionCounter.Add(1)
+	rmq.setState(StateConnected, nil)
+	rmq.ConnectionListener()
+}
+
+// reconnect dials RMQ and re-creates the channels of all queues declared to consume
+// from/publish to - re-declaring their queues, exchanges and bindings. Channels for
+// any other queue are re-created the next time they are used, consumers open their own.
+func (rmq *RMQClient) reconnect() error {
+	conn, err := amqp.Dial(rmq.connStr)
+	if err != nil {
+		return err
+	}
+
+	rmq.mutex.Lock()
+	rmq.connection = conn
+	rmq.channels = make(map[string]*amqp.Channel)
+	rmq.confirmers = make(map[string]*confirmer)
+	rmq.mutex.Unlock()
+
+	if err := rmq.enforceTopology(); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	if err := rmq.createQueueChannels(); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	return nil
+}
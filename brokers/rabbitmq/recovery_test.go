@@ -0,0 +1,102 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoRecoveryConfig(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RABBITMQ_AUTO_RECOVERY", "true")
+
+	cfg, _, err := initConfig()
+	assert.Nil(t, err)
+
+	config = cfg
+	assert.True(t, autoRecoveryEnabled())
+
+	config = Config{}
+	assert.False(t, autoRecoveryEnabled())
+
+	os.Clearenv()
+}
+
+func TestStateHook(t *testing.T) {
+	client := NewRMQClient()
+
+	var states []ConnectionState
+	var errs []error
+	client.OnStateChange(func(state ConnectionState, err error) {
+		states = append(states, state)
+		errs = append(errs, err)
+	})
+
+	lost := errors.New("connection reset")
+	client.setState(StateConnected, nil)
+	client.setState(StateDisconnected, lost)
+	client.setState(StateRecovering, nil)
+
+	assert.Equal(t, StateRecovering, client.State())
+	assert.Equal(t, []ConnectionState{StateConnected, StateDisconnected, StateRecovering}, states)
+	assert.Equal(t, []error{nil, lost, nil}, errs)
+}
+
+func TestAwaitConnection(t *testing.T) {
+	t.Run("connected", func(t *testing.T) {
+		client := NewRMQClient()
+		client.connection = &amqp.Connection{}
+		client.setState(StateConnected, nil)
+
+		assert.True(t, client.awaitConnection(context.Background()))
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		client := NewRMQClient()
+		client.setState(StateClosed, nil)
+
+		assert.False(t, client.awaitConnection(context.Background()))
+	})
+
+	t.Run("recovered", func(t *testing.T) {
+		client := NewRMQClient()
+		client.setState(StateRecovering, nil)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			client.mutex.Lock()
+			client.connection = &amqp.Connection{}
+			client.mutex.Unlock()
+			client.setState(StateConnected, nil)
+		}()
+
+		assert.True(t, client.awaitConnection(context.Background()))
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		client := NewRMQClient()
+		client.setState(StateRecovering, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.False(t, client.awaitConnection(ctx))
+	})
+
+	t.Run("recovery failed", func(t *testing.T) {
+		client := NewRMQClient()
+		client.setState(StateRecovering, nil)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			client.setState(StateClosed, errors.New("gave up"))
+		}()
+
+		assert.False(t, client.awaitConnection(context.Background()))
+	})
+}
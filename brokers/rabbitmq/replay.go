@@ -0,0 +1,448 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/cloudevents"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ReplayFilter selects deadlettered events. Empty fields match any event.
+type ReplayFilter struct {
+	Name      string
+	EventType string
+	StoreCode string
+
+	// matches events with an error message containing it
+	ErrorText string
+}
+
+// ReplayOptions options for replaying deadlettered events.
+type ReplayOptions struct {
+	Filter ReplayFilter
+
+	// max no. of deadlettered messages to look at - 0 for all of them
+	Limit int
+
+	// if true, replayed events keep their Errors. otherwise they are cleared.
+	RetainErrors bool
+
+	// if true, matching events are only listed - nothing is replayed
+	DryRun bool
+
+	// max no. of events replayed per second - 0 for no limit
+	Rate float64
+}
+
+// DeadletterEvent an event sitting in a deadletter queue.
+type DeadletterEvent struct {
+	MessageID string
+	Magento   bool // a base.MagentoProductEvent, otherwise a base.EyewaEvent
+	ID        string
+	Name      string
+	EventType string
+	StoreCode string
+	Errors    []base.Error
+	Body      []byte
+}
+
+// ReplayResult outcome of replaying deadlettered events.
+type ReplayResult struct {
+	Inspected int
+	Matched   []DeadletterEvent
+	Replayed  int
+}
+
+// deadletterEnvelope the fields shared by base.EyewaEvent and base.MagentoProductEvent
+// needed for filtering. Magento events carry their name in `event` instead of `name`.
+type deadletterEnvelope struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	MagentoName string       `json:"event"`
+	EventType   string       `json:"event_type"`
+	StoreCode   string       `json:"store_code"`
+	Errors      []base.Error `json:"errors"`
+}
+
+// deadletterQueueName e.g deadletter-eyewacatalog for eyewacatalog, unless
+// the queue is declared with a deadletter queue of its own.
+func deadletterQueueName(queue string) string {
+	return queueConfig(queue).deadletterQueue()
+}
+
+func parseDeadletterEvent(msg amqp.Delivery) (DeadletterEvent, error) {
+	// deadlettered by RMQ itself e.g poison messages are as compressed as delivered
+	body, err := compression.Decompress(msg.ContentEncoding, msg.Body)
+	if err != nil {
+		return DeadletterEvent{}, err
+	}
+	msg.Body = body
+
+	ce, mode, err := cloudevents.Decode(cloudevents.Message{ContentType: msg.ContentType, Headers: msg.Headers, Body: msg.Body})
+	if mode != "" {
+		if err != nil {
+			return DeadletterEvent{}, err
+		}
+		return parseDeadletterCloudEvent(msg, ce)
+	}
+
+	envelope, err := decodeDeadletterEnvelope(deliveryCodec(msg.ContentType, codec.JSON{}), msg)
+	if err != nil {
+		return DeadletterEvent{}, err
+	}
+
+	event := DeadletterEvent{
+		MessageID: msg.MessageId,
+		ID:        envelope.ID,
+		Name:      envelope.Name,
+		EventType: envelope.EventType,
+		StoreCode: envelope.StoreCode,
+		Errors:    envelope.Errors,
+		Body:      msg.Body,
+	}
+
+	if event.Name == "" && envelope.MagentoName != "" {
+		event.Name = envelope.MagentoName
+		event.Magento = true
+	}
+
+	return event, nil
+}
+
+// decodeDeadletterEnvelope decodes the envelope of an event encoded with c. Codecs
+// encoding by the schema of a type e.g Avro can't decode an envelope, the event
+// is decoded as its type instead - see decodeTypedEvent.
+func decodeDeadletterEnvelope(c codec.Codec, msg amqp.Delivery) (deadletterEnvelope, error) {
+	var envelope deadletterEnvelope
+	err := c.Unmarshal(msg.Body, &envelope)
+	if err == nil {
+		return envelope, nil
+	}
+
+	switch event, _ := decodeTypedEvent(c, msg.Headers, msg.Body); event := event.(type) {
+	case *base.EyewaEvent:
+		return deadletterEnvelope{
+			ID:        event.ID,
+			Name:      event.Name,
+			EventType: event.EventType,
+			StoreCode: event.StoreCode,
+			Errors:    event.Errors,
+		}, nil
+	case *base.MagentoProductEvent:
+		return deadletterEnvelope{
+			ID:          event.ID,
+			MagentoName: event.Name,
+			EventType:   event.EventType,
+			StoreCode:   event.StoreCode,
+			Errors:      event.Errors,
+		}, nil
+	}
+
+	return deadletterEnvelope{}, err
+}
+
+// parseDeadletterCloudEvent parses a deadlettered cloud event. Magento product
+// events are told apart by their subject - the product's entity ID.
+func parseDeadletterCloudEvent(msg amqp.Delivery, ce cloudevents.Event) (DeadletterEvent, error) {
+	event, err := cloudevents.ToEyewaEvent(ce)
+	if err != nil {
+		return DeadletterEvent{}, err
+	}
+
+	return DeadletterEvent{
+		MessageID: msg.MessageId,
+		Magento:   ce.Subject != "",
+		ID:        event.ID,
+		Name:      event.Name,
+		EventType: event.EventType,
+		StoreCode: event.StoreCode,
+		Errors:    event.Errors,
+		Body:      msg.Body,
+	}, nil
+}
+
+// matches reports if the event is selected by the filter.
+func (f ReplayFilter) matches(event DeadletterEvent) bool {
+	if f.Name != "" && f.Name != event.Name {
+		return false
+	}
+
+	if f.EventType != "" && f.EventType != event.EventType {
+		return false
+	}
+
+	if f.StoreCode != "" && f.StoreCode != event.StoreCode {
+		return false
+	}
+
+	if f.ErrorText != "" {
+		for _, e := range event.Errors {
+			if strings.Contains(e.ErrorMessage, f.ErrorText) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// clearErrors removes the errors from an event body encoded with the codec of
+// contentType, leaving all else untouched. Codecs encoding by the schema of a
+// type e.g Avro decode the event as its type - a base.MagentoProductEvent if
+// magento, otherwise a base.EyewaEvent.
+func clearErrors(body []byte, contentType string, magento bool) ([]byte, error) {
+	c := deliveryCodec(contentType, codec.JSON{})
+	if _, isJSON := c.(codec.JSON); !isJSON {
+		var fields map[string]interface{}
+		if err := c.Unmarshal(body, &fields); err != nil {
+			return clearEventErrors(c, body, magento)
+		}
+
+		delete(fields, "errors")
+		return c.Marshal(fields)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "errors")
+	return json.Marshal(fields)
+}
+
+// clearEventErrors removes the errors from an event body encoded with c, decoding
+// it as a base.MagentoProductEvent if magento, otherwise a base.EyewaEvent.
+func clearEventErrors(c codec.Codec, body []byte, magento bool) ([]byte, error) {
+	if magento {
+		var event base.MagentoProductEvent
+		if err := c.Unmarshal(body, &event); err != nil {
+			return nil, err
+		}
+
+		event.Errors = nil
+		return c.Marshal(&event)
+	}
+
+	var event base.EyewaEvent
+	if err := c.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	event.Errors = nil
+	return c.Marshal(&event)
+}
+
+// isBinaryCloudEvent if a message carries a cloud event in binary mode - its
+// errors carried by a header instead of the body.
+func isBinaryCloudEvent(msg amqp.Delivery) bool {
+	_, mode, _ := cloudevents.Decode(cloudevents.Message{ContentType: msg.ContentType, Headers: msg.Headers, Body: msg.Body})
+	return mode == cloudevents.Binary
+}
+
+// clearErrorsHeader removes the header carrying the errors of a cloud event in
+// binary mode, leaving all others untouched.
+func clearErrorsHeader(headers amqp.Table) amqp.Table {
+	cleared := make(amqp.Table, len(headers))
+	for k, v := range headers {
+		cleared[k] = v
+	}
+
+	delete(cleared, cloudevents.HeaderPrefix+cloudevents.ExtensionErrors)
+	delete(cleared, cloudevents.HeaderPrefixUnderscore+cloudevents.ExtensionErrors)
+
+	return cleared
+}
+
+// clearRetryHeaders removes the headers counting the retries of a deadlettered
+// message, so it is retried as often as any other once replayed. The errors of
+// those retries are recorded on the deadlettered event itself.
+func clearRetryHeaders(headers amqp.Table) amqp.Table {
+	cleared := make(amqp.Table, len(headers))
+	for k, v := range headers {
+		cleared[k] = v
+	}
+
+	delete(cleared, retryCountHeader)
+	delete(cleared, retryErrorsHeader)
+
+	return cleared
+}
+
+// ListDeadletterEvents lists the events in the deadletter queue of a queue
+// matching the filter. Events are left in the deadletter queue.
+func (rmq *RMQClient) ListDeadletterEvents(ctx context.Context, queue string, filter ReplayFilter, limit int) ([]DeadletterEvent, error) {
+	result, err := rmq.ReplayDeadletterEvents(ctx, queue, ReplayOptions{
+		Filter: filter,
+		Limit:  limit,
+		DryRun: true,
+	})
+
+	return result.Matched, err
+}
+
+// ReplayDeadletterEvents republishes the events in the deadletter queue of a
+// queue matching the filter back to the queue. Events that don't match, or
+// fail to be republished, are left in the deadletter queue.
+func (rmq *RMQClient) ReplayDeadletterEvents(ctx context.Context, queue string, opts ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+
+	if queue == "" {
+		return result, libErrs.ErrorQueueNotSpecified
+	}
+
+	conn := rmq.currentConnection()
+	if conn == nil {
+		return result, libErrs.ErrorNoRMQConnection
+	}
+
+	deadletterQ := deadletterQueueName(queue)
+
+	// a dedicated channel, so all messages looked at can be held unacked
+	// until done. otherwise a requeued message would be got again right away.
+	channel, err := conn.Channel()
+	if err != nil {
+		return result, fmt.Errorf(libErrs.ErrorChannelCreateFailure.Error(), deadletterQ, err)
+	}
+	defer channel.Close()
+
+	q, err := channel.QueueInspect(deadletterQ)
+	if err != nil {
+		return result, fmt.Errorf(libErrs.ErrorQueueInspectFailure.Error(), deadletterQ, err)
+	}
+
+	total := q.Messages
+	if opts.Limit > 0 && opts.Limit < total {
+		total = opts.Limit
+	}
+
+	var replayer *confirmer
+	if !opts.DryRun {
+		if replayer, err = newConfirmer(channel); err != nil {
+			return result, err
+		}
+	}
+
+	var throttle <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	// return whatever was not replayed to the deadletter queue. all
+	// messages after the last held one were replayed (acked).
+	var lastHeld uint64
+	defer func() {
+		if lastHeld > 0 {
+			if err := channel.Nack(lastHeld, true, true); err != nil {
+				log.Error(fmt.Sprintf("Failed to return messages to %s", deadletterQ), zap.Error(err))
+			}
+		}
+	}()
+
+	for result.Inspected < total {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		msg, ok, err := channel.Get(deadletterQ, false)
+		if err != nil {
+			return result, err
+		}
+		if !ok {
+			break
+		}
+
+		heldBefore := lastHeld
+		lastHeld = msg.DeliveryTag
+		result.Inspected++
+
+		event, err := parseDeadletterEvent(msg)
+		if err != nil {
+			log.Warn(fmt.Sprintf("Skipping unreadable message in %s", deadletterQ),
+				zap.String("message_id", msg.MessageId), zap.Error(err))
+			continue
+		}
+
+		if !opts.Filter.matches(event) {
+			continue
+		}
+
+		result.Matched = append(result.Matched, event)
+		if opts.DryRun {
+			continue
+		}
+
+		if throttle != nil {
+			select {
+			case <-throttle:
+			case <-ctx.Done():
+				return result, ctx.Err()
+			}
+		}
+
+		if err := replay(replayer, queue, msg, event.Magento, opts.RetainErrors); err != nil {
+			log.Error(libErrs.ErrorFailedToReplayEvent.Error(),
+				zap.String("queue", queue),
+				zap.String("event", event.ID),
+				zap.Error(err))
+			continue
+		}
+
+		if err := msg.Ack(false); err != nil {
+			return result, err
+		}
+		lastHeld = heldBefore
+
+		result.Replayed++
+		go standardMetrics.ReplayedEventCounter.Add(1, attribute.Any("event_name", event.Name))
+	}
+
+	return result, nil
+}
+
+// replay republishes a deadlettered message to the queue, waiting for the broker
+// to confirm it. magento if the message carries a base.MagentoProductEvent.
+func replay(replayer *confirmer, queue string, msg amqp.Delivery, magento, retainErrors bool) error {
+	body, headers, encoding := msg.Body, clearRetryHeaders(msg.Headers), msg.ContentEncoding
+	if !retainErrors {
+		var err error
+		if isBinaryCloudEvent(msg) {
+			headers = clearErrorsHeader(headers)
+		} else {
+			// errors are cleared from the decompressed body, replayed as is
+			if body, err = compression.Decompress(encoding, body); err != nil {
+				return err
+			}
+			encoding = ""
+
+			if body, err = clearErrors(body, msg.ContentType, magento); err != nil {
+				return err
+			}
+		}
+	}
+
+	return replayer.publish("", queue, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: encoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        msg.Priority,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Body:            body,
+	})
+}
@@ -0,0 +1,165 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeadletterEvent(t *testing.T) {
+	eyewa, err := parseDeadletterEvent(amqp.Delivery{
+		MessageId: "m1",
+		Body:      []byte(`{"id":"1","name":"product.created","event_type":"Product","store_code":"ae-en","errors":[{"error_message":"db down"}]}`),
+	})
+	assert.NoError(t, err)
+	assert.False(t, eyewa.Magento)
+	assert.Equal(t, "m1", eyewa.MessageID)
+	assert.Equal(t, "product.created", eyewa.Name)
+	assert.Equal(t, "ae-en", eyewa.StoreCode)
+	assert.Equal(t, "db down", eyewa.Errors[0].ErrorMessage)
+
+	magento, err := parseDeadletterEvent(amqp.Delivery{
+		Body: []byte(`{"id":"2","event":"catalog_product_save_after","entity_id":10,"store_code":"sa-ar"}`),
+	})
+	assert.NoError(t, err)
+	assert.True(t, magento.Magento)
+	assert.Equal(t, "catalog_product_save_after", magento.Name)
+
+	_, err = parseDeadletterEvent(amqp.Delivery{Body: []byte(`not json`)})
+	assert.Error(t, err)
+
+	msgpack, err := codec.MsgPack{}.Marshal(&base.MagentoProductEvent{ID: "4", Name: "catalog_product_save_after", StoreCode: "sa-ar"})
+	assert.NoError(t, err)
+
+	magento, err = parseDeadletterEvent(amqp.Delivery{ContentType: "application/msgpack", Body: msgpack})
+	assert.NoError(t, err)
+	assert.True(t, magento.Magento)
+	assert.Equal(t, "sa-ar", magento.StoreCode)
+
+	avro, err := codec.Avro{}.Marshal(&base.MagentoProductEvent{ID: "6", Name: "catalog_product_save_after", EntityID: 10, StoreCode: "ae-en", Errors: []base.Error{{ErrorMessage: "db down"}}})
+	assert.NoError(t, err)
+
+	magento, err = parseDeadletterEvent(amqp.Delivery{ContentType: "avro/binary", Headers: amqp.Table{"x-type-of-event": "magento"}, Body: avro})
+	assert.NoError(t, err)
+	assert.True(t, magento.Magento)
+	assert.Equal(t, "catalog_product_save_after", magento.Name)
+	assert.Equal(t, "db down", magento.Errors[0].ErrorMessage)
+
+	avro, err = codec.Avro{}.Marshal(&base.EyewaEvent{ID: "7", Name: "product.created", StoreCode: "ae-en", Payload: []byte(`{}`)})
+	assert.NoError(t, err)
+
+	eyewa, err = parseDeadletterEvent(amqp.Delivery{ContentType: "avro/binary", Body: avro})
+	assert.NoError(t, err)
+	assert.False(t, eyewa.Magento)
+	assert.Equal(t, "product.created", eyewa.Name)
+	assert.Equal(t, "ae-en", eyewa.StoreCode)
+
+	compressed, err := compression.Compress(compression.Gzip, []byte(`{"id":"5","name":"product.deleted"}`))
+	assert.NoError(t, err)
+
+	eyewa, err = parseDeadletterEvent(amqp.Delivery{ContentEncoding: compression.Gzip, Body: compressed})
+	assert.NoError(t, err)
+	assert.Equal(t, "product.deleted", eyewa.Name)
+	assert.JSONEq(t, `{"id":"5","name":"product.deleted"}`, string(eyewa.Body))
+
+	cloudEvent, err := parseDeadletterEvent(amqp.Delivery{
+		ContentType: "application/cloudevents+json",
+		Body:        []byte(`{"specversion":"1.0","id":"3","source":"magento","type":"product.updated","subject":"10","storecode":"sa-ar","errors":"[{\"error_message\":\"db down\"}]"}`),
+	})
+	assert.NoError(t, err)
+	assert.True(t, cloudEvent.Magento)
+	assert.Equal(t, "product.updated", cloudEvent.Name)
+	assert.Equal(t, "sa-ar", cloudEvent.StoreCode)
+	assert.Equal(t, "db down", cloudEvent.Errors[0].ErrorMessage)
+}
+
+func TestReplayFilterMatches(t *testing.T) {
+	event := DeadletterEvent{
+		Name:      "product.created",
+		EventType: "Product",
+		StoreCode: "ae-en",
+		Errors:    []base.Error{{ErrorMessage: "connection timeout"}, {ErrorMessage: "db down"}},
+	}
+
+	tests := []struct {
+		name    string
+		filter  ReplayFilter
+		matches bool
+	}{
+		{"empty filter", ReplayFilter{}, true},
+		{"name", ReplayFilter{Name: "product.created"}, true},
+		{"other name", ReplayFilter{Name: "product.deleted"}, false},
+		{"event type", ReplayFilter{EventType: "Product"}, true},
+		{"other store", ReplayFilter{StoreCode: "sa-ar"}, false},
+		{"error text", ReplayFilter{ErrorText: "timeout"}, true},
+		{"other error text", ReplayFilter{ErrorText: "not found"}, false},
+		{"all", ReplayFilter{Name: "product.created", StoreCode: "ae-en", ErrorText: "db"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, tt.filter.matches(event))
+		})
+	}
+}
+
+func TestClearErrors(t *testing.T) {
+	body, err := clearErrors([]byte(`{"id":"1","name":"product.created","payload":{"sku":"abc"},"errors":[{"error_message":"db down"}]}`), "application/json", false)
+	assert.NoError(t, err)
+
+	var event base.EyewaEvent
+	assert.NoError(t, json.Unmarshal(body, &event))
+	assert.Empty(t, event.Errors)
+	assert.Equal(t, "product.created", event.Name)
+	assert.JSONEq(t, `{"sku":"abc"}`, string(event.Payload))
+
+	// encoded with the codec of the content type
+	c := codec.MsgPack{}
+	body, err = c.Marshal(&base.EyewaEvent{ID: "1", Name: "product.created", Errors: []base.Error{{ErrorMessage: "db down"}}})
+	assert.NoError(t, err)
+
+	body, err = clearErrors(body, c.ContentType(), false)
+	assert.NoError(t, err)
+
+	event = base.EyewaEvent{}
+	assert.NoError(t, c.Unmarshal(body, &event))
+	assert.Empty(t, event.Errors)
+	assert.Equal(t, "product.created", event.Name)
+
+	// decoded as its type by codecs with schemas
+	avro := codec.Avro{}
+	body, err = avro.Marshal(&base.MagentoProductEvent{ID: "2", Name: "catalog_product_save_after", EntityID: 10, Errors: []base.Error{{ErrorMessage: "db down"}}})
+	assert.NoError(t, err)
+
+	body, err = clearErrors(body, avro.ContentType(), true)
+	assert.NoError(t, err)
+
+	magento := base.MagentoProductEvent{}
+	assert.NoError(t, avro.Unmarshal(body, &magento))
+	assert.Empty(t, magento.Errors)
+	assert.Equal(t, 10, magento.EntityID)
+}
+
+func TestClearErrorsHeader(t *testing.T) {
+	headers := amqp.Table{"cloudEvents:id": "1", "cloudEvents:errors": `[{"error_message":"db down"}]`}
+
+	assert.Equal(t, amqp.Table{"cloudEvents:id": "1"}, clearErrorsHeader(headers))
+	assert.Len(t, headers, 2)
+}
+
+func TestReplayDeadletterEventsNotConnected(t *testing.T) {
+	client := NewRMQClient()
+
+	_, err := client.ReplayDeadletterEvents(context.Background(), "", ReplayOptions{})
+	assert.Equal(t, libErrs.ErrorQueueNotSpecified, err)
+
+	_, err = client.ReplayDeadletterEvents(context.Background(), "eyewacatalog", ReplayOptions{})
+	assert.Equal(t, libErrs.ErrorNoRMQConnection, err)
+}
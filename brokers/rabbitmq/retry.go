@@ -0,0 +1,176 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/utils"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// no. of times a message has been retried
+	retryCountHeader = "x-retry-count"
+	// errors of all previous attempts, as a JSON list of base.Error
+	retryErrorsHeader = "x-retry-errors"
+)
+
+var (
+	defaultRetryInitialDelay = time.Second
+	defaultRetryMultiplier   = 2.0
+	defaultRetryMaxDelay     = time.Hour
+)
+
+// maxRetryAttempts no. of times a failed message is retried before
+// being deadlettered. Retrying is disabled if 0.
+func maxRetryAttempts() int {
+	attempts, err := strconv.Atoi(config.RetryMaxAttempts)
+	if err != nil || attempts < 0 {
+		return 0
+	}
+
+	return attempts
+}
+
+// retryDelay how long to wait before the given attempt (1-based). Delays grow
+// exponentially from the initial delay, capped at the max delay.
+func retryDelay(attempt int) time.Duration {
+	initial, err := time.ParseDuration(config.RetryInitialDelay)
+	if err != nil || initial <= 0 {
+		initial = defaultRetryInitialDelay
+	}
+
+	multiplier, err := strconv.ParseFloat(config.RetryMultiplier, 64)
+	if err != nil || multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	maxDelay, err := time.ParseDuration(config.RetryMaxDelay)
+	if err != nil || maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxDelay) {
+		return maxDelay
+	}
+
+	// whole milliseconds as that's what a queue ttl is in
+	return time.Duration(delay).Truncate(time.Millisecond)
+}
+
+// retryQueueName e.g retry-eyewacatalog-4000 holds messages for 4s before
+// they are dead lettered back to eyewacatalog.
+func retryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s-%s-%d", "retry", queue, delay.Milliseconds())
+}
+
+// retryCount no. of times a delivery has been retried so far.
+func retryCount(msg base.Delivery) int {
+	count, _ := toInt(msg.Headers[retryCountHeader])
+	return count
+}
+
+// retryErrors the errors of all previous attempts of a delivery.
+func retryErrors(msg base.Delivery) []base.Error {
+	var errs []base.Error
+
+	if history, ok := msg.Headers[retryErrorsHeader].(string); ok {
+		if err := json.Unmarshal([]byte(history), &errs); err != nil {
+			log.Warn("Failed to read retry errors.", zap.Error(err))
+		}
+	}
+
+	return errs
+}
+
+// retryOrDeadletter schedules a failed delivery for another attempt. Once
+// out of attempts it is sent to the deadletter queue by deadletter instead.
+// Deliveries from streams are never retried - a stream keeps its messages, so
+// a retry would be a duplicate for every consumer of the stream.
+func (rmq *RMQClient) retryOrDeadletter(msg base.Delivery, eventErr error, deadletter func(base.Delivery, error) error) error {
+	attempt := retryCount(msg) + 1
+	if attempt > maxRetryAttempts() || queueConfig(msg.Queue).Type == queueTypeStream {
+		return deadletter(msg, eventErr)
+	}
+
+	if err := rmq.sendToRetryQueue(msg, eventErr, attempt); err != nil {
+		go standardMetrics.RetryPublishFailureCounter.Add(1)
+		log.Error(libErrs.ErrorFailedToPublishToRetry.Error(),
+			zap.String("queue", msg.Queue),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+
+		// better deadlettered than lost
+		return deadletter(msg, eventErr)
+	}
+
+	go standardMetrics.RetriedEventCounter.Add(1, attribute.Int("attempt", attempt))
+	return nil
+}
+
+// sendToRetryQueue publishes a failed delivery to the retry queue for the attempt.
+// The retry queue holds it for the attempt's delay and then dead letters it
+// back to the queue it was consumed from.
+func (rmq *RMQClient) sendToRetryQueue(msg base.Delivery, eventErr error, attempt int) error {
+	delay := retryDelay(attempt)
+	retryQ := retryQueueName(msg.Queue, delay)
+
+	rmq.mutex.RLock()
+	channel, exists := rmq.channels[retryQ]
+	rmq.mutex.RUnlock()
+
+	// channel doesn't exist yet for retry queue - create one
+	if !exists || channel == nil {
+		var err error
+		if channel, err = rmq.CreateNewChannel(retryQ); err != nil {
+			return err
+		}
+
+		// in verify only mode, nothing is declared
+		if !topologyVerifyOnly() {
+			_, err = channel.QueueDeclare(retryQ, true, false, false, false, amqp.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": msg.Queue,
+			})
+			if err != nil {
+				return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), retryQ, err)
+			}
+		}
+	}
+
+	history, err := json.Marshal(append(retryErrors(msg), base.Error{
+		ErrorMessage: eventErr.Error(),
+		CreatedAt:    utils.NowRFC3339(),
+	}))
+	if err != nil {
+		return err
+	}
+
+	headers := make(amqp.Table, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[retryCountHeader] = int32(attempt)
+	headers[retryErrorsHeader] = string(history)
+
+	return channel.Publish("", retryQ, false, false, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    amqp.Persistent,
+		Priority:        msg.Priority,
+		MessageId:       msg.MessageID,
+		Timestamp:       msg.Timestamp,
+		Body:            msg.Body,
+	})
+}
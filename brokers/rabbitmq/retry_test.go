@@ -0,0 +1,73 @@
+package rabbitmq
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryConfig(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("RABBITMQ_RETRY_MAX_ATTEMPTS", "3")
+	os.Setenv("RABBITMQ_RETRY_INITIAL_DELAY", "500ms")
+
+	cfg, _, err := initConfig()
+	assert.Nil(t, err)
+
+	config = cfg
+	assert.Equal(t, 3, maxRetryAttempts())
+	assert.Equal(t, 500*time.Millisecond, retryDelay(1))
+
+	config = Config{}
+	assert.Equal(t, 0, maxRetryAttempts())
+	assert.Equal(t, defaultRetryInitialDelay, retryDelay(1))
+
+	os.Clearenv()
+}
+
+func TestRetryDelay(t *testing.T) {
+	config = Config{
+		RetryInitialDelay: "1s",
+		RetryMultiplier:   "3",
+		RetryMaxDelay:     "1m",
+	}
+	defer func() { config = Config{} }()
+
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 3 * time.Second},
+		{3, 9 * time.Second},
+		{4, 27 * time.Second},
+		{5, time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, retryDelay(tt.attempt))
+	}
+}
+
+func TestRetryQueueName(t *testing.T) {
+	assert.Equal(t, "retry-eyewacatalog-4000", retryQueueName("eyewacatalog", 4*time.Second))
+	assert.Equal(t, "retry-eyewacatalog-250", retryQueueName("eyewacatalog", 250*time.Millisecond))
+}
+
+func TestRetryHeaders(t *testing.T) {
+	delivery := base.Delivery{Headers: map[string]interface{}{}}
+	assert.Equal(t, 0, retryCount(delivery))
+	assert.Empty(t, retryErrors(delivery))
+
+	delivery.Headers[retryCountHeader] = int32(2)
+	delivery.Headers[retryErrorsHeader] = `[{"error_message":"db down","created_at":"2022-01-01T00:00:00Z"},{"error_message":"db still down","created_at":"2022-01-01T00:00:01Z"}]`
+
+	assert.Equal(t, 2, retryCount(delivery))
+
+	errs := retryErrors(delivery)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "db still down", errs[1].ErrorMessage)
+}
@@ -0,0 +1,136 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/streadway/amqp"
+)
+
+const (
+	queueTypeClassic = "classic"
+	queueTypeQuorum  = "quorum"
+	queueTypeStream  = "stream"
+
+	streamOffsetHeader = "x-stream-offset"
+)
+
+// StreamOffset where in a stream to start consuming from.
+type StreamOffset struct {
+	value interface{}
+}
+
+var (
+	// OffsetFirst consume from the first message in the stream.
+	OffsetFirst = StreamOffset{"first"}
+	// OffsetLast consume from the last chunk of messages in the stream.
+	OffsetLast = StreamOffset{"last"}
+	// OffsetNext consume only messages published from now on.
+	OffsetNext = StreamOffset{"next"}
+)
+
+// OffsetAt consume from the message at offset.
+func OffsetAt(offset int64) StreamOffset {
+	return StreamOffset{offset}
+}
+
+// OffsetFrom consume messages published from t on.
+func OffsetFrom(t time.Time) StreamOffset {
+	return StreamOffset{t}
+}
+
+// ParseStreamOffset parses an offset - first, last, next, an offset e.g 1500,
+// a timestamp e.g 2021-10-01T00:00:00Z or a duration ago e.g 24h.
+func ParseStreamOffset(offset string) (StreamOffset, error) {
+	switch offset {
+	case "first":
+		return OffsetFirst, nil
+	case "last":
+		return OffsetLast, nil
+	case "next", "":
+		return OffsetNext, nil
+	}
+
+	if n, err := strconv.ParseInt(offset, 10, 64); err == nil && n >= 0 {
+		return OffsetAt(n), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, offset); err == nil {
+		return OffsetFrom(t), nil
+	}
+
+	if d, err := time.ParseDuration(offset); err == nil && d > 0 {
+		return OffsetFrom(time.Now().Add(-d)), nil
+	}
+
+	return StreamOffset{}, fmt.Errorf(libErrs.ErrorInvalidStreamOffset.Error(), offset)
+}
+
+// String e.g first or 1500.
+func (o StreamOffset) String() string {
+	if t, ok := o.value.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(o.value)
+}
+
+// ConsumeStream consumes events of any type from a stream until ctx is done -
+// starting at offset, regardless of the stream offset of the queue's config.
+func ConsumeStream[T any](ctx context.Context, rmq *RMQClient, queue string, offset StreamOffset, c codec.Codec, callback Callback[T]) {
+	consume(withStreamOffset(ctx, offset), rmq, queue, "RabbitMQ.ConsumeStream", c, callback)
+}
+
+// ConsumeStream consumes events from a stream until ctx is done - starting at offset
+// e.g to reindex from an hour ago, OffsetFrom(time.Now().Add(-time.Hour)).
+func (rmq *RMQClient) ConsumeStream(ctx context.Context, queue string, offset StreamOffset, callback base.MessageBrokerCallbackFunc) {
+	ConsumeStream(ctx, rmq, queue, offset, codec.JSON{}, Callback[base.EyewaEvent](callback))
+}
+
+type streamOffsetKey struct{}
+
+// withStreamOffset sets the offset to consume from on the ctx of a consumer.
+func withStreamOffset(ctx context.Context, offset StreamOffset) context.Context {
+	return context.WithValue(ctx, streamOffsetKey{}, offset)
+}
+
+// consumeArguments the arguments to consume from a queue with. Streams are
+// consumed from the offset set on ctx, or else the queue's stream offset.
+func (q QueueConfig) consumeArguments(ctx context.Context) (amqp.Table, error) {
+	if q.Type != queueTypeStream {
+		return nil, nil
+	}
+
+	offset, ok := ctx.Value(streamOffsetKey{}).(StreamOffset)
+	if !ok {
+		var err error
+		if offset, err = ParseStreamOffset(q.StreamOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	return amqp.Table{streamOffsetHeader: offset.value}, nil
+}
+
+// streamOffset the offset of a message consumed from a stream.
+func streamOffset(msg amqp.Delivery) (int64, bool) {
+	offset, ok := msg.Headers[streamOffsetHeader].(int64)
+	return offset, ok
+}
+
+// resumeArguments the arguments to resume consuming with after the message
+// at offset - so a recovered stream consumer doesn't start over.
+func resumeArguments(args amqp.Table, offset int64) amqp.Table {
+	resume := make(amqp.Table, len(args))
+	for k, v := range args {
+		resume[k] = v
+	}
+	resume[streamOffsetHeader] = offset + 1
+
+	return resume
+}
@@ -0,0 +1,121 @@
+package rabbitmq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamOffset(t *testing.T) {
+	ts := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		offset   string
+		expected StreamOffset
+		invalid  bool
+	}{
+		{"", OffsetNext, false},
+		{"first", OffsetFirst, false},
+		{"last", OffsetLast, false},
+		{"next", OffsetNext, false},
+		{"1500", OffsetAt(1500), false},
+		{"2021-10-01T00:00:00Z", OffsetFrom(ts), false},
+		{"-5", StreamOffset{}, true},
+		{"yesterday", StreamOffset{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.offset, func(t *testing.T) {
+			offset, err := ParseStreamOffset(tt.offset)
+			if tt.invalid {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, offset)
+		})
+	}
+}
+
+func TestParseStreamOffsetAgo(t *testing.T) {
+	offset, err := ParseStreamOffset("1h")
+	assert.Nil(t, err)
+
+	from, ok := offset.value.(time.Time)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(-time.Hour), from, time.Minute)
+}
+
+func TestQueueArguments(t *testing.T) {
+	tests := []struct {
+		name     string
+		queue    QueueConfig
+		expected amqp.Table
+	}{
+		{"classic", QueueConfig{Name: "catalog"}, amqp.Table{"x-max-priority": defaultMaxPriority}},
+		{"quorum", QueueConfig{Name: "catalog", Type: "quorum"}, amqp.Table{"x-queue-type": "quorum"}},
+		{"quorum with delivery limit", QueueConfig{Name: "catalog", Type: "quorum", DeliveryLimit: 3}, amqp.Table{
+			"x-queue-type":              "quorum",
+			"x-delivery-limit":          int64(3),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": "deadletter-catalog",
+		}},
+		{"stream", QueueConfig{Name: "catalog", Type: "stream", MaxAge: "7D"}, amqp.Table{
+			"x-queue-type": "stream",
+			"x-max-age":    "7D",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.queue.arguments())
+		})
+	}
+}
+
+func TestConsumeArguments(t *testing.T) {
+	ctx := context.Background()
+
+	args, err := QueueConfig{Name: "catalog"}.consumeArguments(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, args)
+
+	stream := QueueConfig{Name: "catalog", Type: "stream", StreamOffset: "first"}
+	args, err = stream.consumeArguments(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{"x-stream-offset": "first"}, args)
+
+	// offset of the consumer wins
+	args, err = stream.consumeArguments(withStreamOffset(ctx, OffsetAt(42)))
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{"x-stream-offset": int64(42)}, args)
+	assert.Nil(t, args.Validate())
+}
+
+func TestResumeArguments(t *testing.T) {
+	args := amqp.Table{"x-stream-offset": "first"}
+
+	offset, ok := streamOffset(amqp.Delivery{Headers: amqp.Table{"x-stream-offset": int64(99)}})
+	assert.True(t, ok)
+
+	assert.Equal(t, amqp.Table{"x-stream-offset": int64(100)}, resumeArguments(args, offset))
+	assert.Equal(t, "first", args["x-stream-offset"])
+
+	_, ok = streamOffset(amqp.Delivery{})
+	assert.False(t, ok)
+}
+
+func TestParseQueuesType(t *testing.T) {
+	_, err := parseQueues(`[{"name": "catalog", "type": "stream", "stream_offset": "first"}]`)
+	assert.Nil(t, err)
+
+	_, err = parseQueues(`[{"name": "catalog", "type": "lazy"}]`)
+	assert.NotNil(t, err)
+
+	_, err = parseQueues(`[{"name": "catalog", "type": "stream", "stream_offset": "whenever"}]`)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,369 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/ory/viper"
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+)
+
+// Topology the exchanges, queues and bindings a service expects on RMQ.
+type Topology struct {
+	Exchanges []ExchangeTopology `mapstructure:"exchanges"`
+	Queues    []QueueTopology    `mapstructure:"queues"`
+	Bindings  []BindingTopology  `mapstructure:"bindings"`
+}
+
+// ExchangeTopology declares an exchange.
+type ExchangeTopology struct {
+	Name       string                 `mapstructure:"name"`
+	Type       string                 `mapstructure:"type"` // direct, fanout, topic or headers
+	Durable    *bool                  `mapstructure:"durable"`
+	AutoDelete bool                   `mapstructure:"auto_delete"`
+	Internal   bool                   `mapstructure:"internal"`
+	Arguments  map[string]interface{} `mapstructure:"arguments"`
+}
+
+// QueueTopology declares a queue.
+type QueueTopology struct {
+	Name       string `mapstructure:"name"`
+	Durable    *bool  `mapstructure:"durable"`
+	AutoDelete bool   `mapstructure:"auto_delete"`
+	Exclusive  bool   `mapstructure:"exclusive"`
+
+	// classic, quorum or stream
+	Type string `mapstructure:"type"`
+
+	// e.g 30s - how long messages live in the queue
+	MessageTTL     string `mapstructure:"message_ttl"`
+	MaxLength      int    `mapstructure:"max_length"`
+	MaxLengthBytes int    `mapstructure:"max_length_bytes"`
+	MaxPriority    int    `mapstructure:"max_priority"`
+	Lazy           bool   `mapstructure:"lazy"`
+
+	// any further x-arguments
+	Arguments map[string]interface{} `mapstructure:"arguments"`
+}
+
+// BindingTopology binds a queue to an exchange by routing key or - for
+// headers exchanges - by headers.
+type BindingTopology struct {
+	Queue      string                 `mapstructure:"queue"`
+	Exchange   string                 `mapstructure:"exchange"`
+	RoutingKey string                 `mapstructure:"routing_key"`
+	Headers    map[string]interface{} `mapstructure:"headers"`
+
+	// all or any of Headers have to match. defaults to all
+	Match string `mapstructure:"match"`
+}
+
+// TopologyDrift a difference between the topology and what is declared on RMQ.
+type TopologyDrift struct {
+	Kind   string // exchange or queue
+	Name   string
+	Reason string
+}
+
+// topologyVerifyOnly reports if the topology should only be verified - not applied.
+func topologyVerifyOnly() bool {
+	verify, _ := strconv.ParseBool(config.TopologyVerify)
+	return verify
+}
+
+// LoadTopology loads a topology from a YAML or JSON file.
+func LoadTopology(path string) (Topology, error) {
+	var topology Topology
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return topology, fmt.Errorf(libErrs.ErrorTopologyLoadFailure.Error(), path, err)
+	}
+
+	if err := v.Unmarshal(&topology); err != nil {
+		return topology, fmt.Errorf(libErrs.ErrorTopologyLoadFailure.Error(), path, err)
+	}
+
+	return topology, nil
+}
+
+// declares reports if the topology declares a queue.
+func (t *Topology) declares(queue string) bool {
+	if t == nil {
+		return false
+	}
+
+	for _, q := range t.Queues {
+		if q.Name == queue {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e ExchangeTopology) durable() bool {
+	return e.Durable == nil || *e.Durable
+}
+
+func (q QueueTopology) durable() bool {
+	return q.Durable == nil || *q.Durable
+}
+
+// arguments the x-arguments to declare the queue with.
+func (q QueueTopology) arguments() (amqp.Table, error) {
+	args := tableOf(q.Arguments)
+
+	if q.Type != "" {
+		args["x-queue-type"] = q.Type
+	}
+
+	if q.MessageTTL != "" {
+		ttl, err := time.ParseDuration(q.MessageTTL)
+		if err != nil {
+			return nil, err
+		}
+		args["x-message-ttl"] = ttl.Milliseconds()
+	}
+
+	if q.MaxLength > 0 {
+		args["x-max-length"] = int64(q.MaxLength)
+	}
+
+	if q.MaxLengthBytes > 0 {
+		args["x-max-length-bytes"] = int64(q.MaxLengthBytes)
+	}
+
+	if q.MaxPriority > 0 {
+		args["x-max-priority"] = int64(q.MaxPriority)
+	}
+
+	if q.Lazy {
+		args["x-queue-mode"] = "lazy"
+	}
+
+	return args, nil
+}
+
+// arguments the arguments to bind the queue with.
+func (b BindingTopology) arguments() amqp.Table {
+	args := tableOf(b.Headers)
+	if len(b.Headers) > 0 {
+		match := b.Match
+		if match == "" {
+			match = "all"
+		}
+		args["x-match"] = match
+	}
+
+	return args
+}
+
+// tableOf converts arguments decoded from a topology file to an amqp.Table.
+// Whole numbers decoded from JSON as floats are converted to integers.
+func tableOf(args map[string]interface{}) amqp.Table {
+	table := make(amqp.Table, len(args))
+	for k, v := range args {
+		table[k] = tableValue(v)
+	}
+
+	return table
+}
+
+func tableValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case float64:
+		if value == float64(int64(value)) {
+			return int64(value)
+		}
+	case int:
+		return int64(value)
+	case map[string]interface{}:
+		return tableOf(value)
+	case map[interface{}]interface{}:
+		table := make(amqp.Table, len(value))
+		for k, v := range value {
+			table[fmt.Sprint(k)] = tableValue(v)
+		}
+		return table
+	case []interface{}:
+		values := make([]interface{}, len(value))
+		for i, v := range value {
+			values[i] = tableValue(v)
+		}
+		return values
+	}
+
+	return v
+}
+
+// ApplyTopology declares the exchanges, queues and bindings of the topology.
+// Declaring is idempotent - anything already declared as is, is left untouched.
+func (rmq *RMQClient) ApplyTopology(t Topology) error {
+	conn := rmq.currentConnection()
+	if conn == nil {
+		return libErrs.ErrorNoRMQConnection
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf(libErrs.ErrorChannelCreateFailure.Error(), "topology", err)
+	}
+	defer channel.Close()
+
+	for _, e := range t.Exchanges {
+		err := channel.ExchangeDeclare(e.Name, e.Type, e.durable(), e.AutoDelete, e.Internal, false, tableOf(e.Arguments))
+		if err != nil {
+			return fmt.Errorf(libErrs.ErrorExchangeDeclareFailure.Error(), e.Name, err)
+		}
+	}
+
+	for _, q := range t.Queues {
+		args, err := q.arguments()
+		if err != nil {
+			return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), q.Name, err)
+		}
+
+		if _, err := channel.QueueDeclare(q.Name, q.durable(), q.AutoDelete, q.Exclusive, false, args); err != nil {
+			return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), q.Name, err)
+		}
+	}
+
+	for _, b := range t.Bindings {
+		if err := channel.QueueBind(b.Queue, b.RoutingKey, b.Exchange, false, b.arguments()); err != nil {
+			return fmt.Errorf(libErrs.ErrorExchangeBindFailure.Error(), b.Queue, err)
+		}
+	}
+
+	return nil
+}
+
+// VerifyTopology reports the exchanges and queues of the topology missing on RMQ or
+// declared with other settings. Each is checked with a passive declare and, once
+// found, redeclared as the topology declares it - which changes nothing if declared
+// alike, and is refused by RMQ with PRECONDITION_FAILED if not. Nothing missing is
+// ever declared. Bindings can't be inspected over AMQP, so they aren't verified.
+func (rmq *RMQClient) VerifyTopology(t Topology) ([]TopologyDrift, error) {
+	conn := rmq.currentConnection()
+	if conn == nil {
+		return nil, libErrs.ErrorNoRMQConnection
+	}
+
+	var drift []TopologyDrift
+
+	// declarations are made in turn until one is refused - reported as drift. RMQ
+	// closes the channel on a refused declare, so each gets a channel of its own
+	check := func(kind, name string, declarations ...func(*amqp.Channel) error) error {
+		for _, declare := range declarations {
+			channel, err := conn.Channel()
+			if err != nil {
+				return fmt.Errorf(libErrs.ErrorChannelCreateFailure.Error(), "topology", err)
+			}
+
+			err = declare(channel)
+			_ = channel.Close()
+
+			if err != nil {
+				drift = append(drift, TopologyDrift{Kind: kind, Name: name, Reason: reason(err)})
+				return nil
+			}
+		}
+
+		return nil
+	}
+
+	for _, e := range t.Exchanges {
+		e := e
+		err := check("exchange", e.Name,
+			func(ch *amqp.Channel) error {
+				return ch.ExchangeDeclarePassive(e.Name, e.Type, e.durable(), e.AutoDelete, e.Internal, false, nil)
+			},
+			func(ch *amqp.Channel) error {
+				return ch.ExchangeDeclare(e.Name, e.Type, e.durable(), e.AutoDelete, e.Internal, false, tableOf(e.Arguments))
+			})
+		if err != nil {
+			return drift, err
+		}
+	}
+
+	for _, q := range t.Queues {
+		q := q
+		args, err := q.arguments()
+		if err != nil {
+			return drift, fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), q.Name, err)
+		}
+
+		err = check("queue", q.Name,
+			func(ch *amqp.Channel) error {
+				_, err := ch.QueueDeclarePassive(q.Name, q.durable(), q.AutoDelete, q.Exclusive, false, nil)
+				return err
+			},
+			func(ch *amqp.Channel) error {
+				_, err := ch.QueueDeclare(q.Name, q.durable(), q.AutoDelete, q.Exclusive, false, args)
+				return err
+			})
+		if err != nil {
+			return drift, err
+		}
+	}
+
+	return drift, nil
+}
+
+// reason the reason RMQ gave for refusing a declare.
+func reason(err error) string {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr.Reason
+	}
+
+	return err.Error()
+}
+
+// setupTopology loads the topology file - if any - and applies it or, in verify
+// only mode, logs how RMQ has drifted from it. Queues the topology declares are
+// left to it and not declared by the client.
+func (rmq *RMQClient) setupTopology() error {
+	if config.TopologyFile == "" {
+		return nil
+	}
+
+	topology, err := LoadTopology(config.TopologyFile)
+	if err != nil {
+		return err
+	}
+	rmq.topology = &topology
+
+	return rmq.enforceTopology()
+}
+
+// enforceTopology applies the loaded topology or logs how RMQ has drifted from it.
+func (rmq *RMQClient) enforceTopology() error {
+	if rmq.topology == nil {
+		return nil
+	}
+
+	if !topologyVerifyOnly() {
+		return rmq.ApplyTopology(*rmq.topology)
+	}
+
+	drift, err := rmq.VerifyTopology(*rmq.topology)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range drift {
+		log.Warn(libErrs.ErrorTopologyDrift.Error(),
+			zap.String("kind", d.Kind),
+			zap.String("name", d.Name),
+			zap.String("reason", d.Reason))
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package rabbitmq
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+var topologyYAML = `
+exchanges:
+  - name: catalog
+    type: headers
+queues:
+  - name: catalog-ae
+    type: quorum
+    message_ttl: 30s
+    max_length: 1000
+  - name: catalog-archive
+    durable: false
+    lazy: true
+    arguments:
+      x-overflow: reject-publish
+bindings:
+  - queue: catalog-ae
+    exchange: catalog
+    headers:
+      store_code: ae-en
+    match: any
+`
+
+var topologyJSON = `{
+	"queues": [{"name": "orders", "max_priority": 10, "arguments": {"x-max-length-bytes": 1024}}],
+	"bindings": [{"queue": "orders", "exchange": "amq.topic", "routing_key": "order.*"}]
+}`
+
+func writeTopology(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoadTopologyYAML(t *testing.T) {
+	topology, err := LoadTopology(writeTopology(t, "topology.yaml", topologyYAML))
+	assert.Nil(t, err)
+
+	assert.Len(t, topology.Exchanges, 1)
+	assert.Equal(t, "headers", topology.Exchanges[0].Type)
+	assert.True(t, topology.Exchanges[0].durable())
+
+	assert.Len(t, topology.Queues, 2)
+	args, err := topology.Queues[0].arguments()
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{
+		"x-queue-type":  "quorum",
+		"x-message-ttl": int64(30000),
+		"x-max-length":  int64(1000),
+	}, args)
+	assert.True(t, topology.declares("catalog-ae"))
+	assert.False(t, topology.declares("orders"))
+
+	archive := topology.Queues[1]
+	assert.False(t, archive.durable())
+	args, err = archive.arguments()
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{"x-queue-mode": "lazy", "x-overflow": "reject-publish"}, args)
+
+	assert.Len(t, topology.Bindings, 1)
+	assert.Equal(t, amqp.Table{"store_code": "ae-en", "x-match": "any"}, topology.Bindings[0].arguments())
+}
+
+func TestLoadTopologyJSON(t *testing.T) {
+	topology, err := LoadTopology(writeTopology(t, "topology.json", topologyJSON))
+	assert.Nil(t, err)
+
+	args, err := topology.Queues[0].arguments()
+	assert.Nil(t, err)
+	assert.Equal(t, amqp.Table{"x-max-priority": int64(10), "x-max-length-bytes": int64(1024)}, args)
+	assert.Nil(t, args.Validate())
+
+	assert.Equal(t, "order.*", topology.Bindings[0].RoutingKey)
+	assert.Equal(t, amqp.Table{}, topology.Bindings[0].arguments())
+}
+
+func TestLoadTopologyInvalid(t *testing.T) {
+	_, err := LoadTopology(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NotNil(t, err)
+
+	topology, err := LoadTopology(writeTopology(t, "topology.yaml", "queues:\n  - name: orders\n    message_ttl: soon\n"))
+	assert.Nil(t, err)
+
+	_, err = topology.Queues[0].arguments()
+	assert.NotNil(t, err)
+}
+
+func TestNilTopologyDeclaresNothing(t *testing.T) {
+	var topology *Topology
+	assert.False(t, topology.declares("orders"))
+}
@@ -0,0 +1,426 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	amqptracing "github.com/eyewa/eyewa-go-lib/tracing/amqp"
+	"github.com/eyewa/eyewa-go-lib/utils"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Callback is invoked for every event of type T consumed/published - or with
+// an error if consuming/publishing failed.
+type Callback[T any] func(ctx context.Context, event *T, err error) error
+
+// Consume consumes events of any type from a queue until ctx is done, decoding
+// them with c. Events are processed, retried and deadlettered the same way as
+// with RMQClient.Consume. To have their errors recorded when deadlettered *T
+// should implement base.ErrorRecorder and to have metrics labelled by event
+// name base.NamedEvent.
+func Consume[T any](ctx context.Context, rmq *RMQClient, queue string, c codec.Codec, callback Callback[T]) {
+	consume(ctx, rmq, queue, "RabbitMQ.Consume", c, callback)
+}
+
+// Publish publishes an event of any type to a queue, encoding it with c. If the
+// queue is bound to a fanout, topic or headers exchange, the event is published
+// to the exchange instead - see QueueConfig.route. The outcome is pushed to callback.
+func Publish[T any](ctx context.Context, rmq *RMQClient, queue string, priority int, event *T, c codec.Codec, callback Callback[T]) {
+	publish(ctx, rmq, queue, priority, event, c, callback, func(event *T) (routing, error) {
+		return queueConfig(queue).route(event)
+	})
+}
+
+func consume[T any](ctx context.Context, rmq *RMQClient, queue, spanName string, c codec.Codec, callback Callback[T]) {
+	defer func() {
+		if ctx.Err() != nil {
+			_ = callback(ctx, nil, libErrs.ErrorConsumerStopped)
+			return
+		}
+
+		// reaching here means the connection meant to be long lived has died.
+		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
+	}()
+
+	if rmq.currentConnection() == nil {
+		_ = callback(ctx, nil, libErrs.ErrorNoRMQConnection)
+		return
+	}
+
+	log.Info(fmt.Sprintf("Listening to %s for new messages...", queue))
+
+	// attempt to consume events from broker - on a channel of its own
+	msgs, consumer, err := rmq.consume(ctx, queue)
+	if err != nil {
+		_ = callback(ctx, nil, fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), queue, err))
+		return
+	}
+
+	// handle incoming messages
+	rmq.process(ctx, queue, consumer, msgs, func(msg amqp.Delivery) {
+		handleDelivery(rmq, queue, spanName, msg, c, callback)
+	})
+}
+
+// handleDelivery processes a delivery consumed by consume.
+func handleDelivery[T any](rmq *RMQClient, queue, spanName string, msg amqp.Delivery, c codec.Codec, callback Callback[T]) {
+	ctx := context.Background()
+	started := time.Now()
+	delivery := newDelivery(queue, msg)
+
+	deadletter := func(msg base.Delivery, eventErr error) error {
+		return sendToDeadletter[T](rmq, c, msg, eventErr)
+	}
+
+	// set amqp message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingOperationReceive,
+			semconv.MessagingRabbitMQRoutingKeyKey.String(msg.RoutingKey)),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+	}
+
+	// extract context from headers, if none, the
+	// context will use the background context.
+	carrier := amqptracing.NewHeaderCarrier(delivery.Headers)
+	log.Debug(fmt.Sprintf("carrier before extract: %v", carrier.Keys()))
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+	log.Debug(fmt.Sprintf("carrier after extract: %v", carrier.Keys()))
+
+	// start the span and and receive a new ctx containing the parent
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+
+	go standardMetrics.ActiveConsumingEventCounter.Add(1)
+
+	// attempt to unmarshal event - a cloud event or encoded with c
+	event := new(T)
+	if err := decodeEvent(delivery, event, c); err != nil {
+		unErrEvent := unmarshalledEvent[T]{
+			unmarshalledCommon{
+				queue:   queue,
+				msg:     delivery,
+				span:    span,
+				started: started,
+				err:     err,
+			},
+			callback,
+			deadletter,
+		}
+
+		handleUnmarshalledEventErr(ctx, unErrEvent)
+		return
+	}
+
+	// nack if callback/service yields an error - panics or times out - for whatever reason
+	if err := invokeWithTimeout(ctx, span, callback, event); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		// publish message to retry queue or DL once out of attempts
+		errDL := rmq.retryOrDeadletter(delivery, err, deadletter)
+		if errDL != nil {
+			go standardMetrics.DeadletterPublishFailureCounter.Add(1)
+			span.RecordError(errDL)
+			span.SetStatus(codes.Error, errDL.Error())
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errDL.Error())
+		}
+
+		// remove message from queue - or return it if it wasn't published
+		if errSettle := settleFailed(delivery, errDL); errSettle != nil {
+			go standardMetrics.NackFailureCounter.Add(1)
+			span.RecordError(errSettle)
+			span.SetStatus(codes.Error, errSettle.Error())
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errSettle.Error())
+		}
+
+		go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
+		go standardMetrics.ActiveConsumingEventCounter.Add(-1)
+		span.End()
+		return
+	}
+
+	// ack message
+	if err := delivery.Ack(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
+			err.Error(),
+			zap.String("queue", queue),
+			zap.String("event", string(delivery.Body)))
+
+		// nack message and return to queue
+		if err := delivery.Nack(true); err != nil {
+			go standardMetrics.NackFailureCounter.Add(1)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
+				err.Error(),
+				zap.String("queue", queue),
+				zap.String("event", string(delivery.Body)))
+		}
+
+		span.End()
+		return
+	}
+
+	log.Debug("Consumed successfully.", zap.Any("event", event))
+
+	go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
+	go standardMetrics.ConsumedEventCounter.Add(1, attribute.Any("event_name", base.EventNameOf(event)))
+	go standardMetrics.ActiveConsumingEventCounter.Add(-1)
+	span.End()
+}
+
+// invokeCallback invokes the callback of a delivery. A panic in the callback is
+// recovered from and returned as an error - recorded on span along with the
+// stack - so the delivery is retried/deadlettered rather than left unacked.
+func invokeCallback[T any](ctx context.Context, span trace.Span, callback Callback[T], event *T, err error) (errCallback error) {
+	defer func() {
+		if r := recover(); r != nil {
+			errCallback = fmt.Errorf(libErrs.ErrorCallbackPanic.Error(), r)
+			stack := string(debug.Stack())
+
+			eventName := ""
+			if event != nil {
+				eventName = base.EventNameOf(event)
+			}
+
+			go standardMetrics.CallbackPanicCounter.Add(1, attribute.Any("event_name", eventName))
+			span.RecordError(errCallback, trace.WithAttributes(semconv.ExceptionStacktraceKey.String(stack)))
+			span.SetStatus(codes.Error, errCallback.Error())
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
+				errCallback.Error(),
+				zap.String("stack", stack))
+		}
+	}()
+
+	return callback(ctx, event, err)
+}
+
+// invokeWithTimeout invokes the callback of a delivery, giving it up to
+// CONSUMER_MESSAGE_TIMEOUT to process the event. Once timed out, the callback's
+// ctx is done and the delivery is failed once the callback returns - never while
+// it is still running, so a retry can't be processed alongside it.
+func invokeWithTimeout[T any](ctx context.Context, span trace.Span, callback Callback[T], event *T) error {
+	timeout := messageTimeout()
+	if timeout == 0 {
+		return invokeCallback(ctx, span, callback, event, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// failed by the timeout or not at all
+	err := invokeCallback(ctx, span, callback, event, nil)
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	err = fmt.Errorf(libErrs.ErrorMessageTimeout.Error(), timeout)
+
+	go standardMetrics.MessageTimeoutCounter.Add(1, attribute.Any("event_name", base.EventNameOf(event)))
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	return err
+}
+
+// settleFailed settles a delivery that failed once republished to the retry/deadletter
+// queue - acking it, or if errPublish tells it wasn't, requeueing it rather than lose it.
+func settleFailed(delivery base.Delivery, errPublish error) error {
+	if errPublish != nil {
+		return delivery.Nack(true)
+	}
+
+	return delivery.Ack()
+}
+
+func handleUnmarshalledEventErr[T any](ctx context.Context, errEvent unmarshalledEvent[T]) {
+	errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), errEvent.queue, errEvent.err)
+
+	go standardMetrics.UnmarshalEventFailureCounter.Add(1)
+	errEvent.span.RecordError(errEvent.err)
+	_ = invokeCallback(ctx, errEvent.span, errEvent.callback, nil, errMsg)
+
+	// publish message to DL
+	errDL := errEvent.deadletter(errEvent.msg, errMsg)
+	if errDL != nil {
+		go standardMetrics.DeadletterPublishFailureCounter.Add(1)
+		errEvent.span.RecordError(errDL)
+		_ = invokeCallback(ctx, errEvent.span, errEvent.callback, nil, errDL)
+	}
+
+	// remove message from queue - or return it if it wasn't published
+	if err := settleFailed(errEvent.msg, errDL); err != nil {
+		go standardMetrics.NackFailureCounter.Add(1)
+		errEvent.span.RecordError(err)
+		_ = invokeCallback(ctx, errEvent.span, errEvent.callback, nil, err)
+	}
+
+	go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(errEvent.started).Milliseconds()))
+	go standardMetrics.ActiveConsumingEventCounter.Add(-1)
+	errEvent.span.End()
+}
+
+func publish[T any](ctx context.Context, rmq *RMQClient, queue string, priority int, event *T, c codec.Codec, callback Callback[T], route func(*T) (routing, error)) {
+	rmq.mutex.RLock()
+	channel, exists := rmq.channels[queue]
+	rmq.mutex.RUnlock()
+
+	// determine if channel exists for queue
+	if !exists && channel == nil {
+		channel, err := rmq.CreateNewChannel(queue)
+		if err != nil {
+			_ = callback(ctx, event, err)
+			return
+		}
+
+		if err := rmq.enableConfirms(queue, channel); err != nil {
+			_ = callback(ctx, event, err)
+			return
+		}
+
+		errQ := rmq.declareQueue(channel, queueConfig(queue))
+		if errQ != nil {
+			_ = callback(ctx, event, errQ)
+			return
+		}
+	}
+
+	rmq.mutex.RLock()
+	channel, exists = rmq.channels[queue]
+	rmq.mutex.RUnlock()
+
+	if exists && channel != nil {
+		r, err := route(event)
+		if err != nil {
+			go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", base.EventNameOf(event)))
+			_ = callback(ctx, event, err)
+			return
+		}
+
+		msg := &amqp.Publishing{
+			Headers:      r.headers,
+			DeliveryMode: amqp.Persistent,
+			Priority:     uint8(priority),
+		}
+
+		// set amqp message span attributes.
+		spanOpts := []trace.SpanOption{
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String(strings.ToUpper(config.MessageBroker)),
+				semconv.MessagingDestinationKindKeyQueue,
+				semconv.MessagingRabbitMQRoutingKeyKey.String(r.key)),
+			trace.WithSpanKind(trace.SpanKindProducer),
+		}
+
+		// start the span and and receive a new ctx containing the parent
+		ctx, span := otel.Tracer(tracerName).Start(ctx, "RabbitMQ.Publish", spanOpts...)
+		defer span.End()
+
+		// attempt to marshal event for publishing - as a cloud event if the queue publishes them
+		encoded, err := encodeEvent(queue, event, c)
+		if err != nil {
+			go standardMetrics.MarshalEventFailureCounter.Add(1)
+			span.RecordError(err)
+			_ = callback(ctx, event, err)
+			return
+		}
+
+		msg.ContentType = encoded.ContentType
+		msg.ContentEncoding = encoded.ContentEncoding
+		msg.Headers = withTraceContext(ctx, withHeaders(msg.Headers, encoded.Headers))
+		msg.Body = encoded.Body
+
+		err = rmq.publish(queue, channel, r.exchange, r.key, *msg)
+		if err != nil {
+			go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", base.EventNameOf(event)))
+			span.RecordError(err)
+			err = callback(ctx, event, publishError(err))
+			if err != nil {
+				span.RecordError(err)
+			}
+			return
+		}
+
+		go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", base.EventNameOf(event)))
+
+		// record the callback failing
+		err = callback(ctx, event, nil)
+		if err != nil {
+			span.RecordError(err)
+		}
+	}
+}
+
+// withTraceContext copies headers with the trace context of ctx injected, so the
+// spans of consuming a message are children of the span it was published in.
+func withTraceContext(ctx context.Context, headers amqp.Table) amqp.Table {
+	traced := make(amqp.Table, len(headers)+2)
+	for k, v := range headers {
+		traced[k] = v
+	}
+
+	// the carrier holds a copy of the headers, its keys are copied back
+	carrier := amqptracing.NewHeaderCarrier(traced)
+
+	log.Debug("Injecting trace context into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for _, k := range carrier.Keys() {
+		traced[k] = carrier.Get(k)
+	}
+	log.Debug("Trace context injected into rabbitmq Table headers", zap.Any("headers", carrier.Keys()))
+
+	return traced
+}
+
+// sendToDeadletter publishes a failed delivery of an event of type T to the
+// deadletter queue, recording the errors it failed with on the event.
+func sendToDeadletter[T any](rmq *RMQClient, c codec.Codec, msg base.Delivery, eventErr error) error {
+	// errors of any previous attempts + the final one
+	errs := append(retryErrors(msg), base.Error{
+		ErrorMessage: eventErr.Error(),
+		CreatedAt:    utils.NowRFC3339(),
+	})
+
+	return rmq.publishToDeadletter(msg.Queue, deadletterMessage[T](c, msg, errs))
+}
+
+// withErrors records errs on the event encoded in body. If the event can't
+// be decoded or doesn't record errors, body is returned as is.
+func withErrors[T any](c codec.Codec, body []byte, errs []base.Error) []byte {
+	event := new(T)
+	if err := c.Unmarshal(body, event); err != nil {
+		return body
+	}
+
+	recorder, ok := interface{}(event).(base.ErrorRecorder)
+	if !ok {
+		return body
+	}
+
+	recorder.RecordErrors(errs...)
+
+	data, err := c.Marshal(event)
+	if err != nil {
+		return body
+	}
+
+	return data
+}
@@ -0,0 +1,218 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+type plainEvent struct {
+	ID     string       `json:"id"`
+	Errors []base.Error `json:"errors"`
+}
+
+func TestWithErrors(t *testing.T) {
+	errs := []base.Error{{ErrorMessage: "boom", CreatedAt: "2021-01-01T00:00:00Z"}}
+
+	t.Run("recorded on events recording errors", func(t *testing.T) {
+		body := withErrors[base.EyewaEvent](codec.JSON{}, []byte(`{"id":"1","name":"product.created"}`), errs)
+
+		var event base.EyewaEvent
+		assert.Nil(t, json.Unmarshal(body, &event))
+		assert.Equal(t, "1", event.ID)
+		assert.Equal(t, errs, event.Errors)
+	})
+
+	t.Run("appended to existing errors", func(t *testing.T) {
+		body := withErrors[base.MagentoProductEvent](codec.JSON{},
+			[]byte(`{"event":"product.updated","errors":[{"error_message":"first"}]}`), errs)
+
+		var event base.MagentoProductEvent
+		assert.Nil(t, json.Unmarshal(body, &event))
+		assert.Len(t, event.Errors, 2)
+		assert.Equal(t, "boom", event.Errors[1].ErrorMessage)
+	})
+
+	t.Run("body as is for events not recording errors", func(t *testing.T) {
+		body := []byte(`{"id":"1"}`)
+		assert.Equal(t, body, withErrors[plainEvent](codec.JSON{}, body, errs))
+	})
+
+	t.Run("body as is if undecodable", func(t *testing.T) {
+		body := []byte(`not json`)
+		assert.Equal(t, body, withErrors[base.EyewaEvent](codec.JSON{}, body, errs))
+	})
+}
+
+func TestEventNameOf(t *testing.T) {
+	assert.Equal(t, "product.created", base.EventNameOf(&base.EyewaEvent{Name: "product.created"}))
+	assert.Equal(t, "", base.EventNameOf(&plainEvent{ID: "1"}))
+}
+
+func TestInvokeCallbackRecoversPanic(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	_, span := otel.Tracer(tracerName).Start(context.Background(), "test")
+	defer span.End()
+
+	tests := []struct {
+		name     string
+		event    *base.EyewaEvent
+		callback Callback[base.EyewaEvent]
+		err      string
+	}{
+		{"no panic", &base.EyewaEvent{}, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			return errors.New("failed")
+		}, "failed"},
+		{"panic", &base.EyewaEvent{Name: "product.created"}, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			panic("boom")
+		}, "Recovered from panic in callback. boom"},
+		{"panic without event", nil, func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			panic(errors.New("boom"))
+		}, "Recovered from panic in callback. boom"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := invokeCallback(context.Background(), span, test.callback, test.event, nil)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestUnmarshalledEventErrRecoversPanic(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	_, span := otel.Tracer(tracerName).Start(context.Background(), "test")
+
+	// the delivery can't be nacked nor deadlettered, the callback panics on every error
+	var calls []error
+	errEvent := unmarshalledEvent[base.EyewaEvent]{
+		unmarshalledCommon{queue: "catalog", msg: base.Delivery{}, span: span, started: time.Now(), err: errors.New("not json")},
+		func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			calls = append(calls, err)
+			panic("boom")
+		},
+		func(base.Delivery, error) error { return errors.New("no channel") },
+	}
+
+	assert.NotPanics(t, func() { handleUnmarshalledEventErr(context.Background(), errEvent) })
+	assert.Len(t, calls, 3)
+}
+
+// settlements records how deliveries are settled, along with the deadletter publishing.
+type settlements struct {
+	calls []string
+}
+
+func (s *settlements) Ack() error {
+	s.calls = append(s.calls, "ack")
+	return nil
+}
+
+func (s *settlements) Nack(requeue bool) error {
+	s.calls = append(s.calls, fmt.Sprintf("nack requeue=%t", requeue))
+	return nil
+}
+
+func (s *settlements) Reject(requeue bool) error {
+	s.calls = append(s.calls, fmt.Sprintf("reject requeue=%t", requeue))
+	return nil
+}
+
+func TestUnmarshalledEventErrSettledOncePublished(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+
+	tests := []struct {
+		name  string
+		errDL error
+		calls []string
+	}{
+		{"deadlettered", nil, []string{"deadletter", "ack"}},
+		{"not deadlettered", errors.New("no channel"), []string{"deadletter", "nack requeue=true"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, span := otel.Tracer(tracerName).Start(context.Background(), "test")
+			acks := new(settlements)
+
+			errEvent := unmarshalledEvent[base.EyewaEvent]{
+				unmarshalledCommon{queue: "catalog", msg: base.Delivery{Acknowledger: acks}, span: span, started: time.Now(), err: errors.New("not json")},
+				func(ctx context.Context, event *base.EyewaEvent, err error) error { return err },
+				func(base.Delivery, error) error {
+					acks.calls = append(acks.calls, "deadletter")
+					return test.errDL
+				},
+			}
+
+			handleUnmarshalledEventErr(context.Background(), errEvent)
+			assert.Equal(t, test.calls, acks.calls)
+		})
+	}
+}
+
+func TestInvokeWithTimeout(t *testing.T) {
+	standardMetrics = NewRabbitMQMetrics()
+	defer func() { config = Config{} }()
+
+	_, span := otel.Tracer(tracerName).Start(context.Background(), "test")
+	defer span.End()
+
+	tests := []struct {
+		name     string
+		timeout  string
+		callback Callback[base.EyewaEvent]
+		err      string
+	}{
+		{"no timeout", "", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			_, hasDeadline := ctx.Deadline()
+			assert.False(t, hasDeadline)
+			return nil
+		}, ""},
+		{"in time", "1s", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			return errors.New("failed")
+		}, "failed"},
+		{"timed out", "10ms", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, "Timed out processing message after 10ms."},
+		{"timed out ignoring ctx", "10ms", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			time.Sleep(50 * time.Millisecond)
+			return errors.New("failed")
+		}, "Timed out processing message after 10ms."},
+		{"done after timing out", "10ms", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config = Config{ConsumerMessageTimeout: test.timeout}
+
+			// the callback has always returned by the time the delivery is failed
+			returned := false
+			callback := func(ctx context.Context, event *base.EyewaEvent, err error) error {
+				defer func() { returned = true }()
+				return test.callback(ctx, event, err)
+			}
+
+			started := time.Now()
+			err := invokeWithTimeout(context.Background(), span, callback, &base.EyewaEvent{})
+			assert.True(t, returned)
+			if test.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+			assert.Less(t, time.Since(started), 500*time.Millisecond)
+		})
+	}
+}
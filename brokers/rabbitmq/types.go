@@ -30,6 +30,58 @@ type Config struct {
 
 	ConsumerExchange string `mapstructure:"rabbitmq_consumer_exchange"`
 
+	// Further queues to consume from + publish to - a JSON list of QueueConfig
+	Queues string `mapstructure:"rabbitmq_queues"`
+	queues []QueueConfig
+
+	// No. of workers processing consumed messages in parallel and optionally, a
+	// field of the message body whose value messages are processed in order by
+	// e.g entity_id, store_code or payload.id
+	ConsumerWorkerCount string `mapstructure:"consumer_worker_count"`
+	ConsumerOrderingKey string `mapstructure:"consumer_ordering_key"`
+
+	// How long a stopped consumer waits for messages in flight to be processed
+	ConsumerShutdownTimeout string `mapstructure:"consumer_shutdown_timeout"`
+
+	// How long a consumer callback has to process a message before it is failed
+	ConsumerMessageTimeout string `mapstructure:"consumer_message_timeout"`
+
+	// If true, published messages are mandatory and only reported as
+	// published once the broker confirms them - within ConfirmTimeout.
+	PublisherConfirms string `mapstructure:"rabbitmq_publisher_confirms"`
+	ConfirmTimeout    string `mapstructure:"rabbitmq_confirm_timeout"`
+
+	// If true, a lost connection is recovered by the client and consumers resume
+	AutoRecovery string `mapstructure:"rabbitmq_auto_recovery"`
+
+	// Retry policy for messages failed by a consumer. A failed message is retried
+	// up to RetryMaxAttempts times - each after an exponentially growing delay -
+	// before being deadlettered.
+	RetryMaxAttempts  string `mapstructure:"rabbitmq_retry_max_attempts"`
+	RetryInitialDelay string `mapstructure:"rabbitmq_retry_initial_delay"`
+	RetryMultiplier   string `mapstructure:"rabbitmq_retry_multiplier"`
+	RetryMaxDelay     string `mapstructure:"rabbitmq_retry_max_delay"`
+
+	// Publish events to PUBLISHER_QUEUE_NAME as CloudEvents - structured or
+	// binary - produced by CloudEventsSource, defaulting to SERVICE_NAME.
+	PublisherCloudEvents string `mapstructure:"rabbitmq_publisher_cloud_events"`
+	CloudEventsSource    string `mapstructure:"rabbitmq_cloud_events_source"`
+
+	// Content type events are published to PUBLISHER_QUEUE_NAME as e.g
+	// application/msgpack - see codec.Lookup. defaults to application/json
+	PublisherContentType string `mapstructure:"rabbitmq_publisher_content_type"`
+
+	// Compress events published to PUBLISHER_QUEUE_NAME - gzip or zstd - once
+	// encoded larger than CompressionThreshold bytes. defaults to 1024
+	PublisherCompression string `mapstructure:"rabbitmq_publisher_compression"`
+	CompressionThreshold string `mapstructure:"rabbitmq_compression_threshold"`
+
+	// YAML/JSON file declaring the exchanges, queues and bindings to set up on
+	// Connect. If TopologyVerify is true, RMQ is only checked against it and
+	// nothing is declared by the client.
+	TopologyFile   string `mapstructure:"rabbitmq_topology_file"`
+	TopologyVerify string `mapstructure:"rabbitmq_topology_verify"`
+
 	// Exchanges to bind consumer + publisher queues to
 	PublisherExchangeType string `mapstructure:"rabbitmq_publisher_exchange_type"`
 	ConsumerExchangeType  string `mapstructure:"rabbitmq_consumer_exchange_type"`
@@ -44,26 +96,33 @@ type RMQClient struct {
 	mutex *sync.RWMutex
 
 	connection *amqp.Connection
+	connStr    string
 
-	// Map of channels for all queues
+	// Map of channels for all queues - published to, consumers have channels of their own
 	channels map[string]*amqp.Channel
-}
 
-type unmarshalledEyewaEvent struct {
-	unmarshalledCommon
-	event    *base.EyewaEvent
-	callback base.MessageBrokerCallbackFunc
+	// Map of confirmers for publisher channels in confirm mode
+	confirmers map[string]*confirmer
+
+	// Topology loaded on Connect - if any
+	topology *Topology
+
+	// Connection state + hook invoked on state transitions
+	stateMutex   sync.Mutex
+	state        ConnectionState
+	stateHook    StateHook
+	stateChanged chan struct{}
 }
 
-type unmarshalledMagentoEvent struct {
+type unmarshalledEvent[T any] struct {
 	unmarshalledCommon
-	event    *base.MagentoProductEvent
-	callback base.MessageBrokerMagentoProductCallbackFunc
+	callback   Callback[T]
+	deadletter func(msg base.Delivery, eventErr error) error
 }
 
 type unmarshalledCommon struct {
 	queue   string
-	msg     amqp.Delivery
+	msg     base.Delivery
 	span    trace.Span
 	started time.Time
 	err     error
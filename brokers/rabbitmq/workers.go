@@ -0,0 +1,136 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	"github.com/streadway/amqp"
+)
+
+// workerCount no. of workers processing deliveries in parallel. defaults to 1
+// i.e deliveries are processed sequentially.
+func workerCount() int {
+	count, err := strconv.Atoi(config.ConsumerWorkerCount)
+	if err != nil || count < 1 {
+		return 1
+	}
+
+	return count
+}
+
+// orderingKey the value of the configured ordering key field in a delivery's
+// body e.g entity_id or payload.id, decoded with the codec of its content type.
+// Deliveries with the same key are processed in the order received. empty if
+// the delivery has no such field.
+func orderingKey(msg amqp.Delivery) string {
+	if config.ConsumerOrderingKey == "" {
+		return ""
+	}
+
+	data, err := compression.Decompress(msg.ContentEncoding, msg.Body)
+	if err != nil {
+		return ""
+	}
+
+	if data, err = asJSON(deliveryCodec(msg.ContentType, codec.JSON{}), msg.Headers, data); err != nil {
+		return ""
+	}
+
+	body := json.RawMessage(data)
+	for _, field := range strings.Split(config.ConsumerOrderingKey, ".") {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return ""
+		}
+
+		var exists bool
+		if body, exists = fields[field]; !exists {
+			return ""
+		}
+	}
+
+	// strings are keyed by their value, anything else by its JSON
+	var key string
+	if err := json.Unmarshal(body, &key); err == nil {
+		return key
+	}
+
+	return string(body)
+}
+
+// worker picks the worker for a key, so all deliveries with the same key end up on the same worker.
+func worker(key string, workers int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(workers))
+}
+
+// dispatch hands deliveries to handle, using as many workers as configured. It
+// returns once the deliveries are closed and every delivery has been handled.
+//
+// If an ordering key is configured, each key is pinned to a worker - deliveries
+// sharing a key are handled serially while other keys are handled in parallel.
+// Otherwise any idle worker takes the next delivery.
+func (rmq *RMQClient) dispatch(msgs <-chan amqp.Delivery, handle func(amqp.Delivery)) {
+	workers := workerCount()
+	if workers == 1 {
+		for msg := range msgs {
+			handle(msg)
+		}
+		return
+	}
+
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+
+	queues := make([]chan amqp.Delivery, workers)
+	for i := range queues {
+		// unordered deliveries share a single queue
+		if i == 0 || config.ConsumerOrderingKey != "" {
+			queues[i] = make(chan amqp.Delivery)
+		} else {
+			queues[i] = queues[0]
+		}
+
+		go func(queue <-chan amqp.Delivery) {
+			defer wg.Done()
+			for msg := range queue {
+				handle(msg)
+			}
+		}(queues[i])
+	}
+
+	next := 0
+	for msg := range msgs {
+		if config.ConsumerOrderingKey == "" {
+			queues[0] <- msg
+			continue
+		}
+
+		// deliveries without a key can be handled by any worker
+		key := orderingKey(msg)
+		if key == "" {
+			next = (next + 1) % workers
+			queues[next] <- msg
+			continue
+		}
+
+		queues[worker(key, workers)] <- msg
+	}
+
+	if config.ConsumerOrderingKey == "" {
+		close(queues[0])
+	} else {
+		for _, queue := range queues {
+			close(queue)
+		}
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,148 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/eyewa/eyewa-go-lib/codec"
+	"github.com/eyewa/eyewa-go-lib/compression"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func deliveries(bodies ...string) <-chan amqp.Delivery {
+	msgs := make(chan amqp.Delivery, len(bodies))
+	for _, body := range bodies {
+		msgs <- amqp.Delivery{Body: []byte(body)}
+	}
+	close(msgs)
+
+	return msgs
+}
+
+func TestWorkerCount(t *testing.T) {
+	defer func() { config = Config{} }()
+
+	for value, expected := range map[string]int{"": 1, "0": 1, "-2": 1, "bleh": 1, "8": 8} {
+		config = Config{ConsumerWorkerCount: value}
+		assert.Equal(t, expected, workerCount(), value)
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	defer func() { config = Config{} }()
+
+	tests := []struct {
+		key      string
+		body     string
+		expected string
+	}{
+		{"", `{"entity_id":10}`, ""},
+		{"entity_id", `{"entity_id":10}`, "10"},
+		{"store_code", `{"store_code":"ae-en"}`, "ae-en"},
+		{"payload.id", `{"payload":{"id":"sku-1"}}`, "sku-1"},
+		{"payload.id", `{"payload":"flat"}`, ""},
+		{"entity_id", `{"store_code":"ae-en"}`, ""},
+		{"entity_id", `not json`, ""},
+	}
+
+	for _, tt := range tests {
+		config = Config{ConsumerOrderingKey: tt.key}
+		assert.Equal(t, tt.expected, orderingKey(amqp.Delivery{Body: []byte(tt.body)}), tt.key+" "+tt.body)
+	}
+
+	// compressed bodies are keyed by their decompressed fields
+	config = Config{ConsumerOrderingKey: "entity_id"}
+	compressed, err := compression.Compress(compression.Zstd, []byte(`{"entity_id":10}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "10", orderingKey(amqp.Delivery{ContentEncoding: compression.Zstd, Body: compressed}))
+
+	// bodies are decoded with the codec of their content type
+	msgpack, err := codec.MsgPack{}.Marshal(&base.EyewaEvent{ID: "1", Payload: []byte(`{"id":"sku-1"}`)})
+	assert.Nil(t, err)
+	avro, err := codec.Avro{}.Marshal(&base.MagentoProductEvent{ID: "2", EntityID: 10})
+	assert.Nil(t, err)
+
+	config = Config{ConsumerOrderingKey: "payload.id"}
+	assert.Equal(t, "sku-1", orderingKey(amqp.Delivery{ContentType: "application/msgpack", Body: msgpack}))
+
+	config = Config{ConsumerOrderingKey: "entity_id"}
+	assert.Equal(t, "10", orderingKey(amqp.Delivery{ContentType: "avro/binary", Headers: amqp.Table{"x-type-of-event": "magento"}, Body: avro}))
+}
+
+func TestWorker(t *testing.T) {
+	assert.Equal(t, worker("ae-en", 4), worker("ae-en", 4))
+	assert.Less(t, worker("sa-ar", 4), 4)
+}
+
+func TestDispatchSequential(t *testing.T) {
+	config = Config{}
+
+	var handled []string
+	NewRMQClient().dispatch(deliveries("1", "2", "3"), func(msg amqp.Delivery) {
+		handled = append(handled, string(msg.Body))
+	})
+
+	assert.Equal(t, []string{"1", "2", "3"}, handled)
+}
+
+func TestDispatchParallel(t *testing.T) {
+	config = Config{ConsumerWorkerCount: "3"}
+	defer func() { config = Config{} }()
+
+	var active, maxActive, handled int32
+	NewRMQClient().dispatch(deliveries("1", "2", "3", "4", "5", "6"), func(msg amqp.Delivery) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			max := atomic.LoadInt32(&maxActive)
+			if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		atomic.AddInt32(&handled, 1)
+	})
+
+	// every delivery is handled before dispatch returns
+	assert.Equal(t, int32(6), handled)
+	assert.Greater(t, maxActive, int32(1))
+	assert.LessOrEqual(t, maxActive, int32(3))
+}
+
+func TestDispatchOrdered(t *testing.T) {
+	config = Config{ConsumerWorkerCount: "4", ConsumerOrderingKey: "entity_id"}
+	defer func() { config = Config{} }()
+
+	var bodies []string
+	for i := 0; i < 20; i++ {
+		bodies = append(bodies, fmt.Sprintf(`{"entity_id":%d,"seq":%d}`, i%3, i))
+	}
+
+	mutex := new(sync.Mutex)
+	handled := make(map[string][]string)
+	NewRMQClient().dispatch(deliveries(bodies...), func(msg amqp.Delivery) {
+		time.Sleep(time.Millisecond)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		key := orderingKey(msg)
+		handled[key] = append(handled[key], string(msg.Body))
+	})
+
+	for key, msgs := range handled {
+		var expected []string
+		for _, body := range bodies {
+			if orderingKey(amqp.Delivery{Body: []byte(body)}) == key {
+				expected = append(expected, body)
+			}
+		}
+		assert.Equal(t, expected, msgs, key)
+	}
+	assert.Len(t, handled, 3)
+}
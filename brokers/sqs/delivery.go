@@ -0,0 +1,87 @@
+package sqs
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+)
+
+// acknowledger settles a base.Delivery with the SQS queue it was received from.
+type acknowledger struct {
+	client *SQSClient
+	url    string
+	msg    *sqs.Message
+}
+
+// Ack acknowledges a message by deleting it from the queue.
+func (a acknowledger) Ack() error {
+	_, err := a.client.client.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(a.url),
+		ReceiptHandle: a.msg.ReceiptHandle,
+	})
+	if err != nil {
+		return fmt.Errorf("%s %s", libErrs.ErrorAckFailure.Error(), err)
+	}
+
+	return nil
+}
+
+// Nack resets the visibility timeout of a message so it is redelivered
+// straight away if requeue is true. Otherwise it is deleted from the queue.
+func (a acknowledger) Nack(requeue bool) error {
+	if !requeue {
+		return a.Ack()
+	}
+
+	_, err := a.client.client.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(a.url),
+		ReceiptHandle:     a.msg.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(0),
+	})
+	if err != nil {
+		return fmt.Errorf("%s %s", libErrs.ErrorNackFailure.Error(), err)
+	}
+
+	return nil
+}
+
+// Reject behaves like Nack as SQS makes no distinction between the two.
+func (a acknowledger) Reject(requeue bool) error {
+	return a.Nack(requeue)
+}
+
+// newDelivery maps an SQS message received from queue at url onto a base.Delivery.
+func (c *SQSClient) newDelivery(queue, url string, msg *sqs.Message) base.Delivery {
+	headers := make(map[string]interface{}, len(msg.MessageAttributes))
+	for k, v := range msg.MessageAttributes {
+		if v != nil && v.StringValue != nil {
+			headers[k] = aws.StringValue(v.StringValue)
+		}
+	}
+
+	delivery := base.Delivery{
+		Acknowledger: acknowledger{c, url, msg},
+		MessageID:    aws.StringValue(msg.MessageId),
+		Headers:      headers,
+		ContentType:  "application/json",
+		Body:         []byte(aws.StringValue(msg.Body)),
+		Queue:        queue,
+	}
+
+	// first receive counts as 1
+	if count, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount])); err == nil && count > 1 {
+		delivery.Redelivered = true
+		delivery.RedeliveryCount = count - 1
+	}
+
+	if sent, err := strconv.ParseInt(aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64); err == nil {
+		delivery.Timestamp = time.UnixMilli(sent)
+	}
+
+	return delivery
+}
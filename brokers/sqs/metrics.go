@@ -0,0 +1,105 @@
+package sqs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/unit"
+
+	"github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	"github.com/eyewa/eyewa-go-lib/metrics"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// SQSMetrics is a collection of standard metrics
+type SQSMetrics struct {
+	PublishedEventCounter           *metrics.Counter
+	PublishEventFailureCounter      *metrics.Counter
+	ConsumedEventCounter            *metrics.Counter
+	UnmarshalEventFailureCounter    *metrics.Counter
+	MarshalEventFailureCounter      *metrics.Counter
+	DeleteFailureCounter            *metrics.Counter
+	DeadletterPublishFailureCounter *metrics.Counter
+	ReceiveFailureCounter           *metrics.Counter
+	ActiveConsumingEventCounter     *metrics.UpDownCounter
+	ConsumedEventLatencyRecorder    *metrics.ValueRecorder
+}
+
+// NewSQSMetrics creates a instance of SQSMetrics
+func NewSQSMetrics() *SQSMetrics {
+	meter := metrics.NewMeter("sqs.meter", context.Background())
+
+	publishedEventCounter, err := meter.NewCounter("sqs.published.event.counter",
+		metric.WithDescription("Counts published events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	publishEventFailureCounter, err := meter.NewCounter("sqs.publish.event.failure.counter",
+		metric.WithDescription("Counts failed published events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	consumedEventCounter, err := meter.NewCounter("sqs.consumed.event.counter",
+		metric.WithDescription("Counts consumed events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	marshalEventFailureCounter, err := meter.NewCounter("sqs.marshal.event.failure.counter",
+		metric.WithDescription("Counts marshal event failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	unmarshalEventFailureCounter, err := meter.NewCounter("sqs.unmarshal.event.failure.counter",
+		metric.WithDescription("Counts unmarshal event failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	deleteFailureCounter, err := meter.NewCounter("sqs.delete.failure.counter",
+		metric.WithDescription("Counts message delete (ack) failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	deadletterPublishFailureCounter, err := meter.NewCounter("sqs.deadletter.publish.failure.counter",
+		metric.WithDescription("Counts deadletter publishing failures"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	receiveFailureCounter, err := meter.NewCounter("sqs.receive.failure.counter",
+		metric.WithDescription("Counts failures to receive messages - retried"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	activeConsumingEventCounter, err := meter.NewUpDownCounter("sqs.active.consuming.event.counter",
+		metric.WithDescription("Counts active consuming events"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	consumedEventLatencyRecorder, err := meter.NewValueRecorder("sqs.consumed.event.latency.recorder",
+		metric.WithUnit(unit.Milliseconds),
+		metric.WithDescription("Records consumed event latency"))
+	if err != nil {
+		log.Error(errors.ErrorFailedToCreateInstrument.Error())
+	}
+
+	return &SQSMetrics{
+		PublishedEventCounter:           publishedEventCounter,
+		PublishEventFailureCounter:      publishEventFailureCounter,
+		ConsumedEventCounter:            consumedEventCounter,
+		MarshalEventFailureCounter:      marshalEventFailureCounter,
+		UnmarshalEventFailureCounter:    unmarshalEventFailureCounter,
+		DeleteFailureCounter:            deleteFailureCounter,
+		DeadletterPublishFailureCounter: deadletterPublishFailureCounter,
+		ReceiveFailureCounter:           receiveFailureCounter,
+		ActiveConsumingEventCounter:     activeConsumingEventCounter,
+		ConsumedEventLatencyRecorder:    consumedEventLatencyRecorder,
+	}
+}
@@ -0,0 +1,755 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/cenkalti/backoff"
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/eyewa/eyewa-go-lib/log"
+	sqstracing "github.com/eyewa/eyewa-go-lib/tracing/sqs"
+	"github.com/eyewa/eyewa-go-lib/utils"
+	"github.com/ory/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+var (
+	config          Config
+	standardMetrics *SQSMetrics
+	tracerName      = "github.com/eyewa/eyewa-go-lib/brokers/sqs"
+
+	// SQS limits
+	maxNumberOfMessages      = 10
+	maxWaitTimeSeconds       = 20
+	defaultPrefetchCount     = 5
+	defaultVisibilityTimeout = 30
+	defaultMaxReceiveCount   = 5
+
+	// highest priority level a message can be published with
+	maxPriority = 5
+)
+
+func initConfig() (Config, error) {
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	envVars := []string{
+		"SERVICE_NAME",
+		"AWS_REGION",
+		"SQS_ENDPOINT",
+		"PUBLISHER_QUEUE_NAME",
+		"CONSUMER_QUEUE_NAME",
+		"QUEUE_PREFETCH_COUNT",
+		"SQS_WAIT_TIME_SECONDS",
+		"SQS_VISIBILITY_TIMEOUT",
+		"SQS_MAX_RECEIVE_COUNT",
+		"SQS_PRIORITY_QUEUES",
+		"MESSAGE_BROKER",
+	}
+
+	viper.SetDefault("QUEUE_PREFETCH_COUNT", defaultPrefetchCount)
+	viper.SetDefault("SQS_WAIT_TIME_SECONDS", maxWaitTimeSeconds)
+	viper.SetDefault("SQS_VISIBILITY_TIMEOUT", defaultVisibilityTimeout)
+	viper.SetDefault("SQS_MAX_RECEIVE_COUNT", defaultMaxReceiveCount)
+
+	for _, v := range envVars {
+		if err := viper.BindEnv(v); err != nil {
+			return config, err
+		}
+	}
+	if err := viper.Unmarshal(&config); err != nil {
+		return config, err
+	}
+
+	if config.QueuePrefetchCount <= 0 || config.QueuePrefetchCount > maxNumberOfMessages {
+		config.QueuePrefetchCount = maxNumberOfMessages
+	}
+
+	if config.WaitTimeSeconds < 0 || config.WaitTimeSeconds > maxWaitTimeSeconds {
+		config.WaitTimeSeconds = maxWaitTimeSeconds
+	}
+
+	return config, nil
+}
+
+// NewSQSClient new sqs client
+func NewSQSClient() *SQSClient {
+	return &SQSClient{
+		mutex:     new(sync.RWMutex),
+		client:    nil,
+		queueURLs: make(map[string]string),
+		closed:    make(chan struct{}),
+	}
+}
+
+// Connect creates an SQS session and declares the consumer and publisher
+// queues (along with their deadletter queues) if they do not exist yet.
+func (c *SQSClient) Connect() error {
+	// init configs
+	if _, err := initConfig(); err != nil {
+		return err
+	}
+
+	// init metrics
+	standardMetrics = NewSQSMetrics()
+
+	// if no queues are specified, back off.
+	if config.ConsumerQueueName == "" && config.PublisherQueueName == "" {
+		return libErrs.ErrorNoQueuesSpecified
+	}
+
+	if c.client == nil {
+		awsConfig := aws.NewConfig().WithRegion(config.Region)
+		if config.Endpoint != "" {
+			awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+		}
+
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return err
+		}
+
+		c.client = sqs.New(sess)
+	}
+
+	for _, queue := range []string{config.ConsumerQueueName, config.PublisherQueueName} {
+		if queue == "" {
+			continue
+		}
+
+		if err := c.declareQueue(queue); err != nil {
+			return err
+		}
+	}
+
+	c.mutex.Lock()
+	c.closed = make(chan struct{})
+	c.mutex.Unlock()
+
+	return nil
+}
+
+// Consume consumes messages from a queue
+func (c *SQSClient) Consume(queue string, callback base.MessageBrokerCallbackFunc) {
+	ctx := context.Background()
+	defer func() {
+		// reaching here means consumption meant to be long lived has stopped.
+		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
+	}()
+
+	err := c.consume(queue, "SQS.Consume", false, func(ctx context.Context, delivery base.Delivery) (string, error) {
+		var event *base.EyewaEvent
+
+		if err := json.Unmarshal(delivery.Body, &event); err != nil {
+			go standardMetrics.UnmarshalEventFailureCounter.Add(1)
+			errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), queue, err)
+			_ = callback(ctx, nil, errMsg)
+			return "", errMsg
+		}
+
+		return event.Name, callback(ctx, event, nil)
+	})
+	if err != nil {
+		_ = callback(ctx, nil, err)
+	}
+}
+
+// ConsumeMagentoProductEvents consumes magento product events from a queue
+func (c *SQSClient) ConsumeMagentoProductEvents(queue string, callback base.MessageBrokerMagentoProductCallbackFunc) {
+	ctx := context.Background()
+	defer func() {
+		// reaching here means consumption meant to be long lived has stopped.
+		_ = callback(ctx, nil, libErrs.ErrorLostConnectionToMessageBroker)
+	}()
+
+	err := c.consume(queue, "SQS.ConsumeMagentoProductEvents", true, func(ctx context.Context, delivery base.Delivery) (string, error) {
+		var event *base.MagentoProductEvent
+
+		if err := json.Unmarshal(delivery.Body, &event); err != nil {
+			go standardMetrics.UnmarshalEventFailureCounter.Add(1)
+			errMsg := fmt.Errorf(libErrs.ErrorEventUnmarshalFailure.Error(), queue, err)
+			_ = callback(ctx, nil, errMsg)
+			return "", errMsg
+		}
+
+		return event.Name, callback(ctx, event, nil)
+	})
+	if err != nil {
+		_ = callback(ctx, nil, err)
+	}
+}
+
+// consume long polls a queue (and its priority queues, if enabled) until the
+// client is closed. Every message received is passed to process - messages it
+// fails are deadlettered, all others are deleted. Failures to receive are retried
+// with an exponential backoff, unless they can't succeed - see retryable.
+func (c *SQSClient) consume(queue, spanName string, magento bool, process processFunc) error {
+	urls := make([]string, 0, maxPriority+1)
+	for priority := maxPriority; priority >= 0; priority-- {
+		if priority > 0 && !config.PriorityQueues {
+			continue
+		}
+
+		url, err := c.getQueueURL(priorityQueueName(queue, priority))
+		if err != nil {
+			return fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), queue, err)
+		}
+		urls = append(urls, url)
+	}
+
+	log.Info(fmt.Sprintf("Listening to %s for new messages...", queue))
+
+	// retry for as long as the client is open
+	bkoff := backoff.NewExponentialBackOff()
+	bkoff.MaxElapsedTime = 0
+
+	for {
+		select {
+		case <-c.done():
+			return nil
+		default:
+		}
+
+		receipts, err := c.receive(urls)
+		if err != nil {
+			if !retryable(err) {
+				return fmt.Errorf(libErrs.ErrorConsumeFailure.Error(), queue, err)
+			}
+
+			wait := bkoff.NextBackOff()
+			go standardMetrics.ReceiveFailureCounter.Add(1)
+			log.Warn(fmt.Sprintf(libErrs.ErrorReceiveFailure.Error(), queue, wait, err))
+
+			select {
+			case <-c.done():
+				return nil
+			case <-time.After(wait):
+			}
+			continue
+		}
+		bkoff.Reset()
+
+		for _, r := range receipts {
+			for _, msg := range r.msgs {
+				delivery := c.newDelivery(queue, r.url, msg)
+				if magento {
+					// ensures base.MagentoProductEvent is deadlettered instead of base.EyewaEvent
+					delivery.Headers["x-type-of-event"] = "magento"
+				}
+
+				c.handleDelivery(spanName, delivery, msg.MessageAttributes, process)
+			}
+		}
+	}
+}
+
+// retryable reports if receiving could succeed once retried - i.e unless SQS
+// refused it for good e.g the queue doesn't exist or access is denied.
+func retryable(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return true
+	}
+
+	switch awsErr.Code() {
+	case sqs.ErrCodeQueueDoesNotExist, sqs.ErrCodeUnsupportedOperation,
+		"AccessDenied", "AccessDeniedException", "InvalidClientTokenId":
+		return false
+	}
+
+	return true
+}
+
+// receipt the messages received from a queue.
+type receipt struct {
+	url  string
+	msgs []*sqs.Message
+}
+
+// receive receives the messages of the given queue urls - highest priority first.
+// The queues are checked in order, and if all are empty, long polled together
+// until any yields messages. Messages received from several queues at once are
+// returned in the order of the queues.
+func (c *SQSClient) receive(urls []string) ([]receipt, error) {
+	if len(urls) > 1 {
+		for _, url := range urls {
+			msgs, err := c.receiveFrom(context.Background(), url, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(msgs) > 0 {
+				return []receipt{{url, msgs}}, nil
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stop polling once the client is closed
+	go func() {
+		select {
+		case <-c.done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var (
+		wg       sync.WaitGroup
+		receipts = make([]receipt, len(urls))
+		errs     = make([]error, len(urls))
+	)
+
+	for i, url := range urls {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+
+			msgs, err := c.receiveFrom(ctx, url, config.WaitTimeSeconds)
+			if err != nil && ctx.Err() != nil {
+				// cancelled - another queue yielded messages
+				return
+			}
+
+			receipts[i], errs[i] = receipt{url, msgs}, err
+			if len(msgs) > 0 || err != nil {
+				cancel()
+			}
+		}(i, url)
+	}
+	wg.Wait()
+
+	received := make([]receipt, 0, len(receipts))
+	for _, r := range receipts {
+		if len(r.msgs) > 0 {
+			received = append(received, r)
+		}
+	}
+
+	// messages received are handled regardless of failures polling other queues
+	if len(received) == 0 {
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return received, nil
+}
+
+// receiveFrom receives the messages of a queue, long polling it for up to wait seconds.
+func (c *SQSClient) receiveFrom(ctx context.Context, url string, wait int) ([]*sqs.Message, error) {
+	out, err := c.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(url),
+		MaxNumberOfMessages:   aws.Int64(int64(config.QueuePrefetchCount)),
+		WaitTimeSeconds:       aws.Int64(int64(wait)),
+		VisibilityTimeout:     aws.Int64(int64(config.VisibilityTimeout)),
+		MessageAttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameAll}),
+		AttributeNames:        aws.StringSlice([]string{sqs.MessageSystemAttributeNameApproximateReceiveCount, sqs.MessageSystemAttributeNameSentTimestamp}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Messages, nil
+}
+
+func (c *SQSClient) handleDelivery(spanName string, delivery base.Delivery, attrs map[string]*sqs.MessageAttributeValue, process processFunc) {
+	started := time.Now()
+
+	// set sqs message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("SQS"),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingDestinationKey.String(delivery.Queue),
+			semconv.MessagingMessageIDKey.String(delivery.MessageID),
+			semconv.MessagingOperationReceive),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+	}
+
+	// extract context from message attributes, if none, the
+	// context will use the background context.
+	carrier := sqstracing.NewAttributeCarrier(attrs)
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+
+	// start the span and and receive a new ctx containing the parent
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+	defer span.End()
+
+	go standardMetrics.ActiveConsumingEventCounter.Add(1)
+	defer func() {
+		go standardMetrics.ConsumedEventLatencyRecorder.Record(float64(time.Since(started).Milliseconds()))
+		go standardMetrics.ActiveConsumingEventCounter.Add(-1)
+	}()
+
+	name, err := process(ctx, delivery)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		// publish message to DL and remove it from the queue. If it cannot
+		// be deadlettered, leave it be for SQS to redrive once its receive
+		// count is exhausted.
+		if errDL := c.SendToDeadletterQueue(delivery, err); errDL != nil {
+			go standardMetrics.DeadletterPublishFailureCounter.Add(1)
+			span.RecordError(errDL)
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errDL.Error())
+			return
+		}
+
+		if errNack := delivery.Nack(false); errNack != nil {
+			go standardMetrics.DeleteFailureCounter.Add(1)
+			span.RecordError(errNack)
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), errNack.Error())
+		}
+
+		return
+	}
+
+	// ack message
+	if err := delivery.Ack(); err != nil {
+		go standardMetrics.DeleteFailureCounter.Add(1)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.ErrorWithTraceID(span.SpanContext().TraceID().String(),
+			err.Error(),
+			zap.String("queue", delivery.Queue),
+			zap.String("event", string(delivery.Body)))
+
+		// make message visible again so it is redelivered
+		if err := delivery.Nack(true); err != nil {
+			span.RecordError(err)
+			log.ErrorWithTraceID(span.SpanContext().TraceID().String(), err.Error())
+		}
+
+		return
+	}
+
+	go standardMetrics.ConsumedEventCounter.Add(1, attribute.Any("event_name", name))
+}
+
+// Publish publishes a message to a queue
+func (c *SQSClient) Publish(ctx context.Context, queue string, priority int, event *base.EyewaEvent, callback base.MessageBrokerCallbackFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	eventJSON, err := json.Marshal(&event)
+	if err != nil {
+		go standardMetrics.MarshalEventFailureCounter.Add(1)
+		_ = callback(ctx, event, err)
+		return
+	}
+
+	ctx, err = c.publish(ctx, queue, priority, eventJSON, "SQS.Publish")
+	if err != nil {
+		go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", event.Name))
+		_ = callback(ctx, event, libErrs.ErrorFailedToPublishEvent)
+		return
+	}
+
+	go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", event.Name))
+	_ = callback(ctx, event, nil)
+}
+
+// PublishMagentoProductEvent publishes a magento product event to a queue
+func (c *SQSClient) PublishMagentoProductEvent(ctx context.Context, queue string, priority int, event *base.MagentoProductEvent, callback base.MessageBrokerMagentoProductCallbackFunc, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	eventJSON, err := json.Marshal(&event)
+	if err != nil {
+		go standardMetrics.MarshalEventFailureCounter.Add(1)
+		_ = callback(ctx, event, err)
+		return
+	}
+
+	ctx, err = c.publish(ctx, queue, priority, eventJSON, "SQS.PublishMagentoProductEvent")
+	if err != nil {
+		go standardMetrics.PublishEventFailureCounter.Add(1, attribute.Any("event_name", event.Name))
+		_ = callback(ctx, event, libErrs.ErrorFailedToPublishEvent)
+		return
+	}
+
+	go standardMetrics.PublishedEventCounter.Add(1, attribute.Any("event_name", event.Name))
+	_ = callback(ctx, event, nil)
+}
+
+// PublishEvent publishes any event structure to a queue based on priority.
+// Clients choose to handle publishing errors.
+func (c *SQSClient) PublishEvent(ctx context.Context, queue string, priority int, event *[]byte, wg *sync.WaitGroup) error {
+	defer wg.Done()
+
+	if event == nil {
+		return errors.New("Event is empty!")
+	}
+
+	_, err := c.publish(ctx, queue, priority, *event, "SQS.PublishEvent")
+	return err
+}
+
+func (c *SQSClient) publish(ctx context.Context, queue string, priority int, body []byte, spanName string) (context.Context, error) {
+	name := queue
+	if config.PriorityQueues {
+		name = priorityQueueName(queue, priority)
+	}
+
+	url, err := c.getQueueURL(name)
+	if err != nil {
+		return ctx, err
+	}
+
+	// set sqs message span attributes.
+	spanOpts := []trace.SpanOption{
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("SQS"),
+			semconv.MessagingDestinationKindKeyQueue,
+			semconv.MessagingDestinationKey.String(name)),
+		trace.WithSpanKind(trace.SpanKindProducer),
+	}
+
+	// start the span and and receive a new ctx containing the parent
+	ctx, span := otel.Tracer(tracerName).Start(ctx, spanName, spanOpts...)
+	defer span.End()
+
+	// inject context into message attributes
+	carrier := sqstracing.NewAttributeCarrier(nil)
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	_, err = c.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(url),
+		MessageBody:       aws.String(string(body)),
+		MessageAttributes: carrier.Attributes(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return ctx, err
+	}
+
+	return ctx, nil
+}
+
+// SendToDeadletterQueue publishes a failed delivery to the deadletter queue
+// of the queue it was received from. The message's errors are replaced by eventErr.
+func (c *SQSClient) SendToDeadletterQueue(msg base.Delivery, eventErr error) error {
+	queue := msg.Queue
+	if queue == "" {
+		queue = config.ConsumerQueueName
+	}
+
+	url, err := c.getQueueURL(deadletterQueueName(queue))
+	if err != nil {
+		return err
+	}
+
+	eventErrors := []base.Error{
+		{
+			ErrorMessage: eventErr.Error(),
+			CreatedAt:    utils.NowRFC3339(),
+		},
+	}
+
+	var eventData []byte
+	if msg.Header("x-type-of-event") == "magento" {
+		var event *base.MagentoProductEvent
+		if err = json.Unmarshal(msg.Body, &event); err != nil {
+			return err
+		}
+
+		event.Errors = eventErrors
+		eventData, err = json.Marshal(event)
+	} else {
+		var event *base.EyewaEvent
+		if err = json.Unmarshal(msg.Body, &event); err != nil {
+			return err
+		}
+
+		event.Errors = eventErrors
+		eventData, err = json.Marshal(event)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    aws.String(url),
+		MessageBody: aws.String(string(eventData)),
+	})
+	if err != nil {
+		log.Error(libErrs.ErrorFailedToPublishToDeadletter.Error(),
+			zap.String("event", string(eventData)),
+			zap.String("deadletter_queue", deadletterQueueName(queue)), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// declareQueue creates the deadletter queue for a queue, then the queue
+// itself (and its priority queues, if enabled) with a redrive policy
+// pointing at the deadletter queue. Creating an existing queue with the
+// same attributes is a no-op in SQS.
+func (c *SQSClient) declareQueue(queue string) error {
+	if queue == "" {
+		return libErrs.ErrorQueueNotSpecified
+	}
+
+	dlURL, err := c.createQueue(deadletterQueueName(queue), nil)
+	if err != nil {
+		return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), deadletterQueueName(queue), err)
+	}
+
+	attrs, err := c.client.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(dlURL),
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameQueueArn}),
+	})
+	if err != nil {
+		return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), deadletterQueueName(queue), err)
+	}
+
+	redrivePolicy, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": aws.StringValue(attrs.Attributes[sqs.QueueAttributeNameQueueArn]),
+		"maxReceiveCount":     fmt.Sprint(config.MaxReceiveCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	queueAttrs := map[string]*string{
+		sqs.QueueAttributeNameRedrivePolicy:     aws.String(string(redrivePolicy)),
+		sqs.QueueAttributeNameVisibilityTimeout: aws.String(fmt.Sprint(config.VisibilityTimeout)),
+	}
+
+	for priority := 0; priority <= maxPriority; priority++ {
+		if priority > 0 && !config.PriorityQueues {
+			break
+		}
+
+		name := priorityQueueName(queue, priority)
+		if _, err := c.createQueue(name, queueAttrs); err != nil {
+			return fmt.Errorf(libErrs.ErrorQueueDeclareFailure.Error(), name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *SQSClient) createQueue(queue string, attrs map[string]*string) (string, error) {
+	out, err := c.client.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  aws.String(queue),
+		Attributes: attrs,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	c.queueURLs[queue] = aws.StringValue(out.QueueUrl)
+	c.mutex.Unlock()
+
+	return aws.StringValue(out.QueueUrl), nil
+}
+
+// getQueueURL resolves the url of a queue, caching it for subsequent calls.
+func (c *SQSClient) getQueueURL(queue string) (string, error) {
+	if c.client == nil {
+		return "", libErrs.ErrorNoSQSClient
+	}
+
+	c.mutex.RLock()
+	url, exists := c.queueURLs[queue]
+	c.mutex.RUnlock()
+
+	if exists {
+		return url, nil
+	}
+
+	out, err := c.client.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	c.queueURLs[queue] = aws.StringValue(out.QueueUrl)
+	c.mutex.Unlock()
+
+	return aws.StringValue(out.QueueUrl), nil
+}
+
+// CloseConnection stops any active consumers. SQS is accessed over
+// stateless HTTP requests so there is no connection to tear down.
+func (c *SQSClient) CloseConnection() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+
+	c.queueURLs = make(map[string]string)
+
+	return nil
+}
+
+// ConnectionListener is a no-op for SQS as there is no long lived
+// connection to listen on. Consumers are notified via their callback
+// should receiving from a queue fail.
+func (c *SQSClient) ConnectionListener() {}
+
+// IsConnectionOpen reports if the client has been connected and not closed since.
+func (c *SQSClient) IsConnectionOpen() bool {
+	if c.client == nil {
+		return false
+	}
+
+	select {
+	case <-c.done():
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *SQSClient) done() <-chan struct{} {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.closed
+}
+
+// priorityQueueName maps a priority to its own queue. Priority none (0)
+// maps to the queue itself.
+func priorityQueueName(queue string, priority int) string {
+	if priority <= 0 {
+		return queue
+	}
+
+	if priority > maxPriority {
+		priority = maxPriority
+	}
+
+	return fmt.Sprintf("%s-priority-%d", queue, priority)
+}
+
+func deadletterQueueName(queue string) string {
+	return fmt.Sprintf("%s-%s", "deadletter", queue)
+}
@@ -0,0 +1,319 @@
+package sqs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQS an in-memory stand-in for the SQS api covering the calls made by SQSClient.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+
+	mutex    sync.Mutex
+	client   *SQSClient
+	created  map[string]map[string]*string
+	sent     map[string][]*sqs.SendMessageInput
+	received []*sqs.Message
+	deleted  []string
+
+	// queue url received is delivered from when long polled - any if empty
+	receivedFrom string
+
+	// errors receiving fails with, one per poll, before anything is received
+	receiveErrs []error
+
+	// every poll made
+	polls []*sqs.ReceiveMessageInput
+}
+
+func newFakeSQS() *fakeSQS {
+	return &fakeSQS{
+		created: make(map[string]map[string]*string),
+		sent:    make(map[string][]*sqs.SendMessageInput),
+	}
+}
+
+func (f *fakeSQS) CreateQueue(in *sqs.CreateQueueInput) (*sqs.CreateQueueOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.created[aws.StringValue(in.QueueName)] = in.Attributes
+	return &sqs.CreateQueueOutput{QueueUrl: aws.String("http://sqs/" + aws.StringValue(in.QueueName))}, nil
+}
+
+func (f *fakeSQS) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	return &sqs.GetQueueAttributesOutput{
+		Attributes: map[string]*string{sqs.QueueAttributeNameQueueArn: aws.String("arn:" + aws.StringValue(in.QueueUrl))},
+	}, nil
+}
+
+func (f *fakeSQS) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	return nil, errors.New("queue does not exist")
+}
+
+func (f *fakeSQS) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sent[aws.StringValue(in.QueueUrl)] = append(f.sent[aws.StringValue(in.QueueUrl)], in)
+	return &sqs.SendMessageOutput{MessageId: aws.String("1")}, nil
+}
+
+func (f *fakeSQS) SendMessageWithContext(_ aws.Context, in *sqs.SendMessageInput, _ ...request.Option) (*sqs.SendMessageOutput, error) {
+	return f.SendMessage(in)
+}
+
+func (f *fakeSQS) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.polls = append(f.polls, in)
+
+	if len(f.receiveErrs) > 0 {
+		err := f.receiveErrs[0]
+		f.receiveErrs = f.receiveErrs[1:]
+		return nil, err
+	}
+
+	longPoll := aws.Int64Value(in.WaitTimeSeconds) > 0
+	if f.receivedFrom != "" && (!longPoll || aws.StringValue(in.QueueUrl) != f.receivedFrom) {
+		return &sqs.ReceiveMessageOutput{}, nil
+	}
+
+	msgs := f.received
+	f.received = nil
+	if len(msgs) == 0 && longPoll {
+		// nothing left to deliver - stop consuming
+		_ = f.client.CloseConnection()
+	}
+
+	return &sqs.ReceiveMessageOutput{Messages: msgs}, nil
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(_ aws.Context, in *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	return f.ReceiveMessage(in)
+}
+
+func (f *fakeSQS) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.deleted = append(f.deleted, aws.StringValue(in.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func connectFakeClient(t *testing.T) (*SQSClient, *fakeSQS) {
+	os.Clearenv()
+	os.Setenv("CONSUMER_QUEUE_NAME", "catalog")
+	os.Setenv("AWS_REGION", "eu-west-1")
+
+	fake := newFakeSQS()
+	client := NewSQSClient()
+	client.client = fake
+	fake.client = client
+
+	assert.NoError(t, client.Connect())
+	return client, fake
+}
+
+func TestConnectionConfig(t *testing.T) {
+	os.Clearenv()
+	vars := map[string]string{
+		"AWS_REGION":            "eu-west-1",
+		"SQS_ENDPOINT":          "http://localhost:9324",
+		"CONSUMER_QUEUE_NAME":   "catalog",
+		"QUEUE_PREFETCH_COUNT":  "50",
+		"SQS_WAIT_TIME_SECONDS": "5",
+	}
+	for e, v := range vars {
+		os.Setenv(e, v)
+	}
+
+	cfg, err := initConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost:9324", cfg.Endpoint)
+	assert.Equal(t, maxNumberOfMessages, cfg.QueuePrefetchCount)
+	assert.Equal(t, 5, cfg.WaitTimeSeconds)
+	assert.Equal(t, defaultVisibilityTimeout, cfg.VisibilityTimeout)
+	assert.Equal(t, defaultMaxReceiveCount, cfg.MaxReceiveCount)
+
+	os.Clearenv()
+}
+
+func TestConnectNoQueues(t *testing.T) {
+	os.Clearenv()
+	config = Config{}
+
+	client := NewSQSClient()
+	client.client = newFakeSQS()
+	assert.Equal(t, libErrs.ErrorNoQueuesSpecified, client.Connect())
+}
+
+func TestConnectDeclaresQueues(t *testing.T) {
+	client, fake := connectFakeClient(t)
+
+	assert.True(t, client.IsConnectionOpen())
+	assert.Contains(t, fake.created, "deadletter-catalog")
+	assert.Contains(t, aws.StringValue(fake.created["catalog"][sqs.QueueAttributeNameRedrivePolicy]), "arn:http://sqs/deadletter-catalog")
+	assert.NotContains(t, fake.created, "catalog-priority-1")
+
+	assert.NoError(t, client.CloseConnection())
+	assert.False(t, client.IsConnectionOpen())
+}
+
+func TestPriorityQueueName(t *testing.T) {
+	assert.Equal(t, "catalog", priorityQueueName("catalog", 0))
+	assert.Equal(t, "catalog-priority-3", priorityQueueName("catalog", 3))
+	assert.Equal(t, "catalog-priority-5", priorityQueueName("catalog", 9))
+}
+
+func TestPublish(t *testing.T) {
+	client, fake := connectFakeClient(t)
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+
+	event := &base.EyewaEvent{ID: "1", Name: "product.created"}
+	client.Publish(context.Background(), "catalog", 0, event, func(ctx context.Context, e *base.EyewaEvent, err error) error {
+		assert.NoError(t, err)
+		return nil
+	}, wg)
+	wg.Wait()
+
+	sent := fake.sent["http://sqs/catalog"]
+	assert.Len(t, sent, 1)
+
+	var published base.EyewaEvent
+	assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(sent[0].MessageBody)), &published))
+	assert.Equal(t, "product.created", published.Name)
+}
+
+func TestConsume(t *testing.T) {
+	client, fake := connectFakeClient(t)
+
+	fake.received = []*sqs.Message{
+		{MessageId: aws.String("1"), ReceiptHandle: aws.String("ok"), Body: aws.String(`{"id":"1","name":"product.created"}`)},
+		{MessageId: aws.String("2"), ReceiptHandle: aws.String("fail"), Body: aws.String(`{"id":"2","name":"product.deleted"}`)},
+		{MessageId: aws.String("3"), ReceiptHandle: aws.String("garbage"), Body: aws.String(`not json`)},
+	}
+
+	var consumed []string
+	client.Consume("catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if event == nil {
+			return nil
+		}
+
+		consumed = append(consumed, event.ID)
+		if event.ID == "2" {
+			return fmt.Errorf("failed to persist")
+		}
+		return nil
+	})
+
+	assert.Equal(t, []string{"1", "2"}, consumed)
+	assert.ElementsMatch(t, []string{"ok", "fail"}, fake.deleted)
+
+	// the failed event is deadlettered with its error, the unparseable
+	// message is left for SQS to redrive.
+	deadlettered := fake.sent["http://sqs/deadletter-catalog"]
+	assert.Len(t, deadlettered, 1)
+
+	var event base.EyewaEvent
+	assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(deadlettered[0].MessageBody)), &event))
+	assert.Equal(t, "2", event.ID)
+	assert.Equal(t, "failed to persist", event.Errors[0].ErrorMessage)
+}
+
+func TestConsumeRetriesReceiving(t *testing.T) {
+	client, fake := connectFakeClient(t)
+
+	fake.receiveErrs = []error{awserr.New("ThrottlingException", "slow down", nil)}
+	fake.received = []*sqs.Message{
+		{MessageId: aws.String("1"), ReceiptHandle: aws.String("ok"), Body: aws.String(`{"id":"1","name":"product.created"}`)},
+	}
+
+	var consumed []string
+	var errs []error
+	client.Consume("catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+
+		consumed = append(consumed, event.ID)
+		return nil
+	})
+
+	// received once retried, consuming only stopped by closing the client
+	assert.Equal(t, []string{"1"}, consumed)
+	assert.Equal(t, []error{libErrs.ErrorLostConnectionToMessageBroker}, errs)
+}
+
+func TestConsumeStopsOnPermanentFailure(t *testing.T) {
+	client, fake := connectFakeClient(t)
+
+	fake.receiveErrs = []error{awserr.New(sqs.ErrCodeQueueDoesNotExist, "queue does not exist", nil)}
+
+	var errs []error
+	client.Consume("catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		errs = append(errs, err)
+		return nil
+	})
+
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), "Failed to consume from queue(catalog).")
+		assert.Equal(t, libErrs.ErrorLostConnectionToMessageBroker, errs[1])
+	}
+	assert.Len(t, fake.polls, 1)
+}
+
+func TestConsumeLongPollsPriorityQueues(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("CONSUMER_QUEUE_NAME", "catalog")
+	os.Setenv("AWS_REGION", "eu-west-1")
+	os.Setenv("SQS_PRIORITY_QUEUES", "true")
+
+	fake := newFakeSQS()
+	client := NewSQSClient()
+	client.client = fake
+	fake.client = client
+	assert.NoError(t, client.Connect())
+
+	// only yields a message once long polled
+	fake.receivedFrom = "http://sqs/catalog-priority-5"
+	fake.received = []*sqs.Message{
+		{MessageId: aws.String("1"), ReceiptHandle: aws.String("ok"), Body: aws.String(`{"id":"1","name":"product.created"}`)},
+	}
+
+	var consumed []string
+	client.Consume("catalog", func(ctx context.Context, event *base.EyewaEvent, err error) error {
+		if event != nil {
+			consumed = append(consumed, event.ID)
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"1"}, consumed)
+
+	longPolled := make(map[string]bool)
+	for _, poll := range fake.polls {
+		if aws.Int64Value(poll.WaitTimeSeconds) > 0 {
+			longPolled[aws.StringValue(poll.QueueUrl)] = true
+		}
+	}
+	assert.True(t, longPolled["http://sqs/catalog-priority-5"])
+	assert.True(t, longPolled["http://sqs/catalog"])
+}
@@ -0,0 +1,61 @@
+package sqs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/eyewa/eyewa-go-lib/base"
+)
+
+// Config for all SQS env vars
+type Config struct {
+	MessageBroker string `mapstructure:"message_broker"`
+
+	// AWS region the queues live in
+	Region string `mapstructure:"aws_region"`
+
+	// optional - overrides the SQS endpoint e.g. for a local
+	// SQS-compatible stand-in such as elasticmq or localstack
+	Endpoint string `mapstructure:"sqs_endpoint"`
+
+	// No. of messages to receive per poll - SQS caps this at 10
+	QueuePrefetchCount int `mapstructure:"queue_prefetch_count"`
+
+	// Queues for consuming + publishing
+	PublisherQueueName string `mapstructure:"publisher_queue_name"`
+	ConsumerQueueName  string `mapstructure:"consumer_queue_name"`
+
+	// How long a receive call waits for messages to arrive (long polling)
+	WaitTimeSeconds int `mapstructure:"sqs_wait_time_seconds"`
+
+	// How long a received message stays hidden from other consumers
+	// before it is redelivered if not deleted (acked)
+	VisibilityTimeout int `mapstructure:"sqs_visibility_timeout"`
+
+	// No. of receives after which SQS redrives a message to its deadletter queue
+	MaxReceiveCount int `mapstructure:"sqs_max_receive_count"`
+
+	// If true, each priority level is mapped to its own queue
+	PriorityQueues bool `mapstructure:"sqs_priority_queues"`
+
+	// Purely for identifying what service/service instance is consuming
+	ServiceName string `mapstructure:"service_name"`
+}
+
+// SQSClient SQS client for implementing the MessageBroker interface and handling all things SQS.
+type SQSClient struct {
+	mutex *sync.RWMutex
+
+	client sqsiface.SQSAPI
+
+	// Map of queue urls for all declared queues
+	queueURLs map[string]string
+
+	// closed once CloseConnection is called to stop consuming
+	closed chan struct{}
+}
+
+// processFunc is invoked for every delivery received. It returns the name
+// of the event for metrics and an error if the message should be deadlettered.
+type processFunc func(ctx context.Context, delivery base.Delivery) (string, error)
@@ -5,7 +5,6 @@ import (
 	"sync"
 
 	"github.com/eyewa/eyewa-go-lib/base"
-	"github.com/streadway/amqp"
 )
 
 // Queue priorities for declaring queues and publishing
@@ -63,7 +62,7 @@ type Consumer interface {
 	Connect() error
 	CloseConnection() error
 	Consume(queue string, callback base.MessageBrokerCallbackFunc)
-	SendToDeadletterQueue(amqp.Delivery, error) error
+	SendToDeadletterQueue(base.Delivery, error) error
 	ConsumeMagentoProductEvents(queue string, callback base.MessageBrokerMagentoProductCallbackFunc)
 }
 
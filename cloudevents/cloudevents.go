@@ -0,0 +1,286 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+)
+
+// Extension attributes the fields of events without a CloudEvents counterpart are mapped to
+const (
+	ExtensionEventType    = "eventtype"
+	ExtensionEventSubType = "eventsubtype"
+	ExtensionStoreCode    = "storecode"
+	ExtensionStoreID      = "storeid"
+	ExtensionWebsiteID    = "websiteid"
+	ExtensionIsMigration  = "ismigration"
+	ExtensionErrors       = "errors" // JSON list of base.Error
+)
+
+// From maps an event - a *base.EyewaEvent or *base.MagentoProductEvent - onto a
+// cloud event produced by source.
+func From(event interface{}, source string) (Event, error) {
+	switch e := event.(type) {
+	case *base.EyewaEvent:
+		return FromEyewaEvent(e, source), nil
+	case *base.MagentoProductEvent:
+		return FromMagentoProductEvent(e, source), nil
+	}
+
+	return Event{}, fmt.Errorf(libErrs.ErrorUnsupportedCloudEventType.Error(), event)
+}
+
+// To maps a cloud event onto event - a *base.EyewaEvent or *base.MagentoProductEvent.
+func To(ce Event, event interface{}) error {
+	switch e := event.(type) {
+	case *base.EyewaEvent:
+		mapped, err := ToEyewaEvent(ce)
+		if err != nil {
+			return err
+		}
+		*e = *mapped
+		return nil
+	case *base.MagentoProductEvent:
+		mapped, err := ToMagentoProductEvent(ce)
+		if err != nil {
+			return err
+		}
+		*e = *mapped
+		return nil
+	}
+
+	return fmt.Errorf(libErrs.ErrorUnsupportedCloudEventType.Error(), event)
+}
+
+// FromEyewaEvent maps an EyewaEvent onto a cloud event produced by source. The
+// event's payload is the data of the cloud event.
+func FromEyewaEvent(event *base.EyewaEvent, source string) Event {
+	ce := Event{
+		SpecVersion: SpecVersion,
+		ID:          event.ID,
+		Source:      source,
+		Type:        event.Name,
+		Time:        event.CreatedAt,
+		Extensions:  make(map[string]interface{}),
+	}
+
+	if len(event.Payload) > 0 {
+		ce.DataContentType = "application/json"
+		ce.Data = event.Payload
+	}
+
+	ce.setExtension(ExtensionEventType, event.EventType)
+	ce.setExtension(ExtensionEventSubType, event.EventSubType)
+	ce.setExtension(ExtensionStoreCode, event.StoreCode)
+	ce.setErrors(event.Errors)
+
+	return ce
+}
+
+// ToEyewaEvent maps a cloud event onto an EyewaEvent. The data of the cloud
+// event has to be JSON, as it is the event's payload.
+func ToEyewaEvent(ce Event) (*base.EyewaEvent, error) {
+	if err := ce.Validate(); err != nil {
+		return nil, err
+	}
+
+	event := &base.EyewaEvent{
+		ID:           ce.ID,
+		Name:         ce.Type,
+		EventType:    ce.stringExtension(ExtensionEventType),
+		EventSubType: ce.stringExtension(ExtensionEventSubType),
+		StoreCode:    ce.stringExtension(ExtensionStoreCode),
+		CreatedAt:    ce.Time,
+	}
+
+	if len(ce.Data) > 0 {
+		if !json.Valid(ce.Data) {
+			return nil, fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), "data isn't JSON")
+		}
+		event.Payload = json.RawMessage(ce.Data)
+	}
+
+	errs, err := ce.errors()
+	if err != nil {
+		return nil, err
+	}
+	event.Errors = errs
+
+	return event, nil
+}
+
+// FromMagentoProductEvent maps a MagentoProductEvent onto a cloud event produced
+// by source. The product's entity ID is the subject of the cloud event, which
+// has no data.
+func FromMagentoProductEvent(event *base.MagentoProductEvent, source string) Event {
+	ce := Event{
+		SpecVersion: SpecVersion,
+		ID:          event.ID,
+		Source:      source,
+		Type:        event.Name,
+		Subject:     strconv.Itoa(event.EntityID),
+		Time:        event.CreatedAt,
+		Extensions: map[string]interface{}{
+			ExtensionStoreID:   int32(event.StoreID),
+			ExtensionWebsiteID: int32(event.WebsiteID),
+		},
+	}
+
+	ce.setExtension(ExtensionEventType, event.EventType)
+	ce.setExtension(ExtensionEventSubType, event.EventSubType)
+	ce.setExtension(ExtensionStoreCode, event.StoreCode)
+	if event.IsMigration {
+		ce.Extensions[ExtensionIsMigration] = true
+	}
+	ce.setErrors(event.Errors)
+
+	return ce
+}
+
+// ToMagentoProductEvent maps a cloud event onto a MagentoProductEvent.
+func ToMagentoProductEvent(ce Event) (*base.MagentoProductEvent, error) {
+	if err := ce.Validate(); err != nil {
+		return nil, err
+	}
+
+	event := &base.MagentoProductEvent{
+		ID:           ce.ID,
+		Name:         ce.Type,
+		EventType:    ce.stringExtension(ExtensionEventType),
+		EventSubType: ce.stringExtension(ExtensionEventSubType),
+		StoreCode:    ce.stringExtension(ExtensionStoreCode),
+		CreatedAt:    ce.Time,
+	}
+
+	var err error
+	if ce.Subject != "" {
+		if event.EntityID, err = strconv.Atoi(ce.Subject); err != nil {
+			return nil, fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), fmt.Sprintf("subject %s isn't an entity ID", ce.Subject))
+		}
+	}
+
+	if event.StoreID, err = ce.intExtension(ExtensionStoreID); err != nil {
+		return nil, err
+	}
+	if event.WebsiteID, err = ce.intExtension(ExtensionWebsiteID); err != nil {
+		return nil, err
+	}
+	if event.IsMigration, err = ce.boolExtension(ExtensionIsMigration); err != nil {
+		return nil, err
+	}
+	if event.Errors, err = ce.errors(); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// Validate checks the event has the attributes required by the spec.
+func (e Event) Validate() error {
+	if e.SpecVersion != SpecVersion {
+		return fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), fmt.Sprintf("unsupported specversion %s", e.SpecVersion))
+	}
+
+	switch "" {
+	case e.ID:
+		return fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), "id is required")
+	case e.Source:
+		return fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), "source is required")
+	case e.Type:
+		return fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), "type is required")
+	}
+
+	return nil
+}
+
+// setExtension sets an extension attribute - unless value is empty.
+func (e *Event) setExtension(name, value string) {
+	if value != "" {
+		e.Extensions[name] = value
+	}
+}
+
+// setErrors records errs in the errors extension attribute.
+func (e *Event) setErrors(errs []base.Error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	if data, err := json.Marshal(errs); err == nil {
+		e.Extensions[ExtensionErrors] = string(data)
+	}
+}
+
+// errors the errors recorded in the errors extension attribute.
+func (e Event) errors() ([]base.Error, error) {
+	data := e.stringExtension(ExtensionErrors)
+	if data == "" {
+		return nil, nil
+	}
+
+	var errs []base.Error
+	if err := json.Unmarshal([]byte(data), &errs); err != nil {
+		return nil, fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), fmt.Sprintf("errors extension. %s", err))
+	}
+
+	return errs, nil
+}
+
+// stringExtension the value of an extension attribute as a string.
+func (e Event) stringExtension(name string) string {
+	switch v := e.Extensions[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// intExtension the value of an integer extension attribute - 0 if not set.
+// Integers are decoded from JSON as float64 and may be carried as strings by
+// some protocols.
+func (e Event) intExtension(name string) (int, error) {
+	switch v := e.Extensions[name].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case int16:
+		return int(v), nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), fmt.Sprintf("%s isn't an integer", name))
+}
+
+// boolExtension the value of a boolean extension attribute - false if not set.
+func (e Event) boolExtension(name string) (bool, error) {
+	switch v := e.Extensions[name].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+
+	return false, fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), fmt.Sprintf("%s isn't a boolean", name))
+}
@@ -0,0 +1,182 @@
+package cloudevents
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	eyewaEvent = &base.EyewaEvent{
+		ID:           "1",
+		Name:         "product.updated",
+		EventType:    "Product",
+		EventSubType: "product-simple",
+		StoreCode:    "ae-en",
+		Errors:       []base.Error{{ErrorCode: 500, ErrorMessage: "failed", CreatedAt: "2021-01-01T00:00:00Z"}},
+		Payload:      json.RawMessage(`{"sku":"123"}`),
+		CreatedAt:    "2021-01-01T00:00:00Z",
+	}
+
+	magentoEvent = &base.MagentoProductEvent{
+		ID:           "2",
+		Name:         "product.created",
+		EntityID:     42,
+		EventType:    "Product",
+		EventSubType: "product-configurable",
+		StoreID:      3,
+		StoreCode:    "sa-ar",
+		WebsiteID:    4,
+		CreatedAt:    "2021-01-01T00:00:00Z",
+		IsMigration:  true,
+	}
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  interface{}
+		mapped interface{}
+	}{
+		{"EyewaEvent", eyewaEvent, new(base.EyewaEvent)},
+		{"MagentoProductEvent", magentoEvent, new(base.MagentoProductEvent)},
+	}
+
+	for _, test := range tests {
+		for _, mode := range []Mode{Structured, Binary} {
+			t.Run(test.name+" "+string(mode), func(t *testing.T) {
+				ce, err := From(test.event, "catalogconsumer")
+				assert.Nil(t, err)
+
+				msg, err := ce.Encode(mode)
+				assert.Nil(t, err)
+
+				decoded, decodedMode, err := Decode(msg)
+				assert.Nil(t, err)
+				assert.Equal(t, mode, decodedMode)
+				assert.Equal(t, "catalogconsumer", decoded.Source)
+
+				assert.Nil(t, To(decoded, test.mapped))
+				assert.Equal(t, test.event, test.mapped)
+			})
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	ce := FromEyewaEvent(eyewaEvent, "catalogconsumer")
+
+	msg, err := ce.Encode(Structured)
+	assert.Nil(t, err)
+	assert.Equal(t, ContentType, msg.ContentType)
+	assert.Nil(t, msg.Headers)
+	assert.JSONEq(t, `{
+		"specversion": "1.0",
+		"id": "1",
+		"source": "catalogconsumer",
+		"type": "product.updated",
+		"time": "2021-01-01T00:00:00Z",
+		"datacontenttype": "application/json",
+		"eventtype": "Product",
+		"eventsubtype": "product-simple",
+		"storecode": "ae-en",
+		"errors": "[{\"error_code\":500,\"error_message\":\"failed\",\"created_at\":\"2021-01-01T00:00:00Z\"}]",
+		"data": {"sku": "123"}
+	}`, string(msg.Body))
+
+	msg, err = ce.Encode(Binary)
+	assert.Nil(t, err)
+	assert.Equal(t, "application/json", msg.ContentType)
+	assert.Equal(t, []byte(`{"sku":"123"}`), msg.Body)
+	assert.Equal(t, "1.0", msg.Headers["cloudEvents:specversion"])
+	assert.Equal(t, "product.updated", msg.Headers["cloudEvents:type"])
+	assert.Equal(t, "ae-en", msg.Headers["cloudEvents:storecode"])
+	assert.NotContains(t, msg.Headers, "cloudEvents:datacontenttype")
+	assert.NotContains(t, msg.Headers, "cloudEvents:subject")
+
+	_, err = ce.Encode("batched")
+	assert.NotNil(t, err)
+}
+
+func TestDecode(t *testing.T) {
+	// not a cloud event
+	_, mode, err := Decode(Message{ContentType: "application/json", Body: []byte(`{"id":"1"}`)})
+	assert.Nil(t, err)
+	assert.Equal(t, Mode(""), mode)
+
+	// structured with a charset + binary data
+	ce, mode, err := Decode(Message{
+		ContentType: "application/cloudevents+json; charset=utf-8",
+		Body:        []byte(`{"specversion":"1.0","id":"1","source":"/erp","type":"stock.updated","datacontenttype":"text/plain","data_base64":"aW4gc3RvY2s=","storeid":3}`),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, Structured, mode)
+	assert.Equal(t, []byte("in stock"), ce.Data)
+	assert.Equal(t, map[string]interface{}{"storeid": float64(3)}, ce.Extensions)
+
+	// binary with underscore prefixes + an AMQP timestamp
+	ce, mode, err = Decode(Message{
+		ContentType: "application/json",
+		Headers: map[string]interface{}{
+			"cloudEvents_specversion": "1.0",
+			"cloudEvents_id":          "1",
+			"cloudEvents_source":      "/erp",
+			"cloudEvents_type":        "stock.updated",
+			"cloudEvents_time":        time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			"cloudEvents_storeid":     int32(3),
+			"traceparent":             "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		},
+		Body: []byte(`{}`),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, Binary, mode)
+	assert.Equal(t, "2021-01-01T00:00:00Z", ce.Time)
+	assert.Equal(t, "application/json", ce.DataContentType)
+	assert.Equal(t, map[string]interface{}{"storeid": int32(3)}, ce.Extensions)
+
+	// missing required attributes
+	_, _, err = Decode(Message{ContentType: ContentType, Body: []byte(`{"specversion":"1.0","id":"1"}`)})
+	assert.EqualError(t, err, "Invalid cloud event. source is required")
+
+	_, _, err = Decode(Message{ContentType: ContentType, Body: []byte(`not json`)})
+	assert.NotNil(t, err)
+}
+
+func TestTo(t *testing.T) {
+	ce := Event{SpecVersion: SpecVersion, ID: "1", Source: "/erp", Type: "stock.updated", Data: []byte("in stock")}
+
+	_, err := ToEyewaEvent(ce)
+	assert.EqualError(t, err, "Invalid cloud event. data isn't JSON")
+
+	ce.Subject = "sku-123"
+	_, err = ToMagentoProductEvent(ce)
+	assert.EqualError(t, err, "Invalid cloud event. subject sku-123 isn't an entity ID")
+
+	ce.Subject = "123"
+	ce.Extensions = map[string]interface{}{"storeid": "3", "ismigration": "true"}
+	event, err := ToMagentoProductEvent(ce)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, event.StoreID)
+	assert.True(t, event.IsMigration)
+
+	ce.SpecVersion = "0.3"
+	_, err = ToEyewaEvent(ce)
+	assert.EqualError(t, err, "Invalid cloud event. unsupported specversion 0.3")
+
+	var unsupported struct{}
+	assert.NotNil(t, To(ce, &unsupported))
+	_, err = From(&unsupported, "/erp")
+	assert.EqualError(t, err, "Events of type *struct {} can't be mapped to cloud events.")
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("Binary")
+	assert.Nil(t, err)
+	assert.Equal(t, Binary, mode)
+
+	_, err = ParseMode("batched")
+	assert.EqualError(t, err, "Unknown cloud events mode batched.")
+}
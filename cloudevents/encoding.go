@@ -0,0 +1,246 @@
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+)
+
+// ParseMode parses a mode - structured or binary.
+func ParseMode(mode string) (Mode, error) {
+	switch m := Mode(strings.ToLower(mode)); m {
+	case Structured, Binary:
+		return m, nil
+	}
+
+	return "", fmt.Errorf(libErrs.ErrorUnknownCloudEventsMode.Error(), mode)
+}
+
+// Encode encodes the event into a message carrying it in mode. In structured
+// mode the body is the event encoded as JSON. In binary mode the body is the
+// event's data and its attributes are carried by headers prefixed with HeaderPrefix.
+func (e Event) Encode(mode Mode) (Message, error) {
+	switch mode {
+	case Structured:
+		body, err := json.Marshal(e)
+		if err != nil {
+			return Message{}, err
+		}
+
+		return Message{ContentType: ContentType, Body: body}, nil
+	case Binary:
+		headers := make(map[string]interface{}, len(e.Extensions)+6)
+		for name, value := range e.attributes() {
+			// carried by the content type of the message instead
+			if name != "datacontenttype" && *value != "" {
+				headers[HeaderPrefix+name] = *value
+			}
+		}
+		for name, value := range e.Extensions {
+			headers[HeaderPrefix+name] = value
+		}
+
+		return Message{ContentType: e.DataContentType, Headers: headers, Body: e.Data}, nil
+	}
+
+	return Message{}, fmt.Errorf(libErrs.ErrorUnknownCloudEventsMode.Error(), mode)
+}
+
+// Decode decodes the event carried by a message in either mode, returning the
+// mode it was carried in. The mode is empty if the message carries no event.
+func Decode(msg Message) (Event, Mode, error) {
+	var (
+		e    Event
+		mode Mode
+		err  error
+	)
+
+	switch {
+	case isStructured(msg.ContentType):
+		mode = Structured
+		err = json.Unmarshal(msg.Body, &e)
+	case isBinary(msg.Headers):
+		mode = Binary
+		e = decodeBinary(msg)
+	default:
+		return e, "", nil
+	}
+
+	if err != nil {
+		return e, mode, fmt.Errorf(libErrs.ErrorInvalidCloudEvent.Error(), err)
+	}
+
+	return e, mode, e.Validate()
+}
+
+// MarshalJSON encodes the event as JSON - its extension attributes alongside the
+// spec's. JSON data is embedded as is, any other data base64 encoded.
+func (e Event) MarshalJSON() ([]byte, error) {
+	attrs := make(map[string]interface{}, len(e.Extensions)+8)
+	for name, value := range e.Extensions {
+		attrs[name] = value
+	}
+
+	for name, value := range e.attributes() {
+		if *value != "" {
+			attrs[name] = *value
+		}
+	}
+
+	if len(e.Data) > 0 {
+		if isJSON(e.DataContentType) && json.Valid(e.Data) {
+			attrs["data"] = json.RawMessage(e.Data)
+		} else {
+			attrs["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+		}
+	}
+
+	return json.Marshal(attrs)
+}
+
+// UnmarshalJSON decodes an event encoded as JSON. Attributes not in the spec
+// are decoded as extension attributes.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return err
+	}
+
+	*e = Event{}
+	known := e.attributes()
+
+	for name, raw := range attrs {
+		if value, ok := known[name]; ok {
+			if err := json.Unmarshal(raw, value); err != nil {
+				return fmt.Errorf("%s. %s", name, err)
+			}
+			continue
+		}
+
+		switch name {
+		case "data":
+			if string(raw) != "null" {
+				e.Data = append([]byte(nil), raw...)
+			}
+		case "data_base64":
+			var encoded string
+			if err := json.Unmarshal(raw, &encoded); err != nil {
+				return fmt.Errorf("%s. %s", name, err)
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("%s. %s", name, err)
+			}
+			e.Data = decoded
+		default:
+			var value interface{}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("%s. %s", name, err)
+			}
+
+			if e.Extensions == nil {
+				e.Extensions = make(map[string]interface{})
+			}
+			e.Extensions[name] = value
+		}
+	}
+
+	return nil
+}
+
+// attributes the attributes defined by the spec, by name.
+func (e *Event) attributes() map[string]*string {
+	return map[string]*string{
+		"specversion":     &e.SpecVersion,
+		"id":              &e.ID,
+		"source":          &e.Source,
+		"type":            &e.Type,
+		"subject":         &e.Subject,
+		"time":            &e.Time,
+		"datacontenttype": &e.DataContentType,
+	}
+}
+
+// decodeBinary decodes an event carried in binary mode.
+func decodeBinary(msg Message) Event {
+	e := Event{
+		DataContentType: msg.ContentType,
+		Data:            msg.Body,
+	}
+	known := e.attributes()
+
+	for header, value := range msg.Headers {
+		name, ok := attributeName(header)
+		if !ok {
+			continue
+		}
+
+		if attr, ok := known[name]; ok {
+			switch v := value.(type) {
+			case string:
+				*attr = v
+			case time.Time:
+				*attr = v.UTC().Format(time.RFC3339)
+			default:
+				*attr = fmt.Sprint(v)
+			}
+			continue
+		}
+
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]interface{})
+		}
+		e.Extensions[name] = value
+	}
+
+	// the content type of the message takes precedence
+	if msg.ContentType != "" {
+		e.DataContentType = msg.ContentType
+	}
+
+	return e
+}
+
+// attributeName the name of the attribute a header carries - if any.
+func attributeName(header string) (string, bool) {
+	for _, prefix := range []string{HeaderPrefix, HeaderPrefixUnderscore} {
+		if strings.HasPrefix(header, prefix) {
+			return strings.TrimPrefix(header, prefix), true
+		}
+	}
+
+	return "", false
+}
+
+// isStructured if a message with contentType carries an event in structured mode.
+func isStructured(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == ContentType
+}
+
+// isBinary if a message with headers carries an event in binary mode.
+func isBinary(headers map[string]interface{}) bool {
+	for _, prefix := range []string{HeaderPrefix, HeaderPrefixUnderscore} {
+		if _, ok := headers[prefix+"specversion"]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isJSON if data of contentType is JSON e.g application/json or application/vnd.eyewa+json.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,50 @@
+package cloudevents
+
+const (
+	// SpecVersion version of the CloudEvents spec events conform to
+	SpecVersion = "1.0"
+
+	// ContentType content type of messages carrying events in structured mode
+	ContentType = "application/cloudevents+json"
+
+	// HeaderPrefix prefix of the headers (application properties) carrying the
+	// attributes of events in binary mode. Headers prefixed with
+	// HeaderPrefixUnderscore are accepted too.
+	HeaderPrefix           = "cloudEvents:"
+	HeaderPrefixUnderscore = "cloudEvents_"
+)
+
+// Mode how an event is carried by a message.
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/bindings/amqp-protocol-binding.md
+type Mode string
+
+const (
+	// Structured the event is carried by the body, encoded as JSON along with its data
+	Structured Mode = "structured"
+	// Binary the event's attributes are carried by headers and its data by the body
+	Binary Mode = "binary"
+)
+
+// Event a CloudEvents 1.0 event.
+type Event struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"` // URI-reference of what produced the event e.g catalogconsumer
+	Type            string `json:"type"`   // name of event - product.created, product.updated etc
+	Subject         string `json:"subject,omitempty"`
+	Time            string `json:"time,omitempty"` // time in RFC3339 format
+	DataContentType string `json:"datacontenttype,omitempty"`
+
+	// Attributes beyond the spec's e.g storecode - strings, integers or booleans
+	Extensions map[string]interface{} `json:"-"`
+
+	Data []byte `json:"-"` // encoded as DataContentType e.g JSON
+}
+
+// Message a message carrying an event - the body along with the headers and
+// content type it is published with.
+type Message struct {
+	ContentType string
+	Headers     map[string]interface{}
+	Body        []byte
+}
@@ -0,0 +1,80 @@
+// Command deadletter-replay lists and replays events deadlettered by a
+// RabbitMQ consumer back to the queue they were consumed from.
+//
+// RMQ credentials are read from the same env vars the rabbitmq pkg uses
+// i.e RABBITMQ_SERVER, RABBITMQ_AMQP_PORT, RABBITMQ_USERNAME etc.
+//
+//	deadletter-replay -queue eyewacatalog -name product.created -error "timeout" -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/eyewa/eyewa-go-lib/brokers/rabbitmq"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run lists/replays the events and returns the exit code - so deferred calls
+// e.g closing the connection run before exiting.
+func run() int {
+	var (
+		queue = flag.String("queue", os.Getenv("CONSUMER_QUEUE_NAME"), "queue whose deadletter queue to replay - defaults to CONSUMER_QUEUE_NAME")
+		opts  rabbitmq.ReplayOptions
+	)
+
+	flag.StringVar(&opts.Filter.Name, "name", "", "only events with this name")
+	flag.StringVar(&opts.Filter.EventType, "event-type", "", "only events of this type")
+	flag.StringVar(&opts.Filter.StoreCode, "store-code", "", "only events for this store")
+	flag.StringVar(&opts.Filter.ErrorText, "error", "", "only events with an error containing this text")
+	flag.IntVar(&opts.Limit, "limit", 0, "max no. of deadlettered messages to look at - 0 for all")
+	flag.BoolVar(&opts.RetainErrors, "retain-errors", false, "keep the errors of replayed events")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "only list matching events")
+	flag.Float64Var(&opts.Rate, "rate", 0, "max no. of events replayed per second - 0 for no limit")
+	flag.Parse()
+
+	if *queue == "" {
+		fmt.Fprintln(os.Stderr, "a -queue is required")
+		flag.Usage()
+		return 2
+	}
+
+	client := rabbitmq.NewRMQClient()
+	if err := client.ConnectWithoutQueues(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer client.CloseConnection()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := client.ReplayDeadletterEvents(ctx, *queue, opts)
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, event := range result.Matched {
+		_ = enc.Encode(map[string]interface{}{
+			"id":         event.ID,
+			"name":       event.Name,
+			"event_type": event.EventType,
+			"store_code": event.StoreCode,
+			"errors":     event.Errors,
+		})
+	}
+
+	fmt.Fprintf(os.Stderr, "inspected: %d matched: %d replayed: %d\n", result.Inspected, len(result.Matched), result.Replayed)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,137 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/hamba/avro"
+)
+
+// avroErrorSchema the schema of base.Error - shared by the event schemas.
+const avroErrorSchema = `{
+	"type": "record",
+	"name": "Error",
+	"fields": [
+		{"name": "error_code", "type": "int"},
+		{"name": "error_message", "type": "string"},
+		{"name": "created_at", "type": "string"}
+	]
+}`
+
+// EyewaEventAvroSchema the Avro schema of base.EyewaEvent. The payload is
+// carried as is - JSON - in bytes.
+const EyewaEventAvroSchema = `{
+	"type": "record",
+	"name": "EyewaEvent",
+	"namespace": "com.eyewa.events",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "name", "type": "string"},
+		{"name": "event_type", "type": "string"},
+		{"name": "store_code", "type": "string", "default": ""},
+		{"name": "event_subtype", "type": "string", "default": ""},
+		{"name": "errors", "type": {"type": "array", "items": ` + avroErrorSchema + `}, "default": []},
+		{"name": "payload", "type": "bytes"},
+		{"name": "created_at", "type": "string"}
+	]
+}`
+
+// MagentoProductEventAvroSchema the Avro schema of base.MagentoProductEvent.
+const MagentoProductEventAvroSchema = `{
+	"type": "record",
+	"name": "MagentoProductEvent",
+	"namespace": "com.eyewa.events",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "event", "type": "string"},
+		{"name": "entity_id", "type": "int"},
+		{"name": "event_type", "type": "string"},
+		{"name": "event_subtype", "type": "string"},
+		{"name": "store_id", "type": "int"},
+		{"name": "store_code", "type": "string"},
+		{"name": "website_id", "type": "int"},
+		{"name": "errors", "type": {"type": "array", "items": ` + avroErrorSchema + `}, "default": []},
+		{"name": "created_at", "type": "string"},
+		{"name": "is_migration", "type": "boolean", "default": false}
+	]
+}`
+
+// avroSchemas the Avro schemas of event types, keyed by type.
+var avroSchemas = struct {
+	sync.RWMutex
+	schemas map[reflect.Type]avro.Schema
+}{schemas: make(map[reflect.Type]avro.Schema)}
+
+// Avro encodes events as Avro binary. Events are encoded with the schema
+// registered for their type - see RegisterAvroSchema. Schemas for
+// base.EyewaEvent and base.MagentoProductEvent are registered by default.
+// Fields are matched by their avro tags.
+type Avro struct{}
+
+func init() {
+	RegisterAvroSchema(base.EyewaEvent{}, EyewaEventAvroSchema)
+	RegisterAvroSchema(base.MagentoProductEvent{}, MagentoProductEventAvroSchema)
+}
+
+// RegisterAvroSchema registers the Avro schema events of the type of v are
+// encoded with - replacing any schema registered for it before. Panics if the
+// schema is invalid.
+func RegisterAvroSchema(v interface{}, schema string) {
+	parsed := avro.MustParse(schema)
+
+	avroSchemas.Lock()
+	defer avroSchemas.Unlock()
+
+	avroSchemas.schemas[indirectType(v)] = parsed
+}
+
+// ContentType avro/binary
+func (Avro) ContentType() string {
+	return "avro/binary"
+}
+
+// Marshal encodes v as Avro binary
+func (c Avro) Marshal(v interface{}) ([]byte, error) {
+	schema, err := c.schema(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return avro.Marshal(schema, v)
+}
+
+// Unmarshal decodes Avro binary data into v
+func (c Avro) Unmarshal(data []byte, v interface{}) error {
+	schema, err := c.schema(v)
+	if err != nil {
+		return err
+	}
+
+	return avro.Unmarshal(schema, data, v)
+}
+
+// schema the schema registered for the type of v.
+func (c Avro) schema(v interface{}) (avro.Schema, error) {
+	avroSchemas.RLock()
+	defer avroSchemas.RUnlock()
+
+	schema, ok := avroSchemas.schemas[indirectType(v)]
+	if !ok {
+		return nil, fmt.Errorf(libErrs.ErrorUnsupportedCodecType.Error(), c.ContentType(), v)
+	}
+
+	return schema, nil
+}
+
+// indirectType the type of v - or of what it points to.
+func indirectType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
@@ -0,0 +1,29 @@
+package codec
+
+import "encoding/json"
+
+// Codec encodes events into message bodies and decodes them back.
+type Codec interface {
+	// ContentType the MIME type of encoded bodies e.g application/json
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSON encodes events as JSON.
+type JSON struct{}
+
+// ContentType application/json
+func (JSON) ContentType() string {
+	return "application/json"
+}
+
+// Marshal encodes v as JSON
+func (JSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v
+func (JSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
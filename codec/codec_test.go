@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/eyewa/eyewa-go-lib/base"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	var c Codec = JSON{}
+	assert.Equal(t, "application/json", c.ContentType())
+
+	data, err := c.Marshal(&base.EyewaEvent{ID: "1", Name: "product.created"})
+	assert.NoError(t, err)
+
+	var event base.EyewaEvent
+	assert.NoError(t, c.Unmarshal(data, &event))
+	assert.Equal(t, "product.created", event.Name)
+
+	assert.Error(t, c.Unmarshal([]byte("not json"), &event))
+}
+
+func TestCodecs(t *testing.T) {
+	eyewaEvent := &base.EyewaEvent{
+		ID:        "1",
+		Name:      "product.created",
+		EventType: "Product",
+		StoreCode: "ae-en",
+		Errors:    []base.Error{{ErrorCode: 500, ErrorMessage: "failed", CreatedAt: "2021-01-01T00:00:00Z"}},
+		Payload:   json.RawMessage(`{"sku":"123"}`),
+		CreatedAt: "2021-01-01T00:00:00Z",
+	}
+
+	magentoEvent := &base.MagentoProductEvent{
+		ID:          "2",
+		Name:        "product.updated",
+		EntityID:    42,
+		EventType:   "Product",
+		StoreID:     3,
+		StoreCode:   "sa-ar",
+		WebsiteID:   4,
+		Errors:      []base.Error{},
+		CreatedAt:   "2021-01-01T00:00:00Z",
+		IsMigration: true,
+	}
+
+	tests := []struct {
+		codec       Codec
+		contentType string
+	}{
+		{JSON{}, "application/json"},
+		{MsgPack{}, "application/msgpack"},
+		{Avro{}, "avro/binary"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.contentType, func(t *testing.T) {
+			assert.Equal(t, test.contentType, test.codec.ContentType())
+
+			data, err := test.codec.Marshal(eyewaEvent)
+			assert.NoError(t, err)
+
+			var decoded base.EyewaEvent
+			assert.NoError(t, test.codec.Unmarshal(data, &decoded))
+			assert.Equal(t, *eyewaEvent, decoded)
+
+			data, err = test.codec.Marshal(magentoEvent)
+			assert.NoError(t, err)
+
+			decodedMagento := base.MagentoProductEvent{Errors: []base.Error{}}
+			assert.NoError(t, test.codec.Unmarshal(data, &decodedMagento))
+			assert.Equal(t, *magentoEvent, decodedMagento)
+		})
+	}
+}
+
+func TestAvroUnregisteredType(t *testing.T) {
+	_, err := Avro{}.Marshal(&base.DeleteEventPayload{})
+	assert.EqualError(t, err, "Codec avro/binary can't encode events of type *base.DeleteEventPayload.")
+}
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		contentType string
+		codec       Codec
+	}{
+		{"application/json", JSON{}},
+		{"application/json; charset=utf-8", JSON{}},
+		{"Application/MsgPack", MsgPack{}},
+		{"avro/binary", Avro{}},
+	}
+
+	for _, test := range tests {
+		c, err := Lookup(test.contentType)
+		assert.NoError(t, err, test.contentType)
+		assert.Equal(t, test.codec, c, test.contentType)
+	}
+
+	_, err := Lookup("text/plain")
+	assert.EqualError(t, err, "No codec registered for content type text/plain.")
+
+	r := NewRegistry()
+	r.Register(JSON{})
+	_, err = r.Lookup("application/msgpack")
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package codec
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPack encodes events as MessagePack. Fields are named after their json tags,
+// so events are encoded with the same field names as with JSON.
+type MsgPack struct{}
+
+// ContentType application/msgpack
+func (MsgPack) ContentType() string {
+	return "application/msgpack"
+}
+
+// Marshal encodes v as MessagePack
+func (MsgPack) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes MessagePack data into v
+func (MsgPack) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
@@ -0,0 +1,70 @@
+package codec
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+)
+
+// Registry codecs keyed by the content type of the bodies they encode.
+type Registry struct {
+	mutex  sync.RWMutex
+	codecs map[string]Codec
+}
+
+// defaultRegistry the codecs available by default - see Register/Lookup.
+var defaultRegistry = NewRegistry(JSON{}, MsgPack{}, Avro{})
+
+// NewRegistry creates a registry of codecs.
+func NewRegistry(codecs ...Codec) *Registry {
+	r := &Registry{codecs: make(map[string]Codec, len(codecs))}
+	for _, c := range codecs {
+		r.Register(c)
+	}
+
+	return r
+}
+
+// Register registers a codec for its content type - replacing any codec
+// registered for it before.
+func (r *Registry) Register(c Codec) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.codecs[mediaType(c.ContentType())] = c
+}
+
+// Lookup gets the codec for a content type. Parameters e.g charset are ignored.
+func (r *Registry) Lookup(contentType string) (Codec, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	c, ok := r.codecs[mediaType(contentType)]
+	if !ok {
+		return nil, fmt.Errorf(libErrs.ErrorUnsupportedContentType.Error(), contentType)
+	}
+
+	return c, nil
+}
+
+// Register registers a codec with the default registry.
+func Register(c Codec) {
+	defaultRegistry.Register(c)
+}
+
+// Lookup gets the codec for a content type from the default registry.
+func Lookup(contentType string) (Codec, error) {
+	return defaultRegistry.Lookup(contentType)
+}
+
+// mediaType the media type of a content type, without any parameters.
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
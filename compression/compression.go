@@ -0,0 +1,138 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	libErrs "github.com/eyewa/eyewa-go-lib/errors"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MaxDecompressedSize max no. of bytes data is decompressed to - RMQ's default
+// max message size, as no event could be published any larger uncompressed.
+// Data decompressing to more is refused, so a small message can't exhaust memory.
+const MaxDecompressedSize = 128 << 20
+
+// zstd encoders/decoders are safe for concurrent use of EncodeAll/DecodeAll
+// and costly to create, so they are shared - decoders per limit they decode to.
+var (
+	zstdOnce     sync.Once
+	zstdEncoder  *zstd.Encoder
+	zstdErr      error
+	zstdMutex    sync.Mutex
+	zstdDecoders = map[int]*zstd.Decoder{}
+)
+
+// Validate checks an encoding is supported - gzip or zstd.
+func Validate(encoding string) error {
+	switch normalize(encoding) {
+	case Gzip, Zstd:
+		return nil
+	}
+
+	return fmt.Errorf(libErrs.ErrorUnsupportedContentEncoding.Error(), encoding)
+}
+
+// Compress compresses data with encoding - gzip or zstd.
+func Compress(encoding string, data []byte) ([]byte, error) {
+	switch normalize(encoding) {
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+
+	return nil, fmt.Errorf(libErrs.ErrorUnsupportedContentEncoding.Error(), encoding)
+}
+
+// Decompress decompresses data compressed with encoding. Data without an
+// encoding or the identity encoding is returned as is. Data decompressing to
+// more than MaxDecompressedSize fails with errors.ErrorDecompressedSizeExceeded.
+func Decompress(encoding string, data []byte) ([]byte, error) {
+	return decompress(encoding, data, MaxDecompressedSize)
+}
+
+// decompress decompresses data compressed with encoding to no more than limit bytes.
+func decompress(encoding string, data []byte, limit int) ([]byte, error) {
+	switch normalize(encoding) {
+	case "", "identity":
+		return data, nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		decompressed, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(decompressed) > limit {
+			return nil, fmt.Errorf(libErrs.ErrorDecompressedSizeExceeded.Error(), limit)
+		}
+		return decompressed, nil
+	case Zstd:
+		decoder, err := zstdDecoder(limit)
+		if err != nil {
+			return nil, err
+		}
+
+		// the decoder refuses to decode more than limit
+		decompressed, err := decoder.DecodeAll(data, nil)
+		if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) || len(decompressed) > limit {
+			return nil, fmt.Errorf(libErrs.ErrorDecompressedSizeExceeded.Error(), limit)
+		}
+		return decompressed, err
+	}
+
+	return nil, fmt.Errorf(libErrs.ErrorUnsupportedContentEncoding.Error(), encoding)
+}
+
+// initZstd creates the shared zstd encoder.
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+	})
+
+	return zstdErr
+}
+
+// zstdDecoder the shared zstd decoder refusing to decode more than limit bytes.
+func zstdDecoder(limit int) (*zstd.Decoder, error) {
+	zstdMutex.Lock()
+	defer zstdMutex.Unlock()
+
+	if decoder, ok := zstdDecoders[limit]; ok {
+		return decoder, nil
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	zstdDecoders[limit] = decoder
+
+	return decoder, nil
+}
+
+// normalize encodings are case-insensitive.
+func normalize(encoding string) string {
+	return strings.ToLower(strings.TrimSpace(encoding))
+}
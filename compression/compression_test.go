@@ -0,0 +1,78 @@
+package compression
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"sku":"123","name":"Ray-Ban"}`), 100)
+
+	for _, encoding := range []string{Gzip, Zstd, "GZIP"} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(encoding, data)
+			assert.NoError(t, err)
+			assert.Less(t, len(compressed), len(data))
+
+			decompressed, err := Decompress(encoding, compressed)
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	data, err := Decompress("", []byte("plain"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plain"), data)
+
+	_, err = Decompress(Gzip, []byte("not gzip"))
+	assert.Error(t, err)
+
+	_, err = Decompress(Zstd, []byte("not zstd"))
+	assert.Error(t, err)
+
+	_, err = Decompress("br", []byte("plain"))
+	assert.EqualError(t, err, "Unsupported content encoding br.")
+}
+
+func TestDecompressLimit(t *testing.T) {
+	data := make([]byte, 4096)
+
+	for _, encoding := range []string{Gzip, Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(encoding, data)
+			assert.NoError(t, err)
+
+			decompressed, err := decompress(encoding, compressed, len(data))
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+
+			_, err = decompress(encoding, compressed, len(data)-1)
+			assert.EqualError(t, err, "Decompressed size exceeds the limit of 4095 bytes.")
+		})
+	}
+}
+
+func TestZstdDecoderLimit(t *testing.T) {
+	// frames of 1KB of zeros back to back, decompressed as one
+	frame, err := Compress(Zstd, make([]byte, 1024))
+	assert.NoError(t, err)
+
+	decompressed, err := decompress(Zstd, bytes.Repeat(frame, 4), 4096)
+	assert.NoError(t, err)
+	assert.Len(t, decompressed, 4096)
+
+	_, err = decompress(Zstd, bytes.Repeat(frame, 5), 4096)
+	assert.EqualError(t, err, "Decompressed size exceeds the limit of 4096 bytes.")
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("zstd"))
+	assert.EqualError(t, Validate("deflate"), "Unsupported content encoding deflate.")
+
+	_, err := Compress("deflate", nil)
+	assert.Error(t, err)
+}
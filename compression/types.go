@@ -0,0 +1,8 @@
+package compression
+
+const (
+	// Gzip content encoding of bodies compressed with gzip
+	Gzip = "gzip"
+	// Zstd content encoding of bodies compressed with Zstandard
+	Zstd = "zstd"
+)
@@ -26,6 +26,51 @@ var (
 	ErrorChannelCreateFailure            = errors.New("Failed to create new channel for queue(%s). %s")
 	ErrorQueueInspectFailure             = errors.New("Failed to inspect queue(%s). %s")
 	ErrorQueueInspectMissingQueueFailure = errors.New("Queue specified to inspect doesn't exist queue(%s)")
+	ErrorNoSQSClient                     = errors.New("No SQS client exists!")
+	ErrorReceiveFailure                  = errors.New("Failed to receive messages from queue(%s). Retrying in %s. %s")
+	ErrorNoKafkaConnection               = errors.New("No connection to Kafka exists!")
+	ErrorNoKafkaBrokersSpecified         = errors.New("No Kafka brokers specified!")
+	ErrorNoMemoryBrokerConnection        = errors.New("No connection to in-memory message broker exists!")
+	ErrorDeliveryNotAcknowledgeable      = errors.New("Delivery has no acknowledger. Cannot settle it with the message broker.")
+	ErrorRequeueNotSupported             = errors.New("Message broker does not support requeuing a single message.")
+	ErrorPublishNacked                   = errors.New("Message broker failed to confirm published message.")
+	ErrorPublishReturned                 = errors.New("Published message was returned by the broker as unroutable.")
+	ErrorPublishConfirmTimeout           = errors.New("Timed out waiting for message broker to confirm published message.")
+	ErrorConnectionRecoveryFailure       = errors.New("Failed to recover connection to message broker.")
+	ErrorFailedToPublishToRetry          = errors.New("Failed to publish event to retry queue. Deadlettering instead.")
+	ErrorFailedToReplayEvent             = errors.New("Failed to replay deadlettered event.")
+	ErrorConsumerStopped                 = errors.New("Consumer was stopped.")
+	ErrorConsumerShutdownTimeout         = errors.New("Timed out waiting for messages in flight to be processed. Stopping consumer regardless.")
+	ErrorInvalidQueueConfig              = errors.New("Invalid queue config. %s")
+	ErrorTopologyLoadFailure             = errors.New("Failed to load topology from %s. %s")
+	ErrorTopologyDrift                   = errors.New("RMQ has drifted from the topology.")
+	ErrorRoutingFailure                  = errors.New("Failed to route event published to queue(%s). %s")
+	ErrorInvalidStreamOffset             = errors.New("Invalid stream offset %s.")
+	ErrorCallbackPanic                   = errors.New("Recovered from panic in callback. %v")
+	ErrorMessageTimeout                  = errors.New("Timed out processing message after %s.")
+	ErrorBatchPublishFailure             = errors.New("Failed to publish %d of %d events of batch.")
+
+	// CloudEvents errors
+	ErrorInvalidCloudEvent         = errors.New("Invalid cloud event. %s")
+	ErrorUnsupportedCloudEventType = errors.New("Events of type %T can't be mapped to cloud events.")
+	ErrorUnknownCloudEventsMode    = errors.New("Unknown cloud events mode %s.")
+
+	// Codec errors
+	ErrorUnsupportedContentType = errors.New("No codec registered for content type %s.")
+	ErrorUnsupportedCodecType   = errors.New("Codec %s can't encode events of type %T.")
+
+	// Compression errors
+	ErrorUnsupportedContentEncoding = errors.New("Unsupported content encoding %s.")
+	ErrorDecompressedSizeExceeded   = errors.New("Decompressed size exceeds the limit of %d bytes.")
+
+	// Outbox errors
+	ErrorOutboxRelayFailure = errors.New("Failed to relay event from outbox.")
+
+	// Dedup errors
+	ErrorDedupStoreFailure = errors.New("Failed to access dedup store.")
+
+	// Middleware errors
+	ErrorInvalidEvent = errors.New("Invalid event(%s). %s")
 
 	// Tracing errors
 	ErrorNoExporterEndpointSpecified = errors.New("No exporter endpoint specified.")
@@ -3,6 +3,7 @@ package amqp
 import (
 	"testing"
 
+	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -100,3 +101,14 @@ func TestPublishingHeaderCarrierKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHeaderCarrierSkipsNonStringHeaders(t *testing.T) {
+	carrier := NewHeaderCarrier(amqp.Table{
+		"traceparent":   "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"x-retry-count": int32(2),
+		"x-death":       []interface{}{amqp.Table{"count": int64(1)}},
+	})
+
+	assert.Equal(t, []string{"traceparent"}, carrier.Keys())
+	assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", carrier.Get("traceparent"))
+}
@@ -17,7 +17,11 @@ type HeaderCarrier struct {
 func NewHeaderCarrier(data amqp.Table) *HeaderCarrier {
 	copied := make(map[string]string, len(data))
 	for k, v := range data {
-		copied[k] = v.(string)
+		// only string headers can hold trace context, skip anything
+		// else e.g retry counts or x-death.
+		if s, ok := v.(string); ok {
+			copied[k] = s
+		}
 	}
 	return &HeaderCarrier{data: copied}
 }
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"github.com/segmentio/kafka-go"
+)
+
+// Get returns the value associated with the passed key.
+func (c *HeaderCarrier) Get(key string) string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set stores the key-value pair, replacing any existing value for key.
+func (c *HeaderCarrier) Set(key, value string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys returns the keys for which this carrier has a value.
+func (c *HeaderCarrier) Keys() []string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	result := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		result = append(result, h.Key)
+	}
+	return result
+}
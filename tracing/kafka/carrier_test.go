@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderCarrierGet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		carrier  *HeaderCarrier
+		key      string
+		expected string
+	}{
+		{
+			name:     "header exists",
+			carrier:  NewHeaderCarrier(&[]kafka.Header{{Key: "foo", Value: []byte("bar")}}),
+			key:      "foo",
+			expected: "bar",
+		},
+		{
+			name:     "header does not exists",
+			carrier:  NewHeaderCarrier(nil),
+			key:      "foo",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.carrier.Get(tc.key)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestHeaderCarrierSet(t *testing.T) {
+	headers := []kafka.Header{{Key: "foo", Value: []byte("bar")}}
+	carrier := NewHeaderCarrier(&headers)
+
+	carrier.Set("foo", "bar1")
+	carrier.Set("hello", "world")
+
+	assert.Equal(t, []kafka.Header{
+		{Key: "foo", Value: []byte("bar1")},
+		{Key: "hello", Value: []byte("world")},
+	}, headers)
+	assert.ElementsMatch(t, []string{"foo", "hello"}, carrier.Keys())
+}
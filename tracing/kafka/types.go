@@ -0,0 +1,23 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderCarrier carries trace context over kafka message headers.
+type HeaderCarrier struct {
+	mtx sync.RWMutex
+
+	headers *[]kafka.Header
+}
+
+// NewHeaderCarrier wraps the headers of a message. Injected values are
+// written to the headers directly so they end up on the message being published.
+func NewHeaderCarrier(headers *[]kafka.Header) *HeaderCarrier {
+	if headers == nil {
+		headers = &[]kafka.Header{}
+	}
+	return &HeaderCarrier{headers: headers}
+}
@@ -0,0 +1,47 @@
+package sqs
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Get returns the value associated with the passed key.
+func (c *AttributeCarrier) Get(key string) string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if v, ok := c.data[key]; ok && v != nil {
+		return aws.StringValue(v.StringValue)
+	}
+	return ""
+}
+
+// Set stores the key-value pair as a string message attribute.
+func (c *AttributeCarrier) Set(key, value string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.data[key] = &sqs.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+// Keys returns the keys for which this carrier has a value.
+func (c *AttributeCarrier) Keys() []string {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	result := make([]string, 0, len(c.data))
+	for k := range c.data {
+		result = append(result, k)
+	}
+	return result
+}
+
+// Attributes returns the underlying message attributes.
+func (c *AttributeCarrier) Attributes() map[string]*sqs.MessageAttributeValue {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.data
+}
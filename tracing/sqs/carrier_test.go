@@ -0,0 +1,52 @@
+package sqs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributeCarrierGet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		carrier  *AttributeCarrier
+		key      string
+		expected string
+	}{
+		{
+			name: "attribute exists",
+			carrier: NewAttributeCarrier(map[string]*sqs.MessageAttributeValue{
+				"foo": {DataType: aws.String("String"), StringValue: aws.String("bar")},
+			}),
+			key:      "foo",
+			expected: "bar",
+		},
+		{
+			name:     "attribute does not exists",
+			carrier:  NewAttributeCarrier(nil),
+			key:      "foo",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.carrier.Get(tc.key)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestAttributeCarrierSet(t *testing.T) {
+	attrs := map[string]*sqs.MessageAttributeValue{}
+	carrier := NewAttributeCarrier(attrs)
+
+	carrier.Set("foo", "bar")
+	carrier.Set("hello", "world")
+
+	assert.Equal(t, "bar", aws.StringValue(attrs["foo"].StringValue))
+	assert.Equal(t, "String", aws.StringValue(attrs["hello"].DataType))
+	assert.ElementsMatch(t, []string{"foo", "hello"}, carrier.Keys())
+}
@@ -0,0 +1,24 @@
+package sqs
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// AttributeCarrier carries trace context over SQS message attributes.
+type AttributeCarrier struct {
+	mtx sync.RWMutex
+
+	data map[string]*sqs.MessageAttributeValue
+}
+
+// NewAttributeCarrier wraps the message attributes of a message. The
+// attributes map is written to directly so injected values end up on the
+// message being published.
+func NewAttributeCarrier(data map[string]*sqs.MessageAttributeValue) *AttributeCarrier {
+	if data == nil {
+		data = make(map[string]*sqs.MessageAttributeValue)
+	}
+	return &AttributeCarrier{data: data}
+}